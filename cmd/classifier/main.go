@@ -0,0 +1,224 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package classifier
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/parser"
+	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+var (
+	operator     string
+	cookieName   string
+	category     string
+	description  string
+	exportFormat string
+	exportOutput string
+	lang         string
+	snapshotPath string
+)
+
+func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
+	c := app.Command("classifier", "manage cookie classifier definitions")
+	c.Flag("operator", "operator name recorded in classifier change history").
+		Envar("USER").StringVar(&operator)
+
+	add := c.Command("add", "add a new cookie definition")
+	add.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	add.Flag("category", "cookie category").Required().StringVar(&category)
+	add.Flag("desc", "cookie description").StringVar(&description)
+	add.Action(func(context *kingpin.ParseContext) error {
+		return withClassifier(opts, addHandler)
+	})
+
+	update := c.Command("update", "update an existing cookie definition")
+	update.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	update.Flag("category", "new cookie category").StringVar(&category)
+	update.Flag("desc", "new cookie description").StringVar(&description)
+	update.Action(func(context *kingpin.ParseContext) error {
+		return withClassifier(opts, updateHandler)
+	})
+
+	del := c.Command("delete", "delete a cookie definition")
+	del.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	del.Action(func(context *kingpin.ParseContext) error {
+		return withClassifier(opts, deleteHandler)
+	})
+
+	translate := c.Command("translate", "set cookie description in another language")
+	translate.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	translate.Flag("lang", "description language").Required().StringVar(&lang)
+	translate.Flag("desc", "translated cookie description").Required().StringVar(&description)
+	translate.Action(func(context *kingpin.ParseContext) error {
+		return withClassifier(opts, translateHandler)
+	})
+
+	show := c.Command("show", "show a cookie definition and its change history")
+	show.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	show.Action(func(context *kingpin.ParseContext) error {
+		return withClassifier(opts, showHandler)
+	})
+
+	sync := c.Command("sync", "copy classifier definitions to a local snapshot")
+	sync.Flag("output", "snapshot file, defaults to --classifier-snapshot").StringVar(&snapshotPath)
+	sync.Action(func(context *kingpin.ParseContext) error {
+		if snapshotPath == "" {
+			snapshotPath = opts.ClassifierSnapshot
+		}
+		if snapshotPath == "" {
+			return errors.New("snapshot file not provided, use --output or --classifier-snapshot")
+		}
+
+		return withClassifier(opts, func(c *parser.Classifier) (err error) {
+			version, err := c.SyncSnapshot(snapshotPath, opts.ClassifierDB)
+			if err != nil {
+				return errors.Wrap(err, "sync classifier snapshot failed")
+			}
+
+			fmt.Println(version)
+			return
+		})
+	})
+
+	export := c.Command("export", "export all cookie definitions")
+	export.Flag("format", "export format").Default(formatJSON).EnumVar(&exportFormat, formatJSON, formatCSV)
+	export.Flag("output", "export to file instead of stdout").StringVar(&exportOutput)
+	export.Action(func(context *kingpin.ParseContext) error {
+		return withClassifier(opts, exportHandler)
+	})
+}
+
+func withClassifier(opts *cmd.CommonOptions, h func(c *parser.Classifier) error) error {
+	if opts.ClassifierHandler == nil {
+		return errors.New("classifier database not provided, use --classifier")
+	}
+
+	return h(opts.ClassifierHandler)
+}
+
+func addHandler(c *parser.Classifier) (err error) {
+	err = errors.Wrapf(c.AddCookie(&parser.CookieDefinition{
+		Name:        cookieName,
+		Category:    category,
+		Description: description,
+	}, operator), "add cookie definition failed")
+	return
+}
+
+func updateHandler(c *parser.Classifier) (err error) {
+	if category == "" && description == "" {
+		return errors.New("nothing to update, provide --category or --desc")
+	}
+
+	err = errors.Wrapf(c.UpdateCookie(&parser.CookieDefinition{
+		Name:        cookieName,
+		Category:    category,
+		Description: description,
+	}, operator), "update cookie definition failed")
+	return
+}
+
+func deleteHandler(c *parser.Classifier) (err error) {
+	err = errors.Wrapf(c.DeleteCookie(cookieName, operator), "delete cookie definition failed")
+	return
+}
+
+func translateHandler(c *parser.Classifier) (err error) {
+	err = errors.Wrapf(c.SetTranslation(cookieName, lang, description, operator), "translate cookie definition failed")
+	return
+}
+
+func showHandler(c *parser.Classifier) (err error) {
+	def, err := c.GetCookie(cookieName)
+	if err != nil {
+		return
+	}
+	if def == nil {
+		return errors.Errorf("cookie %s not found", cookieName)
+	}
+
+	history, err := c.History(cookieName)
+	if err != nil {
+		return
+	}
+
+	jsonBlob, err := json.MarshalIndent(map[string]interface{}{
+		"definition": def,
+		"history":    history,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+
+	fmt.Println(string(jsonBlob))
+
+	return
+}
+
+func exportHandler(c *parser.Classifier) (err error) {
+	defs, err := c.ListCookies()
+	if err != nil {
+		return
+	}
+
+	var w io.Writer = os.Stdout
+
+	if exportOutput != "" {
+		var f *os.File
+		if f, err = os.OpenFile(exportOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+			err = errors.Wrap(err, "open export file failed")
+			return
+		}
+
+		defer func() {
+			_ = f.Close()
+		}()
+
+		w = f
+	}
+
+	switch strings.ToLower(exportFormat) {
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"name", "category", "description"})
+		for _, def := range defs {
+			_ = cw.Write([]string{def.Name, def.Category, def.Description})
+		}
+		cw.Flush()
+		err = errors.Wrap(cw.Error(), "write csv export failed")
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = errors.Wrap(enc.Encode(defs), "write json export failed")
+	}
+
+	return
+}
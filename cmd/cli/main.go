@@ -18,6 +18,7 @@ package cli
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 
 	"github.com/CovenantSQL/CookieScanner/cmd"
@@ -32,7 +33,18 @@ var (
 	outputJSON bool
 	outputHTML string
 	outputPDF  string
+	outputCSV  string
+	outputMD   string
+	outputDecl string
+	declFormat string
+	outputCMP  string
+	cmpFormat  string
+	outputInit string
+	initFormat string
+	outputHAR  string
+	trace      string
 	site       string
+	lang       string
 )
 
 func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
@@ -42,6 +54,21 @@ func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
 	c.Flag("json", "print report as json").BoolVar(&outputJSON)
 	c.Flag("html", "save report as html").StringVar(&outputHTML)
 	c.Flag("pdf", "save report as pdf").StringVar(&outputPDF)
+	c.Flag("csv", "save report as csv").StringVar(&outputCSV)
+	c.Flag("markdown", "save report as markdown").StringVar(&outputMD)
+	c.Flag("declaration", "save cookie declaration for the cookie policy page").StringVar(&outputDecl)
+	c.Flag("declaration-format", "cookie declaration format").Default(parser.DeclarationFragment).
+		EnumVar(&declFormat, parser.DeclarationFormats()...)
+	c.Flag("consent", "save consent-manager configuration").StringVar(&outputCMP)
+	c.Flag("consent-format", "consent-manager configuration format").Default(parser.ConsentGeneric).
+		EnumVar(&cmpFormat, parser.ConsentFormats()...)
+	c.Flag("initiators", "save request initiator graph").StringVar(&outputInit)
+	c.Flag("initiators-format", "request initiator graph format").Default(parser.InitiatorGraphJSON).
+		EnumVar(&initFormat, parser.InitiatorGraphFormats()...)
+	c.Flag("har", "save network activity as http archive (har)").StringVar(&outputHAR)
+	c.Flag("trace", "record debugger events and cookies as ndjson trace for replay").StringVar(&trace)
+	c.Flag("lang", "report language").Default(parser.DefaultLanguage).
+		EnumVar(&lang, parser.SupportedLanguages()...)
 	c.Arg("site", "site url").Required().StringVar(&site)
 	c.Action(func(context *kingpin.ParseContext) error {
 		return handler(opts)
@@ -49,7 +76,8 @@ func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
 }
 
 func handler(opts *cmd.CommonOptions) (err error) {
-	if !outputJSON && outputHTML == "" && outputPDF == "" {
+	if !outputJSON && outputHTML == "" && outputPDF == "" && outputCSV == "" && outputMD == "" &&
+		outputDecl == "" && outputCMP == "" && outputInit == "" && outputHAR == "" {
 		outputJSON = true
 	}
 
@@ -57,15 +85,38 @@ func handler(opts *cmd.CommonOptions) (err error) {
 		headless = true
 	}
 
-	t := parser.NewTask(&parser.TaskConfig{
+	tc := &parser.TaskConfig{
 		Timeout:           opts.Timeout,
 		WaitAfterPageLoad: opts.WaitAfterPageLoad,
 		Verbose:           opts.Verbose,
 		ChromeApp:         opts.ChromeApp,
 		DebuggerPort:      port,
 		Headless:          headless,
-		Classifier:        opts.ClassifierHandler,
-	})
+		Classifier:        opts.ScanClassifier(),
+		ReviewQueue:       opts.ReviewHandler,
+		Lang:              lang,
+		Templates:         opts.Templates,
+		Branding:          opts.Branding,
+		PDF:               &opts.PDF,
+		Screenshot:        &opts.Screenshot,
+		Resolver:          opts.Resolver,
+		FilterLists:       opts.FilterLists,
+		GeoIP:             opts.GeoIP,
+	}
+
+	if trace != "" {
+		var f *os.File
+		if f, err = os.Create(trace); err != nil {
+			err = errors.Wrapf(err, "create trace %s failed", trace)
+			return
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		tc.Trace = f
+	}
+
+	t := parser.NewTask(tc)
 
 	if err = t.Start(); err != nil {
 		err = errors.Wrapf(err, "start debugger failed")
@@ -80,30 +131,37 @@ func handler(opts *cmd.CommonOptions) (err error) {
 	}
 
 	if outputJSON {
-		if jsonData, err := t.OutputJSON(true); err == nil {
-			fmt.Println(jsonData)
-		} else {
+		var jsonData string
+		if jsonData, err = t.OutputJSON(true); err != nil {
 			err = errors.Wrapf(err, "generate json report failed")
+			return
 		}
 
-		return
+		fmt.Println(jsonData)
 	}
 
-	if outputHTML != "" {
-		var htmlData string
-		if htmlData, err = t.OutputHTML(); err != nil {
-			err = errors.Wrapf(err, "generate html report failed")
-		} else {
-			var f *os.File
-			if f, err = os.OpenFile(outputHTML, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-				err = errors.Wrap(err, "write html report failed")
-				return
-			}
-			_, _ = f.WriteString(htmlData)
-			_ = f.Sync()
-			_ = f.Close()
+	for _, o := range []struct {
+		filename string
+		format   string
+		generate func() (string, error)
+	}{
+		{outputHTML, "html", t.OutputHTML},
+		{outputCSV, "csv", t.OutputCSV},
+		{outputMD, "markdown", t.OutputMarkdown},
+		{outputDecl, "declaration", func() (string, error) {
+			return t.OutputDeclaration(declFormat)
+		}},
+		{outputCMP, "consent-manager", func() (string, error) {
+			return t.OutputConsentConfig(cmpFormat)
+		}},
+		{outputInit, "initiator graph", func() (string, error) {
+			return t.OutputInitiatorGraph(initFormat)
+		}},
+		{outputHAR, "har", t.OutputHAR},
+	} {
+		if err = saveReport(o.filename, o.format, o.generate); err != nil {
+			return
 		}
-		return
 	}
 
 	if outputPDF != "" {
@@ -113,3 +171,21 @@ func handler(opts *cmd.CommonOptions) (err error) {
 
 	return
 }
+
+func saveReport(filename string, format string, generate func() (string, error)) (err error) {
+	if filename == "" {
+		return
+	}
+
+	data, err := generate()
+	if err != nil {
+		err = errors.Wrapf(err, "generate %s report failed", format)
+		return
+	}
+
+	if err = ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		err = errors.Wrapf(err, "write %s report failed", format)
+	}
+
+	return
+}
@@ -23,10 +23,41 @@ import (
 )
 
 type CommonOptions struct {
-	ChromeApp         string
-	Verbose           bool
-	Timeout           time.Duration
-	WaitAfterPageLoad time.Duration
-	ClassifierDB      string
-	ClassifierHandler *parser.Classifier
+	ChromeApp           string
+	Verbose             bool
+	Timeout             time.Duration
+	WaitAfterPageLoad   time.Duration
+	ClassifierDB        string
+	ClassifierHandler   *parser.Classifier
+	ClassifierSnapshot  string
+	ClassifierCacheSize int
+	ClassifierCacheTTL  time.Duration
+	SnapshotHandler     *parser.ClassifierSnapshot
+	ReviewQueue         string
+	ReviewHandler       *parser.ReviewQueue
+	PublicSuffixList    string
+	CNAMEDetection      bool
+	DNSServer           string
+	DNSTimeout          time.Duration
+	Resolver            parser.CNAMEResolver
+	TrackerLists        []string
+	AdLists             []string
+	FilterLists         *parser.FilterLists
+	GeoIPDatabases      []string
+	GeoIP               *parser.GeoIP
+	TemplateDir         string
+	Templates           *parser.Templates
+	BrandingOptions     parser.Branding
+	Branding            *parser.Branding
+	PDF                 parser.PDFOptions
+	Screenshot          parser.ScreenshotOptions
+}
+
+// ScanClassifier returns the classifier used by scans, local snapshot is preferred over the remote database.
+func (o *CommonOptions) ScanClassifier() *parser.Classifier {
+	if c := o.SnapshotHandler.Classifier(); c != nil {
+		return c
+	}
+
+	return o.ClassifierHandler
 }
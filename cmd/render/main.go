@@ -0,0 +1,114 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package render
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/parser"
+	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	formatJSON        = "json"
+	formatHTML        = "html"
+	formatCSV         = "csv"
+	formatMarkdown    = "markdown"
+	formatDeclaration = "declaration"
+	formatConsent     = "consent"
+	formatInitiators  = "initiators"
+)
+
+var (
+	reportFile string
+	format     string
+	declFormat string
+	cmpFormat  string
+	initFormat string
+	output     string
+)
+
+func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
+	c := app.Command("render", "render a saved json report without re-running the scan")
+	c.Flag("format", "output format").Default(formatDeclaration).
+		EnumVar(&format, formatJSON, formatHTML, formatCSV, formatMarkdown, formatDeclaration, formatConsent,
+			formatInitiators)
+	c.Flag("declaration-format", "cookie declaration format").Default(parser.DeclarationFragment).
+		EnumVar(&declFormat, parser.DeclarationFormats()...)
+	c.Flag("consent-format", "consent-manager configuration format").Default(parser.ConsentGeneric).
+		EnumVar(&cmpFormat, parser.ConsentFormats()...)
+	c.Flag("initiators-format", "request initiator graph format").Default(parser.InitiatorGraphJSON).
+		EnumVar(&initFormat, parser.InitiatorGraphFormats()...)
+	c.Flag("output", "output file, print to stdout if not provided").StringVar(&output)
+	c.Arg("report", "json report generated by cli or server").Required().ExistingFileVar(&reportFile)
+	c.Action(func(context *kingpin.ParseContext) error {
+		return handler(opts)
+	})
+}
+
+func handler(opts *cmd.CommonOptions) (err error) {
+	jsonData, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		err = errors.Wrapf(err, "read report %s failed", reportFile)
+		return
+	}
+
+	t, err := parser.LoadReport(&parser.TaskConfig{
+		Templates: opts.Templates,
+		Branding:  opts.Branding,
+	}, jsonData)
+	if err != nil {
+		return
+	}
+
+	var data string
+
+	switch format {
+	case formatJSON:
+		data, err = t.OutputJSON(true)
+	case formatHTML:
+		data, err = t.OutputHTML()
+	case formatCSV:
+		data, err = t.OutputCSV()
+	case formatMarkdown:
+		data, err = t.OutputMarkdown()
+	case formatDeclaration:
+		data, err = t.OutputDeclaration(declFormat)
+	case formatConsent:
+		data, err = t.OutputConsentConfig(cmpFormat)
+	case formatInitiators:
+		data, err = t.OutputInitiatorGraph(initFormat)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "generate %s output failed", format)
+		return
+	}
+
+	if output == "" {
+		fmt.Println(data)
+		return
+	}
+
+	if err = ioutil.WriteFile(output, []byte(data), 0644); err != nil {
+		err = errors.Wrapf(err, "write %s output failed", format)
+	}
+
+	return
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/parser"
+	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	formatJSON        = "json"
+	formatHTML        = "html"
+	formatCSV         = "csv"
+	formatMarkdown    = "markdown"
+	formatDeclaration = "declaration"
+	formatConsent     = "consent"
+	formatInitiators  = "initiators"
+	formatHAR         = "har"
+)
+
+var (
+	recordingFile string
+	format        string
+	declFormat    string
+	cmpFormat     string
+	initFormat    string
+	lang          string
+	output        string
+)
+
+func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
+	c := app.Command("replay", "generate report from a trace recorded by cli or a har file without browser")
+	c.Flag("format", "output format").Default(formatJSON).
+		EnumVar(&format, formatJSON, formatHTML, formatCSV, formatMarkdown, formatDeclaration, formatConsent,
+			formatInitiators, formatHAR)
+	c.Flag("declaration-format", "cookie declaration format").Default(parser.DeclarationFragment).
+		EnumVar(&declFormat, parser.DeclarationFormats()...)
+	c.Flag("consent-format", "consent-manager configuration format").Default(parser.ConsentGeneric).
+		EnumVar(&cmpFormat, parser.ConsentFormats()...)
+	c.Flag("initiators-format", "request initiator graph format").Default(parser.InitiatorGraphJSON).
+		EnumVar(&initFormat, parser.InitiatorGraphFormats()...)
+	c.Flag("lang", "report language").Default(parser.DefaultLanguage).
+		EnumVar(&lang, parser.SupportedLanguages()...)
+	c.Flag("output", "output file, print to stdout if not provided").StringVar(&output)
+	c.Arg("recording", "ndjson trace recorded by cli --trace or har file").Required().ExistingFileVar(&recordingFile)
+	c.Action(func(context *kingpin.ParseContext) error {
+		return handler(opts)
+	})
+}
+
+func handler(opts *cmd.CommonOptions) (err error) {
+	data, err := ioutil.ReadFile(recordingFile)
+	if err != nil {
+		err = errors.Wrapf(err, "read recording %s failed", recordingFile)
+		return
+	}
+
+	// replay is offline, cookies are not queued for review and hosts are not resolved
+	t := parser.NewTask(&parser.TaskConfig{
+		Classifier:  opts.ScanClassifier(),
+		Lang:        lang,
+		Templates:   opts.Templates,
+		Branding:    opts.Branding,
+		FilterLists: opts.FilterLists,
+		GeoIP:       opts.GeoIP,
+	})
+
+	if err = t.ParseRecording(data); err != nil {
+		err = errors.Wrapf(err, "parse recording %s failed", recordingFile)
+		return
+	}
+
+	var result string
+
+	switch format {
+	case formatJSON:
+		result, err = t.OutputJSON(true)
+	case formatHTML:
+		result, err = t.OutputHTML()
+	case formatCSV:
+		result, err = t.OutputCSV()
+	case formatMarkdown:
+		result, err = t.OutputMarkdown()
+	case formatDeclaration:
+		result, err = t.OutputDeclaration(declFormat)
+	case formatConsent:
+		result, err = t.OutputConsentConfig(cmpFormat)
+	case formatInitiators:
+		result, err = t.OutputInitiatorGraph(initFormat)
+	case formatHAR:
+		result, err = t.OutputHAR()
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "generate %s output failed", format)
+		return
+	}
+
+	if output == "" {
+		fmt.Println(result)
+		return
+	}
+
+	if err = ioutil.WriteFile(output, []byte(result), 0644); err != nil {
+		err = errors.Wrapf(err, "write %s output failed", format)
+	}
+
+	return
+}
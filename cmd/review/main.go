@@ -0,0 +1,98 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/parser"
+	"github.com/pkg/errors"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	operator    string
+	status      string
+	cookieName  string
+	category    string
+	description string
+)
+
+func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
+	c := app.Command("review", "review unclassified cookies collected by scans")
+	c.Flag("operator", "operator name recorded in classifier change history").
+		Envar("USER").StringVar(&operator)
+
+	list := c.Command("list", "list queued cookies")
+	list.Flag("status", "filter by review status").
+		EnumVar(&status, parser.ReviewStatusPending, parser.ReviewStatusAssigned, parser.ReviewStatusPromoted)
+	list.Action(func(context *kingpin.ParseContext) error {
+		return withQueue(opts, listHandler)
+	})
+
+	assign := c.Command("assign", "assign classification to a queued cookie")
+	assign.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	assign.Flag("category", "cookie category").Required().StringVar(&category)
+	assign.Flag("desc", "cookie description").StringVar(&description)
+	assign.Action(func(context *kingpin.ParseContext) error {
+		return withQueue(opts, assignHandler)
+	})
+
+	promote := c.Command("promote", "promote assigned classification to classifier database")
+	promote.Arg("name", "cookie name").Required().StringVar(&cookieName)
+	promote.Action(func(context *kingpin.ParseContext) error {
+		if opts.ClassifierHandler == nil {
+			return errors.New("classifier database not provided, use --classifier")
+		}
+
+		return withQueue(opts, func(q *parser.ReviewQueue) error {
+			return errors.Wrap(q.Promote(cookieName, opts.ClassifierHandler, operator),
+				"promote cookie failed")
+		})
+	})
+}
+
+func withQueue(opts *cmd.CommonOptions, h func(q *parser.ReviewQueue) error) error {
+	if opts.ReviewHandler == nil {
+		return errors.New("review queue not provided, use --review-queue")
+	}
+
+	return h(opts.ReviewHandler)
+}
+
+func listHandler(q *parser.ReviewQueue) (err error) {
+	cookies, err := q.List(status)
+	if err != nil {
+		return
+	}
+
+	jsonBlob, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return
+	}
+
+	fmt.Println(string(jsonBlob))
+
+	return
+}
+
+func assignHandler(q *parser.ReviewQueue) (err error) {
+	err = errors.Wrap(q.Assign(cookieName, category, description), "assign cookie failed")
+	return
+}
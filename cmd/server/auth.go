@@ -0,0 +1,48 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// managementTokens maps operators to bearer tokens of the classifier and review api.
+var managementTokens = map[string]string{}
+
+// authorizeManagement returns the operator of the bearer token, the response is sent if request is not authorized.
+func authorizeManagement(rw http.ResponseWriter, r *http.Request) (operator string, ok bool) {
+	if len(managementTokens) == 0 {
+		sendResponse(http.StatusNotFound, false, "management api is disabled", nil, rw)
+		return
+	}
+
+	auth := r.Header.Get("Authorization")
+	if token := strings.TrimPrefix(auth, "Bearer "); token != "" && token != auth {
+		for name, t := range managementTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return name, true
+			}
+		}
+	}
+
+	rw.Header().Set("WWW-Authenticate", `Bearer realm="CookieScanner"`)
+	sendResponse(http.StatusUnauthorized, false, "invalid management token", nil, rw)
+
+	return
+}
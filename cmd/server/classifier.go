@@ -0,0 +1,133 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/parser"
+	"github.com/gorilla/mux"
+)
+
+const (
+	argName        = "name"
+	argCategory    = "category"
+	argDescription = "description"
+	argLang        = "lang"
+)
+
+func classifierAPI(opts *cmd.CommonOptions,
+	h func(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		operator, ok := authorizeManagement(rw, r)
+		if !ok {
+			return
+		}
+		if opts.ClassifierHandler == nil {
+			sendResponse(http.StatusInternalServerError, false, "classifier database not provided", nil, rw)
+			return
+		}
+
+		h(opts.ClassifierHandler, operator, rw, r)
+	}
+}
+
+func exportClassifierFunc(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request) {
+	defs, err := c.ListCookies()
+	if err != nil {
+		sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, defs, rw)
+}
+
+func showClassifierFunc(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)[argName]
+
+	def, err := c.GetCookie(name)
+	if err != nil {
+		sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+		return
+	}
+	if def == nil {
+		sendResponse(http.StatusNotFound, false, "cookie not found", nil, rw)
+		return
+	}
+
+	history, err := c.History(name)
+	if err != nil {
+		sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, map[string]interface{}{
+		"definition": def,
+		"history":    history,
+	}, rw)
+}
+
+func addClassifierFunc(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request) {
+	def := &parser.CookieDefinition{
+		Name:        r.FormValue(argName),
+		Category:    r.FormValue(argCategory),
+		Description: r.FormValue(argDescription),
+	}
+
+	if err := c.AddCookie(def, operator); err != nil {
+		sendResponse(http.StatusBadRequest, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, def, rw)
+}
+
+func updateClassifierFunc(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request) {
+	def := &parser.CookieDefinition{
+		Name:        mux.Vars(r)[argName],
+		Category:    r.FormValue(argCategory),
+		Description: r.FormValue(argDescription),
+	}
+
+	if err := c.UpdateCookie(def, operator); err != nil {
+		sendResponse(http.StatusBadRequest, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, nil, rw)
+}
+
+func deleteClassifierFunc(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request) {
+	if err := c.DeleteCookie(mux.Vars(r)[argName], operator); err != nil {
+		sendResponse(http.StatusBadRequest, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, nil, rw)
+}
+
+func translateClassifierFunc(c *parser.Classifier, operator string, rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := c.SetTranslation(vars[argName], vars[argLang], r.FormValue(argDescription), operator); err != nil {
+		sendResponse(http.StatusBadRequest, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, nil, rw)
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -48,15 +49,40 @@ const (
 	argTo     = "to"
 	argAsync  = "async"
 	argDelay  = "delay"
+	argFormat = "format"
+
+	argPDFPaper        = "pdf_paper"
+	argPDFLandscape    = "pdf_landscape"
+	argPDFMargin       = "pdf_margin"
+	argPDFScale        = "pdf_scale"
+	argPDFHeaderFooter = "pdf_header_footer"
+	argPDFHeader       = "pdf_header"
+	argPDFFooter       = "pdf_footer"
+
+	argScreenshotFullPage = "screenshot_full_page"
+	argScreenshotBanner   = "screenshot_banner"
+	argScreenshotFormat   = "screenshot_format"
+	argScreenshotQuality  = "screenshot_quality"
+	argScreenshotInline   = "screenshot_inline"
 
 	typeJSON  = "json"
 	typeHTML  = "html"
 	typePDF   = "pdf"
 	typeEmail = "email"
+	typeCSV   = "csv"
+	typeMD    = "markdown"
+	typeDecl  = "declaration"
+	typeCMP   = "consent"
+	typeInit  = "initiators"
+	typeHAR   = "har"
 
 	contentTypeJSON = "application/json"
 	contentTypeHTML = "text/html"
 	contentTypePDF  = "application/pdf"
+	contentTypeCSV  = "text/csv; charset=utf-8"
+	contentTypeMD   = "text/markdown; charset=utf-8"
+	contentTypeJS   = "application/javascript"
+	contentTypeDOT  = "text/vnd.graphviz; charset=utf-8"
 
 	mailSubject = `CookieScan Report`
 )
@@ -68,6 +94,12 @@ var (
 	disableHTML  bool
 	disableJSON  bool
 	disableEmail bool
+	disableCSV   bool
+	disableMD    bool
+	disableDecl  bool
+	disableCMP   bool
+	disableInit  bool
+	disableHAR   bool
 
 	versionOnce sync.Once
 	versionLock sync.RWMutex
@@ -88,7 +120,7 @@ var (
 func jsonContentType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		// test if request is post
-		if r.Method == http.MethodPost &&
+		if (r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete) &&
 			r.Header.Get("Content-Type") == "application/json" &&
 			r.Body != nil {
 			// parse json and set to form in request
@@ -136,6 +168,14 @@ func RegisterCommand(app *kingpin.Application, opts *cmd.CommonOptions) {
 	c.Flag("disable-html", "disable html output support").BoolVar(&disableHTML)
 	c.Flag("disable-pdf", "disable pdf output support").BoolVar(&disablePDF)
 	c.Flag("disable-email", "disable htm output support").BoolVar(&disableEmail)
+	c.Flag("disable-csv", "disable csv output support").BoolVar(&disableCSV)
+	c.Flag("disable-markdown", "disable markdown output support").BoolVar(&disableMD)
+	c.Flag("disable-declaration", "disable cookie declaration output support").BoolVar(&disableDecl)
+	c.Flag("disable-consent", "disable consent-manager configuration output support").BoolVar(&disableCMP)
+	c.Flag("disable-initiators", "disable request initiator graph output support").BoolVar(&disableInit)
+	c.Flag("disable-har", "disable http archive (har) output support").BoolVar(&disableHAR)
+	c.Flag("management-token", "enable classifier and review api for operator with bearer token, as operator=token, "+
+		"could be repeated").PlaceHolder("OPERATOR=TOKEN").StringMapVar(&managementTokens)
 	c.Flag("mail-server", "mail server hostname").Envar("MAIL_SERVER").StringVar(&mailServer)
 	c.Flag("mail-port", "mail server port").Envar("MAIL_PORT").IntVar(&mailPort)
 	c.Flag("mail-user", "mail login user").Envar("MAIL_USER").StringVar(&mailUser)
@@ -195,7 +235,8 @@ func getVersionFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 	}
 }
 
-func asyncEmailReport(opts *cmd.CommonOptions, site string, mailTo string) {
+func asyncEmailReport(opts *cmd.CommonOptions, site string, mailTo string, lang string,
+	pdfOpts *parser.PDFOptions, screenshotOpts *parser.ScreenshotOptions) {
 	if maxInflightScan > 0 {
 		if err := inflightSem.Acquire(context.Background(), 1); err != nil {
 			logrus.WithFields(logrus.Fields{
@@ -226,7 +267,16 @@ func asyncEmailReport(opts *cmd.CommonOptions, site string, mailTo string) {
 		ChromeApp:         opts.ChromeApp,
 		DebuggerPort:      port,
 		Headless:          true,
-		Classifier:        opts.ClassifierHandler,
+		Classifier:        opts.ScanClassifier(),
+		ReviewQueue:       opts.ReviewHandler,
+		Lang:              lang,
+		Templates:         opts.Templates,
+		Branding:          opts.Branding,
+		PDF:               pdfOpts,
+		Screenshot:        screenshotOpts,
+		Resolver:          opts.Resolver,
+		FilterLists:       opts.FilterLists,
+		GeoIP:             opts.GeoIP,
 	})
 
 	if err = t.Start(); err != nil {
@@ -282,7 +332,7 @@ func asyncEmailReport(opts *cmd.CommonOptions, site string, mailTo string) {
 	m := gomail.NewMessage()
 	m.SetHeader("From", mailFrom)
 	m.SetAddressHeader("To", mailTo, mailTo)
-	m.SetHeader("Subject", mailSubject)
+	m.SetHeader("Subject", parser.Translate(lang, mailSubject))
 	m.SetBody("text/html", emailContent)
 	m.Attach(tempPDF, gomail.SetHeader(map[string][]string{"Content-Type": {contentTypePDF}}))
 
@@ -303,18 +353,112 @@ func asyncEmailReport(opts *cmd.CommonOptions, site string, mailTo string) {
 	}).Info("generate report complete")
 }
 
+// requestPDFOptions overrides the pdf options of server with request parameters.
+func requestPDFOptions(opts *cmd.CommonOptions, r *http.Request) (pdfOpts *parser.PDFOptions, err error) {
+	o := opts.PDF
+
+	if v := r.FormValue(argPDFPaper); v != "" {
+		o.Paper = v
+	}
+	if v := r.FormValue(argPDFLandscape); v != "" {
+		if o.Landscape, err = strconv.ParseBool(v); err != nil {
+			err = errors.Wrapf(err, "invalid %s", argPDFLandscape)
+			return
+		}
+	}
+	if v := r.FormValue(argPDFMargin); v != "" {
+		o.Margin = v
+	}
+	if v := r.FormValue(argPDFScale); v != "" {
+		if o.Scale, err = strconv.ParseFloat(v, 64); err != nil {
+			err = errors.Wrapf(err, "invalid %s", argPDFScale)
+			return
+		}
+	}
+	if v := r.FormValue(argPDFHeaderFooter); v != "" {
+		if o.HeaderFooter, err = strconv.ParseBool(v); err != nil {
+			err = errors.Wrapf(err, "invalid %s", argPDFHeaderFooter)
+			return
+		}
+	}
+	if v := r.FormValue(argPDFHeader); v != "" {
+		o.HeaderTemplate = v
+	}
+	if v := r.FormValue(argPDFFooter); v != "" {
+		o.FooterTemplate = v
+	}
+
+	if err = o.Validate(); err == nil {
+		pdfOpts = &o
+	}
+
+	return
+}
+
+// requestScreenshotOptions overrides the screenshot options of server with request parameters.
+func requestScreenshotOptions(opts *cmd.CommonOptions, r *http.Request) (screenshotOpts *parser.ScreenshotOptions, err error) {
+	o := opts.Screenshot
+
+	for arg, v := range map[string]*bool{
+		argScreenshotFullPage: &o.FullPage,
+		argScreenshotBanner:   &o.Banner,
+		argScreenshotInline:   &o.Inline,
+	} {
+		if s := r.FormValue(arg); s != "" {
+			if *v, err = strconv.ParseBool(s); err != nil {
+				err = errors.Wrapf(err, "invalid %s", arg)
+				return
+			}
+		}
+	}
+	if v := r.FormValue(argScreenshotFormat); v != "" {
+		o.Format = v
+	}
+	if v := r.FormValue(argScreenshotQuality); v != "" {
+		if o.Quality, err = strconv.Atoi(v); err != nil {
+			err = errors.Wrapf(err, "invalid %s", argScreenshotQuality)
+			return
+		}
+	}
+
+	if err = o.Validate(); err == nil {
+		screenshotOpts = &o
+	}
+
+	return
+}
+
 func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
 		// parse requests
 		site := r.FormValue(argSite)
 		reportType := r.FormValue(argType)
 		asyncReport := r.FormValue(argAsync)
+		lang := r.FormValue(argLang)
+		outputFormat := r.FormValue(argFormat)
 
 		if site == "" {
 			sendResponse(http.StatusBadRequest, false, "invalid website url", nil, rw)
 			return
 		}
 
+		if lang != "" && !parser.IsSupportedLanguage(lang) {
+			sendResponse(http.StatusBadRequest, false, "unsupported report language", nil, rw)
+			return
+		}
+
+		pdfOpts, err := requestPDFOptions(opts, r)
+		if err != nil {
+			sendResponse(http.StatusBadRequest, false, err, nil, rw)
+			return
+		}
+
+		screenshotOpts, err := requestScreenshotOptions(opts, r)
+		if err != nil {
+			sendResponse(http.StatusBadRequest, false, err, nil, rw)
+			return
+		}
+
 		switch strings.ToLower(reportType) {
 		case "", typeJSON:
 			if disableJSON {
@@ -331,6 +475,69 @@ func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 				sendResponse(http.StatusBadRequest, false, "pdf report is disabled", nil, rw)
 				return
 			}
+		case typeCSV:
+			if disableCSV {
+				sendResponse(http.StatusBadRequest, false, "csv report is disabled", nil, rw)
+				return
+			}
+		case typeMD:
+			if disableMD {
+				sendResponse(http.StatusBadRequest, false, "markdown report is disabled", nil, rw)
+				return
+			}
+		case typeDecl:
+			if disableDecl {
+				sendResponse(http.StatusBadRequest, false, "cookie declaration is disabled", nil, rw)
+				return
+			}
+
+			if outputFormat == "" {
+				outputFormat = parser.DeclarationFragment
+			}
+
+			switch outputFormat {
+			case parser.DeclarationFragment, parser.DeclarationPage, parser.DeclarationScript, parser.DeclarationJSON:
+			default:
+				sendResponse(http.StatusBadRequest, false, "invalid cookie declaration format", nil, rw)
+				return
+			}
+		case typeCMP:
+			if disableCMP {
+				sendResponse(http.StatusBadRequest, false, "consent-manager configuration is disabled", nil, rw)
+				return
+			}
+
+			if outputFormat == "" {
+				outputFormat = parser.ConsentGeneric
+			}
+
+			switch outputFormat {
+			case parser.ConsentGeneric, parser.ConsentKlaro:
+			default:
+				sendResponse(http.StatusBadRequest, false, "invalid consent-manager configuration format", nil, rw)
+				return
+			}
+		case typeInit:
+			if disableInit {
+				sendResponse(http.StatusBadRequest, false, "initiator graph is disabled", nil, rw)
+				return
+			}
+
+			if outputFormat == "" {
+				outputFormat = parser.InitiatorGraphJSON
+			}
+
+			switch outputFormat {
+			case parser.InitiatorGraphJSON, parser.InitiatorGraphDOT:
+			default:
+				sendResponse(http.StatusBadRequest, false, "invalid initiator graph format", nil, rw)
+				return
+			}
+		case typeHAR:
+			if disableHAR {
+				sendResponse(http.StatusBadRequest, false, "har output is disabled", nil, rw)
+				return
+			}
 		case typeEmail:
 			if disableEmail {
 				sendResponse(http.StatusBadGateway, false, "email report is disabled", nil, rw)
@@ -357,7 +564,7 @@ func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 				}
 
 				time.AfterFunc(myDelay, func() {
-					asyncEmailReport(opts, site, mailTo)
+					asyncEmailReport(opts, site, mailTo, lang, pdfOpts, screenshotOpts)
 				})
 				sendResponse(http.StatusOK, true, nil, nil, rw)
 				return
@@ -389,7 +596,16 @@ func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 			ChromeApp:         opts.ChromeApp,
 			DebuggerPort:      port,
 			Headless:          true,
-			Classifier:        opts.ClassifierHandler,
+			Classifier:        opts.ScanClassifier(),
+			ReviewQueue:       opts.ReviewHandler,
+			Lang:              lang,
+			Templates:         opts.Templates,
+			Branding:          opts.Branding,
+			PDF:               pdfOpts,
+			Screenshot:        screenshotOpts,
+			Resolver:          opts.Resolver,
+			FilterLists:       opts.FilterLists,
+			GeoIP:             opts.GeoIP,
 		})
 
 		if err = t.Start(); err != nil {
@@ -440,6 +656,81 @@ func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 			rw.Header().Set("Content-Type", contentTypePDF)
 			rw.WriteHeader(http.StatusOK)
 			_, _ = rw.Write(pdfBytes)
+		case typeCSV:
+			csvData, err := t.OutputCSV()
+			if err != nil {
+				sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+				return
+			}
+
+			rw.Header().Set("Content-Type", contentTypeCSV)
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(csvData))
+		case typeMD:
+			mdData, err := t.OutputMarkdown()
+			if err != nil {
+				sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+				return
+			}
+
+			rw.Header().Set("Content-Type", contentTypeMD)
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(mdData))
+		case typeDecl:
+			declData, err := t.OutputDeclaration(outputFormat)
+			if err != nil {
+				sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+				return
+			}
+
+			switch outputFormat {
+			case parser.DeclarationScript:
+				rw.Header().Set("Content-Type", contentTypeJS)
+			case parser.DeclarationJSON:
+				rw.Header().Set("Content-Type", contentTypeJSON)
+			default:
+				rw.Header().Set("Content-Type", contentTypeHTML)
+			}
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(declData))
+		case typeCMP:
+			cmpData, err := t.OutputConsentConfig(outputFormat)
+			if err != nil {
+				sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+				return
+			}
+
+			if outputFormat == parser.ConsentKlaro {
+				rw.Header().Set("Content-Type", contentTypeJS)
+			} else {
+				rw.Header().Set("Content-Type", contentTypeJSON)
+			}
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(cmpData))
+		case typeInit:
+			initData, err := t.OutputInitiatorGraph(outputFormat)
+			if err != nil {
+				sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+				return
+			}
+
+			if outputFormat == parser.InitiatorGraphDOT {
+				rw.Header().Set("Content-Type", contentTypeDOT)
+			} else {
+				rw.Header().Set("Content-Type", contentTypeJSON)
+			}
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(initData))
+		case typeHAR:
+			harData, err := t.OutputHAR()
+			if err != nil {
+				sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+				return
+			}
+
+			rw.Header().Set("Content-Type", contentTypeJSON)
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(harData))
 		case typeEmail:
 			// send email
 			mailTo := r.FormValue(argTo)
@@ -471,7 +762,7 @@ func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 			m := gomail.NewMessage()
 			m.SetHeader("From", mailFrom)
 			m.SetAddressHeader("To", mailTo, mailTo)
-			m.SetHeader("Subject", mailSubject)
+			m.SetHeader("Subject", parser.Translate(lang, mailSubject))
 			m.SetBody("text/html", emailContent)
 			m.Attach(tempPDF, gomail.SetHeader(map[string][]string{"Content-Type": {contentTypePDF}}))
 
@@ -488,14 +779,32 @@ func analyzeFunc(opts *cmd.CommonOptions) http.HandlerFunc {
 }
 
 func handler(opts *cmd.CommonOptions) (err error) {
-	if disableJSON && disablePDF && disableHTML && disableEmail {
+	if disableJSON && disablePDF && disableHTML && disableEmail && disableCSV && disableMD && disableDecl && disableCMP &&
+		disableInit && disableHAR {
 		disableJSON = false
 	}
 
+	for operator, token := range managementTokens {
+		if operator == "" || token == "" {
+			err = errors.New("management token requires operator and token")
+			return
+		}
+	}
+
 	router := mux.NewRouter()
 	router.Use(jsonContentType)
 	router.HandleFunc("/", getVersionFunc(opts))
 	router.HandleFunc("/api/v1/analyze", analyzeFunc(opts)).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/api/v1/classifier", classifierAPI(opts, exportClassifierFunc)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/classifier", classifierAPI(opts, addClassifierFunc)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/classifier/{name}", classifierAPI(opts, showClassifierFunc)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/classifier/{name}", classifierAPI(opts, updateClassifierFunc)).Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/classifier/{name}", classifierAPI(opts, deleteClassifierFunc)).Methods(http.MethodDelete)
+	router.HandleFunc("/api/v1/classifier/{name}/translations/{lang}", classifierAPI(opts, translateClassifierFunc)).
+		Methods(http.MethodPut)
+	router.HandleFunc("/api/v1/review", reviewAPI(opts, listReviewFunc)).Methods(http.MethodGet)
+	router.HandleFunc("/api/v1/review/{name}/assign", reviewAPI(opts, assignReviewFunc)).Methods(http.MethodPost)
+	router.HandleFunc("/api/v1/review/{name}/promote", reviewAPI(opts, promoteReviewFunc(opts))).Methods(http.MethodPost)
 
 	if maxInflightScan > 0 {
 		inflightSem = semaphore.NewWeighted(int64(maxInflightScan))
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/parser"
+	"github.com/gorilla/mux"
+)
+
+const (
+	argStatus = "status"
+)
+
+func reviewAPI(opts *cmd.CommonOptions,
+	h func(q *parser.ReviewQueue, operator string, rw http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		operator, ok := authorizeManagement(rw, r)
+		if !ok {
+			return
+		}
+		if opts.ReviewHandler == nil {
+			sendResponse(http.StatusInternalServerError, false, "review queue not provided", nil, rw)
+			return
+		}
+
+		h(opts.ReviewHandler, operator, rw, r)
+	}
+}
+
+func listReviewFunc(q *parser.ReviewQueue, operator string, rw http.ResponseWriter, r *http.Request) {
+	cookies, err := q.List(r.FormValue(argStatus))
+	if err != nil {
+		sendResponse(http.StatusInternalServerError, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, cookies, rw)
+}
+
+func assignReviewFunc(q *parser.ReviewQueue, operator string, rw http.ResponseWriter, r *http.Request) {
+	if err := q.Assign(mux.Vars(r)[argName], r.FormValue(argCategory), r.FormValue(argDescription)); err != nil {
+		sendResponse(http.StatusBadRequest, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(http.StatusOK, true, nil, nil, rw)
+}
+
+func promoteReviewFunc(opts *cmd.CommonOptions) func(q *parser.ReviewQueue, operator string, rw http.ResponseWriter,
+	r *http.Request) {
+	return func(q *parser.ReviewQueue, operator string, rw http.ResponseWriter, r *http.Request) {
+		if opts.ClassifierHandler == nil {
+			sendResponse(http.StatusInternalServerError, false, "classifier database not provided", nil, rw)
+			return
+		}
+
+		if err := q.Promote(mux.Vars(r)[argName], opts.ClassifierHandler, operator); err != nil {
+			sendResponse(http.StatusBadRequest, false, err, nil, rw)
+			return
+		}
+
+		sendResponse(http.StatusOK, true, nil, nil, rw)
+	}
+}
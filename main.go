@@ -18,10 +18,15 @@ package main
 
 import (
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/CovenantSQL/CookieScanner/cmd"
+	"github.com/CovenantSQL/CookieScanner/cmd/classifier"
 	"github.com/CovenantSQL/CookieScanner/cmd/cli"
+	"github.com/CovenantSQL/CookieScanner/cmd/render"
+	"github.com/CovenantSQL/CookieScanner/cmd/replay"
+	"github.com/CovenantSQL/CookieScanner/cmd/review"
 	"github.com/CovenantSQL/CookieScanner/cmd/server"
 	"github.com/CovenantSQL/CookieScanner/cmd/version"
 	"github.com/CovenantSQL/CookieScanner/parser"
@@ -43,11 +48,93 @@ func init() {
 	app.Flag("wait", "wait duration after page load in scan").DurationVar(&options.WaitAfterPageLoad)
 	app.Flag("classifier", "classifier database for cookie report").
 		PreAction(loadCookieClassifier).StringVar(&options.ClassifierDB)
+	app.Flag("classifier-cache-size", "max cached classifier lookups, 0 disables the cache").
+		Default(strconv.Itoa(parser.DefaultClassifierCacheSize)).IntVar(&options.ClassifierCacheSize)
+	app.Flag("classifier-cache-ttl", "expiration of cached classifier lookups").
+		Default(parser.DefaultClassifierCacheTTL.String()).DurationVar(&options.ClassifierCacheTTL)
+	app.Flag("classifier-snapshot", "local classifier snapshot used by scans instead of the remote classifier").
+		PreAction(loadClassifierSnapshot).StringVar(&options.ClassifierSnapshot)
+	app.Flag("review-queue", "local review queue database for unclassified cookies").
+		PreAction(loadReviewQueue).StringVar(&options.ReviewQueue)
+	app.Flag("public-suffix-list", "public suffix list file replacing the bundled list, from publicsuffix.org").
+		PreAction(loadPublicSuffixList).ExistingFileVar(&options.PublicSuffixList)
+	app.Flag("cname-detection", "resolve first-party cookie hosts to detect trackers cloaked by cname aliases").
+		BoolVar(&options.CNAMEDetection)
+	app.Flag("dns-server", "dns server address for cname detection, system name server if not provided").
+		StringVar(&options.DNSServer)
+	app.Flag("dns-timeout", "timeout of a single dns query").Default(parser.DefaultDNSTimeout.String()).
+		DurationVar(&options.DNSTimeout)
+	app.Flag("tracker-list", "adblock plus filter list of trackers like EasyPrivacy, could be repeated").
+		ExistingFilesVar(&options.TrackerLists)
+	app.Flag("ad-list", "adblock plus filter list of advertising like EasyList, could be repeated").
+		ExistingFilesVar(&options.AdLists)
+	app.Flag("geoip-db", "maxmind format database like GeoLite2-Country or GeoLite2-ASN to locate servers, could be repeated").
+		ExistingFilesVar(&options.GeoIPDatabases)
+	app.Flag("template-dir", "directory with report/email template overrides").
+		PreAction(loadTemplates).ExistingDirVar(&options.TemplateDir)
+	app.Flag("brand-name", "company name shown in reports and emails").StringVar(&options.BrandingOptions.CompanyName)
+	app.Flag("brand-website", "company website linked from reports and emails").StringVar(&options.BrandingOptions.Website)
+	app.Flag("brand-logo", "logo url or image file of reports and emails").StringVar(&options.BrandingOptions.Logo)
+	app.Flag("brand-primary-color", "primary color of reports").StringVar(&options.BrandingOptions.PrimaryColor)
+	app.Flag("brand-accent-color", "accent (link) color of reports").StringVar(&options.BrandingOptions.AccentColor)
+	app.Flag("brand-contact-email", "contact email shown in reports and emails").StringVar(&options.BrandingOptions.ContactEmail)
+	app.Flag("brand-contact-phone", "contact phone shown in reports and emails").StringVar(&options.BrandingOptions.ContactPhone)
+	app.Flag("brand-contact-address", "contact address shown in reports and emails").StringVar(&options.BrandingOptions.ContactAddress)
+	app.Flag("pdf-paper", "pdf paper size, letter/legal/tabloid/a3/a4/a5 or <width>x<height>[in|cm|mm]").
+		StringVar(&options.PDF.Paper)
+	app.Flag("pdf-landscape", "print pdf in landscape mode").BoolVar(&options.PDF.Landscape)
+	app.Flag("pdf-margin", "pdf margins as 1, 2 or 4 lengths like css, e.g. \"10mm\" or \"0.4in 0.6in\"").
+		StringVar(&options.PDF.Margin)
+	app.Flag("pdf-scale", "pdf scale between 0.1 and 2").Float64Var(&options.PDF.Scale)
+	app.Flag("pdf-header-footer", "print scan metadata and page numbers in pdf header and footer").
+		BoolVar(&options.PDF.HeaderFooter)
+	app.Flag("pdf-header", "pdf header html template").StringVar(&options.PDF.HeaderTemplate)
+	app.Flag("pdf-footer", "pdf footer html template").StringVar(&options.PDF.FooterTemplate)
+	app.Flag("screenshot-full-page", "capture the full page instead of the viewport").
+		BoolVar(&options.Screenshot.FullPage)
+	app.Flag("screenshot-banner", "capture the detected consent banner").BoolVar(&options.Screenshot.Banner)
+	app.Flag("screenshot-format", "screenshot image format").Default(parser.ScreenshotPNG).
+		EnumVar(&options.Screenshot.Format, parser.ScreenshotFormats()...)
+	app.Flag("screenshot-quality", "jpeg/webp screenshot quality from 0 to 100").IntVar(&options.Screenshot.Quality)
+	app.Flag("screenshot-dir", "write screenshots to directory and refer them from report").
+		StringVar(&options.Screenshot.Dir)
+	app.Flag("screenshot-inline", "embed screenshots in report as base64").Default("true").
+		BoolVar(&options.Screenshot.Inline)
 	app.Flag("log-level", "set log level").PreAction(setLogLevel).StringVar(&logLevel)
 
+	app.PreAction(func(context *kingpin.ParseContext) (err error) {
+		parser.SetClassifierCache(options.ClassifierCacheSize, options.ClassifierCacheTTL)
+		if options.Branding, err = parser.NewBranding(options.BrandingOptions); err != nil {
+			return
+		}
+		if options.CNAMEDetection {
+			if options.Resolver, err = parser.NewDNSResolver(options.DNSServer, options.DNSTimeout); err != nil {
+				logrus.WithError(err).Warning("cname detection disabled")
+				options.Resolver, err = nil, nil
+			}
+		}
+		if err = loadFilterLists(); err != nil {
+			return
+		}
+		if len(options.GeoIPDatabases) > 0 {
+			if options.GeoIP, err = parser.LoadGeoIP(options.GeoIPDatabases...); err != nil {
+				return
+			}
+		}
+		if err = options.PDF.Validate(); err != nil {
+			return
+		}
+		err = options.Screenshot.Validate()
+		return
+	})
+
 	cli.RegisterCommand(app, &options)
 	version.RegisterCommand(app, &options)
 	server.RegisterCommand(app, &options)
+	classifier.RegisterCommand(app, &options)
+	review.RegisterCommand(app, &options)
+	render.RegisterCommand(app, &options)
+	replay.RegisterCommand(app, &options)
 }
 
 func loadCookieClassifier(context *kingpin.ParseContext) (err error) {
@@ -61,6 +148,85 @@ func loadCookieClassifier(context *kingpin.ParseContext) (err error) {
 	return
 }
 
+func loadClassifierSnapshot(context *kingpin.ParseContext) (err error) {
+	if options.ClassifierSnapshot == "" {
+		return
+	}
+
+	// snapshot may not be synced yet, it is loaded once created by sync
+	if _, statErr := os.Stat(options.ClassifierSnapshot); os.IsNotExist(statErr) {
+		logrus.WithField("snapshot", options.ClassifierSnapshot).Warning("classifier snapshot not found")
+	}
+
+	// load local classifier snapshot, the snapshot is reopened when replaced by sync
+	options.SnapshotHandler = parser.NewClassifierSnapshot(options.ClassifierSnapshot)
+
+	return
+}
+
+func loadReviewQueue(context *kingpin.ParseContext) (err error) {
+	if options.ReviewQueue == "" {
+		return
+	}
+
+	// load unclassified cookie review queue
+	options.ReviewHandler, err = parser.NewReviewQueue(options.ReviewQueue)
+
+	return
+}
+
+func loadPublicSuffixList(context *kingpin.ParseContext) (err error) {
+	if options.PublicSuffixList == "" {
+		return
+	}
+
+	// replace the bundled public suffix list
+	var l *parser.PublicSuffixList
+	if l, err = parser.LoadPublicSuffixList(options.PublicSuffixList); err != nil {
+		return
+	}
+
+	parser.SetPublicSuffixList(l)
+	logrus.WithField("rules", l.Len()).Debug("public suffix list loaded")
+
+	return
+}
+
+func loadFilterLists() (err error) {
+	if len(options.TrackerLists) == 0 && len(options.AdLists) == 0 {
+		return
+	}
+
+	options.FilterLists = parser.NewFilterLists()
+
+	// tracker lists take precedence over advertising lists
+	for _, f := range options.TrackerLists {
+		if err = options.FilterLists.Load(f, parser.FilterKindTracker); err != nil {
+			return
+		}
+	}
+	for _, f := range options.AdLists {
+		if err = options.FilterLists.Load(f, parser.FilterKindAd); err != nil {
+			return
+		}
+	}
+
+	logrus.WithField("rules", options.FilterLists.Len()).Debug("filter lists loaded")
+
+	return
+}
+
+func loadTemplates(context *kingpin.ParseContext) (err error) {
+	if options.TemplateDir == "" {
+		return
+	}
+
+	// load report and email template overrides
+	options.Templates, err = parser.LoadTemplates(options.TemplateDir)
+
+	return
+}
+
 func setLogLevel(context *kingpin.ParseContext) (err error) {
 	if logLevel != "" {
 		var lvl logrus.Level
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/base64"
+	"html/template"
+)
+
+// Report assets are embedded so that html and pdf reports render identically
+// without network access, the stylesheet only covers the utility classes used
+// by reportTemplate (a subset of bootstrap 4.3.1, MIT licensed). The default logo
+// is the product name set as text, --brand-logo replaces it with an image file.
+
+const reportLogoSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="360" height="64" viewBox="0 0 360 64">
+<text x="0" y="44" font-family="Helvetica,Arial,sans-serif" font-size="32" font-weight="700" fill="#343a40">GDPRExpert</text>
+</svg>`
+
+const reportStylesheet = `*,::after,::before{box-sizing:border-box}
+html{font-family:sans-serif;line-height:1.15;-webkit-text-size-adjust:100%}
+body{margin:0;font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,"Helvetica Neue",Arial,"Noto Sans",sans-serif;font-size:1rem;font-weight:400;line-height:1.5;color:#212529;text-align:left;background-color:#fff}
+section{display:block}
+h2,h3{margin-top:0;margin-bottom:.5rem;font-weight:500;line-height:1.2}
+h2{font-size:2rem}
+h3{font-size:1.75rem}
+p,ul{margin-top:0;margin-bottom:1rem}
+strong{font-weight:bolder}
+small,.small{font-size:80%;font-weight:400}
+a{color:#007bff;text-decoration:none;background-color:transparent}
+img{vertical-align:middle;border-style:none}
+table{border-collapse:collapse}
+th{text-align:inherit}
+.container{width:100%;padding-right:15px;padding-left:15px;margin-right:auto;margin-left:auto}
+@media (min-width:576px){.container{max-width:540px}}
+@media (min-width:768px){.container{max-width:720px}}
+@media (min-width:992px){.container{max-width:960px}}
+@media (min-width:1200px){.container{max-width:1140px}}
+.row{display:flex;flex-wrap:wrap;margin-right:-15px;margin-left:-15px}
+.col-6{position:relative;width:100%;padding-right:15px;padding-left:15px;flex:0 0 50%;max-width:50%}
+.list-unstyled{padding-left:0;list-style:none}
+.img-fluid{max-width:100%;height:auto}
+.img-thumbnail{padding:.25rem;background-color:#fff;border:1px solid #dee2e6;border-radius:.25rem;max-width:100%;height:auto}
+.table{width:100%;margin-bottom:1rem;color:#212529}
+.table td,.table th{padding:.75rem;vertical-align:top;border-top:1px solid #dee2e6}
+.table thead th{vertical-align:bottom;border-bottom:2px solid #dee2e6}
+.border-top{border-top:1px solid #dee2e6!important}
+.border-top-0,.table .border-top-0{border-top:0!important}
+.bg-light{background-color:#f8f9fa!important}
+.d-block{display:block!important}
+.w-25{width:25%!important}
+.border-0{border:0!important}
+.image{vertical-align:middle;border-style:none}
+.mt-3{margin-top:1rem!important}
+.mt-5{margin-top:3rem!important}
+.mb-1{margin-bottom:.25rem!important}
+.mb-3{margin-bottom:1rem!important}
+.mb-5{margin-bottom:3rem!important}
+.mr-1{margin-right:.25rem!important}
+.mx-2{margin-right:.5rem!important;margin-left:.5rem!important}
+.pt-0{padding-top:0!important}
+.pl-0{padding-left:0!important}
+.pl-3{padding-left:1rem!important}
+.mr-3{margin-right:1rem!important}
+.pt-3{padding-top:1rem!important}
+.text-right{text-align:right!important}
+.text-nowrap{white-space:nowrap!important}
+.text-uppercase{text-transform:uppercase!important}
+.text-muted{color:#6c757d!important}
+.ml-1{margin-left:.25rem!important}
+.text-danger{color:#dc3545!important}
+.initiator-tree{list-style:none;margin:0;padding-left:1.25rem;border-left:1px dotted #ced4da;word-break:break-all}
+.initiator-tree summary{cursor:pointer}
+.timeline-row{display:flex;align-items:center;border-bottom:1px solid #f1f3f5;page-break-inside:avoid}
+.timeline-label{width:30%;overflow:hidden;text-overflow:ellipsis;white-space:nowrap;padding-right:.5rem}
+.timeline-track{position:relative;flex:1;height:1.5rem}
+.timeline-time{width:5rem;text-align:right}
+.timeline-bar{position:absolute;top:.375rem;height:.75rem;width:1.5%;min-width:6px;border-radius:2px;-webkit-print-color-adjust:exact}
+.timeline-line{position:absolute;top:0;bottom:0;border-left:1px dashed #adb5bd}
+.timeline-marker{position:absolute;bottom:0;white-space:nowrap;padding-left:.25rem;border-left:1px dashed #adb5bd}
+.timeline-legend{display:inline-block;width:.75rem;height:.75rem;margin:0 .25rem 0 .75rem;vertical-align:middle;border-radius:2px;-webkit-print-color-adjust:exact}
+@media print{*,::after,::before{text-shadow:none!important;box-shadow:none!important}tr,img{page-break-inside:avoid}thead{display:table-header-group}.container{min-width:992px!important}.d-print-none{display:none!important}}
+`
+
+var (
+	reportLogo = template.URL("data:image/svg+xml;base64," +
+		base64.StdEncoding.EncodeToString([]byte(reportLogoSVG)))
+
+	// emailAssets are the hosted images of emails, most mail clients block data uri images.
+	emailAssets = map[string]template.URL{
+		"background": "https://cdn.jsdelivr.net/gh/CovenantLabs/assets@c59ad83/gdprexpert/bg_small.png",
+		"logo":       "https://cdn.jsdelivr.net/gh/CovenantLabs/assets@a92103d/gdprexpert/logo_white.png",
+		"chat":       "https://cdn.jsdelivr.net/gh/CovenantLabs/assets/gdprexpert/crisp_logo.png",
+	}
+)
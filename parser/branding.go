@@ -0,0 +1,196 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/base64"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]{3,20})$`)
+)
+
+// Branding contains the white-labelling options of html reports and emails,
+// empty fields keep the built-in gdprexpert.io look.
+type Branding struct {
+	CompanyName    string `json:"company_name,omitempty"`
+	Website        string `json:"website,omitempty"`
+	Logo           string `json:"logo,omitempty"`
+	PrimaryColor   string `json:"primary_color,omitempty"`
+	AccentColor    string `json:"accent_color,omitempty"`
+	ContactEmail   string `json:"contact_email,omitempty"`
+	ContactPhone   string `json:"contact_phone,omitempty"`
+	ContactAddress string `json:"contact_address,omitempty"`
+
+	logoURL template.URL
+}
+
+// NewBranding validates the branding options, a logo referring to a local file is inlined
+// as data uri to keep the reports self-contained.
+func NewBranding(b Branding) (branding *Branding, err error) {
+	for _, color := range []string{b.PrimaryColor, b.AccentColor} {
+		if color != "" && !colorPattern.MatchString(color) {
+			err = errors.Errorf("invalid color: %s", color)
+			return
+		}
+	}
+
+	if b.Website != "" && !isHTTPURL(b.Website) {
+		err = errors.Errorf("invalid website url: %s", b.Website)
+		return
+	}
+
+	switch {
+	case b.Logo == "":
+	case isHTTPURL(b.Logo):
+		b.logoURL = template.URL(b.Logo)
+	default:
+		var logoData []byte
+		if logoData, err = ioutil.ReadFile(b.Logo); err != nil {
+			err = errors.Wrapf(err, "read logo %s failed", b.Logo)
+			return
+		}
+
+		mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(b.Logo)))
+		if mimeType == "" {
+			mimeType = http.DetectContentType(logoData)
+		}
+		if !strings.HasPrefix(mimeType, "image/") {
+			err = errors.Errorf("logo %s is not an image: %s", b.Logo, mimeType)
+			return
+		}
+		if idx := strings.Index(mimeType, ";"); idx >= 0 {
+			mimeType = mimeType[:idx]
+		}
+
+		b.logoURL = template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(logoData))
+	}
+
+	branding = &b
+	return
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// IsCustom returns whether any white-labelling option is set.
+func (b *Branding) IsCustom() bool {
+	return b != nil && (b.CompanyName != "" || b.Website != "" || b.Logo != "" ||
+		b.PrimaryColor != "" || b.AccentColor != "" || b.HasContact())
+}
+
+// HasContact returns whether any contact detail is set.
+func (b *Branding) HasContact() bool {
+	return b != nil && (b.ContactEmail != "" || b.ContactPhone != "" || b.ContactAddress != "")
+}
+
+// LogoURL returns the logo url or data uri, empty for the built-in logo.
+func (b *Branding) LogoURL() template.URL {
+	if b == nil {
+		return ""
+	}
+	return b.logoURL
+}
+
+// Stylesheet returns the css overrides for the branding colors.
+func (b *Branding) Stylesheet() template.CSS {
+	if b == nil {
+		return ""
+	}
+
+	var css strings.Builder
+	if b.PrimaryColor != "" {
+		css.WriteString("h2,h3,.brand-primary{color:" + b.PrimaryColor + "}")
+		css.WriteString(".table thead th{border-bottom-color:" + b.PrimaryColor + "}")
+	}
+	if b.AccentColor != "" {
+		css.WriteString("a,.brand-accent{color:" + b.AccentColor + "}")
+	}
+	return template.CSS(css.String())
+}
+
+// Templates contains the html report and email templates used to render a task.
+type Templates struct {
+	report *template.Template
+	email  *template.Template
+}
+
+// LoadTemplates loads template overrides from dir, files in dir/report override the named blocks
+// ("header", "summary", "cookie_row", "footer") of the html report, files in dir/email override
+// the named blocks ("header", "summary", "contact", "footer") of the email.
+func LoadTemplates(dir string) (templates *Templates, err error) {
+	if _, err = os.Stat(dir); err != nil {
+		err = errors.Wrapf(err, "stat template dir %s failed", dir)
+		return
+	}
+
+	templates = &Templates{}
+	if templates.report, err = overrideTemplate(reportTemplate, filepath.Join(dir, "report")); err != nil {
+		return
+	}
+	if templates.email, err = overrideTemplate(emailTemplate, filepath.Join(dir, "email")); err != nil {
+		return
+	}
+
+	return
+}
+
+func overrideTemplate(base *template.Template, dir string) (tpl *template.Template, err error) {
+	var files []string
+	if files, err = filepath.Glob(filepath.Join(dir, "*.html")); err != nil {
+		err = errors.Wrapf(err, "list templates in %s failed", dir)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	if tpl, err = base.Clone(); err != nil {
+		err = errors.Wrap(err, "clone template failed")
+		return
+	}
+	if tpl, err = tpl.ParseFiles(files...); err != nil {
+		err = errors.Wrapf(err, "parse templates in %s failed", dir)
+	}
+
+	return
+}
+
+func (t *Templates) reportTemplate() *template.Template {
+	if t == nil || t.report == nil {
+		return reportTemplate
+	}
+	return t.report
+}
+
+func (t *Templates) emailTemplate() *template.Template {
+	if t == nil || t.email == nil {
+		return emailTemplate
+	}
+	return t.email
+}
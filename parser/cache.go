@@ -0,0 +1,116 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultClassifierCacheSize = 10000
+	DefaultClassifierCacheTTL  = 10 * time.Minute
+)
+
+type classifierCacheKey struct {
+	classifier *Classifier
+	lang       string
+	name       string
+}
+
+type classifierCacheEntry struct {
+	// def is nil for cookies not found in classifier database
+	def      *CookieDefinition
+	expireAt time.Time
+}
+
+var (
+	classifierCacheLock sync.RWMutex
+	classifierCache     *lru.Cache
+	classifierCacheTTL  = DefaultClassifierCacheTTL
+)
+
+func init() {
+	classifierCache, _ = lru.New(DefaultClassifierCacheSize)
+}
+
+// SetClassifierCache configures the process-wide classifier lookup cache, zero size or ttl disables the cache.
+func SetClassifierCache(size int, ttl time.Duration) {
+	classifierCacheLock.Lock()
+	defer classifierCacheLock.Unlock()
+
+	classifierCacheTTL = ttl
+
+	if size <= 0 || ttl <= 0 {
+		classifierCache = nil
+		return
+	}
+
+	var err error
+	if classifierCache, err = lru.New(size); err != nil {
+		logrus.WithError(err).Warning("create classifier cache failed")
+	}
+}
+
+func getCachedCookie(key classifierCacheKey) (def *CookieDefinition, ok bool) {
+	classifierCacheLock.RLock()
+	defer classifierCacheLock.RUnlock()
+
+	if classifierCache == nil {
+		return
+	}
+
+	v, ok := classifierCache.Get(key)
+	if !ok {
+		return
+	}
+
+	entry := v.(*classifierCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		classifierCache.Remove(key)
+		return nil, false
+	}
+
+	return entry.def, true
+}
+
+func setCachedCookie(key classifierCacheKey, def *CookieDefinition) {
+	classifierCacheLock.RLock()
+	defer classifierCacheLock.RUnlock()
+
+	if classifierCache == nil {
+		return
+	}
+
+	classifierCache.Add(key, &classifierCacheEntry{
+		def:      def,
+		expireAt: time.Now().Add(classifierCacheTTL),
+	})
+}
+
+// purgeClassifierCache drops all cached lookups after classifier modifications.
+func purgeClassifierCache() {
+	classifierCacheLock.RLock()
+	defer classifierCacheLock.RUnlock()
+
+	if classifierCache != nil {
+		classifierCache.Purge()
+	}
+}
@@ -20,13 +20,20 @@ import (
 	"database/sql"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/CovenantSQL/CovenantSQL/client"
 	"github.com/pkg/errors"
 )
 
 type Classifier struct {
-	db *sql.DB
+	db          *sql.DB
+	versionOnce sync.Once
+	version     string
+	// tablesReady is set once the audit and translation tables are created
+	tablesLock  sync.Mutex
+	tablesReady bool
 }
 
 func NewClassifier(dsn string) (c *Classifier, err error) {
@@ -58,6 +65,10 @@ func NewClassifier(dsn string) (c *Classifier, err error) {
 	return
 }
 
+func (c *Classifier) Close() error {
+	return c.db.Close()
+}
+
 func (c *Classifier) GetCookieDetail(name string) (cookieType string, cookieDesc string, err error) {
 	err = c.db.QueryRow("SELECT cookie_type, cookie_desc FROM cookies WHERE cookie_name = ? LIMIT 1", name).
 		Scan(&cookieType, &cookieDesc)
@@ -66,3 +77,483 @@ func (c *Classifier) GetCookieDetail(name string) (cookieType string, cookieDesc
 	}
 	return
 }
+
+// GetLocalizedCookieDetail returns the cookie detail with description in lang, falls back to english description.
+func (c *Classifier) GetLocalizedCookieDetail(name string, lang string) (cookieType string, cookieDesc string, err error) {
+	details, err := c.GetCookieDetails([]string{name}, lang)
+	if def := details[name]; def != nil {
+		cookieType, cookieDesc = def.Category, def.Description
+	}
+	return
+}
+
+const (
+	// maxBatchLookup limits the query parameters of a batch lookup below the sqlite variable limit.
+	maxBatchLookup = 500
+)
+
+// GetCookieDetails returns the definitions of cookies with description in lang in batch,
+// cookies not found in classifier database are omitted from result.
+func (c *Classifier) GetCookieDetails(names []string, lang string) (details map[string]*CookieDefinition, err error) {
+	lang = NormalizeLanguage(lang)
+	details = map[string]*CookieDefinition{}
+
+	var (
+		missing []string
+		seen    = map[string]bool{}
+	)
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if def, ok := getCachedCookie(classifierCacheKey{classifier: c, lang: lang, name: name}); ok {
+			if def != nil {
+				details[name] = def
+			}
+			continue
+		}
+
+		missing = append(missing, name)
+	}
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxBatchLookup {
+			batch = batch[:maxBatchLookup]
+		}
+		missing = missing[len(batch):]
+
+		var found map[string]*CookieDefinition
+		if found, err = c.queryCookieDetails(batch, lang); err != nil {
+			return
+		}
+
+		for _, name := range batch {
+			def := found[name]
+			if def != nil {
+				details[name] = def
+			}
+			setCachedCookie(classifierCacheKey{classifier: c, lang: lang, name: name}, def)
+		}
+	}
+
+	return
+}
+
+func (c *Classifier) queryCookieDetails(names []string, lang string) (found map[string]*CookieDefinition, err error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	nameArgs := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		nameArgs = append(nameArgs, name)
+	}
+
+	var rows *sql.Rows
+
+	if lang != DefaultLanguage {
+		rows, err = c.db.Query(`SELECT c.cookie_name, c.cookie_type, c.cookie_desc, COALESCE(t.cookie_desc, '')
+FROM cookies c LEFT JOIN cookie_translations t ON t.cookie_name = c.cookie_name AND t.lang = ?
+WHERE c.cookie_name IN (`+placeholders+`)`, append([]interface{}{lang}, nameArgs...)...)
+	}
+
+	if lang == DefaultLanguage || err != nil {
+		// translation table is optional, fall back to english descriptions
+		rows, err = c.db.Query(`SELECT cookie_name, cookie_type, cookie_desc, ''
+FROM cookies WHERE cookie_name IN (`+placeholders+`)`, nameArgs...)
+	}
+
+	if err != nil {
+		err = errors.Wrap(err, "batch query cookies failed")
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	found = map[string]*CookieDefinition{}
+
+	for rows.Next() {
+		var (
+			def       = new(CookieDefinition)
+			localDesc string
+		)
+
+		if err = rows.Scan(&def.Name, &def.Category, &def.Description, &localDesc); err != nil {
+			err = errors.Wrap(err, "scan cookie definition failed")
+			return
+		}
+
+		if localDesc != "" {
+			def.Description = localDesc
+		}
+
+		// keep the first definition like the single lookup
+		if _, ok := found[def.Name]; !ok {
+			found[def.Name] = def
+		}
+	}
+
+	err = errors.Wrap(rows.Err(), "iterate cookies failed")
+
+	return
+}
+
+const (
+	ClassifierActionAdd       = "add"
+	ClassifierActionUpdate    = "update"
+	ClassifierActionDelete    = "delete"
+	ClassifierActionTranslate = "translate"
+)
+
+// CookieDefinition is a single cookie classification stored in classifier database.
+type CookieDefinition struct {
+	Name         string            `json:"name"`
+	Category     string            `json:"category"`
+	Description  string            `json:"description"`
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// ClassifierChange is an audit record of a classifier definition modification.
+type ClassifierChange struct {
+	Name           string    `json:"name"`
+	Action         string    `json:"action"`
+	Lang           string    `json:"lang,omitempty"`
+	Operator       string    `json:"operator"`
+	OldCategory    string    `json:"old_category"`
+	OldDescription string    `json:"old_description"`
+	NewCategory    string    `json:"new_category"`
+	NewDescription string    `json:"new_description"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+func (c *Classifier) GetCookie(name string) (def *CookieDefinition, err error) {
+	def = &CookieDefinition{Name: name}
+	err = c.db.QueryRow("SELECT cookie_type, cookie_desc FROM cookies WHERE cookie_name = ? LIMIT 1", name).
+		Scan(&def.Category, &def.Description)
+	if err == sql.ErrNoRows {
+		def = nil
+		err = nil
+	} else if err != nil {
+		def = nil
+		err = errors.Wrapf(err, "query cookie %s failed", name)
+	} else {
+		def.Translations = c.getTranslations(name)[name]
+	}
+	return
+}
+
+// getTranslations returns the description translations of cookie, all cookies if name is empty.
+func (c *Classifier) getTranslations(name string) (r map[string]map[string]string) {
+	r = map[string]map[string]string{}
+
+	query := "SELECT cookie_name, lang, cookie_desc FROM cookie_translations"
+	var args []interface{}
+	if name != "" {
+		query += " WHERE cookie_name = ?"
+		args = append(args, name)
+	}
+
+	// translation table is optional, errors are treated as no translations
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var cookieName, lang, desc string
+		if rows.Scan(&cookieName, &lang, &desc) != nil {
+			continue
+		}
+		if r[cookieName] == nil {
+			r[cookieName] = map[string]string{}
+		}
+		r[cookieName][lang] = desc
+	}
+
+	return
+}
+
+func (c *Classifier) ListCookies() (defs []*CookieDefinition, err error) {
+	rows, err := c.db.Query("SELECT cookie_name, cookie_type, cookie_desc FROM cookies ORDER BY cookie_name")
+	if err != nil {
+		err = errors.Wrap(err, "query cookies failed")
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		def := new(CookieDefinition)
+		if err = rows.Scan(&def.Name, &def.Category, &def.Description); err != nil {
+			err = errors.Wrap(err, "scan cookie definition failed")
+			return
+		}
+		defs = append(defs, def)
+	}
+
+	if err = rows.Err(); err != nil {
+		err = errors.Wrap(err, "iterate cookies failed")
+		return
+	}
+
+	translations := c.getTranslations("")
+	for _, def := range defs {
+		def.Translations = translations[def.Name]
+	}
+
+	return
+}
+
+func (c *Classifier) AddCookie(def *CookieDefinition, operator string) (err error) {
+	if def.Name == "" {
+		return errors.New("cookie name is required")
+	}
+	if def.Category, err = NormalizeCategory(def.Category); err != nil {
+		return
+	}
+
+	old, err := c.GetCookie(def.Name)
+	if err != nil {
+		return
+	}
+	if old != nil {
+		return errors.Errorf("cookie %s already exists", def.Name)
+	}
+
+	return c.modify(&ClassifierChange{
+		Name:           def.Name,
+		Action:         ClassifierActionAdd,
+		Operator:       operator,
+		NewCategory:    def.Category,
+		NewDescription: def.Description,
+	}, func(tx *sql.Tx) (err error) {
+		_, err = tx.Exec("INSERT INTO cookies (cookie_name, cookie_type, cookie_desc) VALUES (?, ?, ?)",
+			def.Name, def.Category, def.Description)
+		return
+	})
+}
+
+// UpdateCookie updates an existing definition, empty category or description keeps the stored value.
+func (c *Classifier) UpdateCookie(def *CookieDefinition, operator string) (err error) {
+	if def.Category == "" && def.Description == "" {
+		return errors.Errorf("nothing to update of cookie %s, category or description is required", def.Name)
+	}
+
+	old, err := c.GetCookie(def.Name)
+	if err != nil {
+		return
+	}
+	if old == nil {
+		return errors.Errorf("cookie %s not found", def.Name)
+	}
+
+	newDef := *old
+	if def.Category != "" {
+		if newDef.Category, err = NormalizeCategory(def.Category); err != nil {
+			return
+		}
+	}
+	if def.Description != "" {
+		newDef.Description = def.Description
+	}
+
+	return c.modify(&ClassifierChange{
+		Name:           def.Name,
+		Action:         ClassifierActionUpdate,
+		Operator:       operator,
+		OldCategory:    old.Category,
+		OldDescription: old.Description,
+		NewCategory:    newDef.Category,
+		NewDescription: newDef.Description,
+	}, func(tx *sql.Tx) (err error) {
+		_, err = tx.Exec("UPDATE cookies SET cookie_type = ?, cookie_desc = ? WHERE cookie_name = ?",
+			newDef.Category, newDef.Description, newDef.Name)
+		return
+	})
+}
+
+func (c *Classifier) DeleteCookie(name string, operator string) (err error) {
+	old, err := c.GetCookie(name)
+	if err != nil {
+		return
+	}
+	if old == nil {
+		return errors.Errorf("cookie %s not found", name)
+	}
+
+	return c.modify(&ClassifierChange{
+		Name:           name,
+		Action:         ClassifierActionDelete,
+		Operator:       operator,
+		OldCategory:    old.Category,
+		OldDescription: old.Description,
+	}, func(tx *sql.Tx) (err error) {
+		if _, err = tx.Exec("DELETE FROM cookies WHERE cookie_name = ?", name); err != nil {
+			return
+		}
+		_, err = tx.Exec("DELETE FROM cookie_translations WHERE cookie_name = ?", name)
+		return
+	})
+}
+
+// SetTranslation sets the description of cookie in a non-english language.
+func (c *Classifier) SetTranslation(name string, lang string, desc string, operator string) (err error) {
+	if lang = NormalizeLanguage(lang); lang == DefaultLanguage {
+		return errors.New("english description should be changed by update")
+	}
+
+	old, err := c.GetCookie(name)
+	if err != nil {
+		return
+	}
+	if old == nil {
+		return errors.Errorf("cookie %s not found", name)
+	}
+
+	return c.modify(&ClassifierChange{
+		Name:           name,
+		Action:         ClassifierActionTranslate,
+		Lang:           lang,
+		Operator:       operator,
+		OldCategory:    old.Category,
+		OldDescription: old.Translations[lang],
+		NewCategory:    old.Category,
+		NewDescription: desc,
+	}, func(tx *sql.Tx) (err error) {
+		if _, err = tx.Exec("DELETE FROM cookie_translations WHERE cookie_name = ? AND lang = ?", name, lang); err != nil {
+			return
+		}
+		_, err = tx.Exec("INSERT INTO cookie_translations (cookie_name, lang, cookie_desc) VALUES (?, ?, ?)",
+			name, lang, desc)
+		return
+	})
+}
+
+// History returns the audit records of the cookie definition, newest first.
+func (c *Classifier) History(name string) (changes []*ClassifierChange, err error) {
+	if err = c.ensureTables(); err != nil {
+		return
+	}
+
+	rows, err := c.db.Query(`SELECT cookie_name, action, lang, operator, old_type, old_desc, new_type, new_desc, changed_at
+FROM cookies_audit WHERE cookie_name = ? ORDER BY changed_at DESC`, name)
+	if err != nil {
+		err = errors.Wrap(err, "query cookie history failed")
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var (
+			change    = new(ClassifierChange)
+			changedAt int64
+		)
+		if err = rows.Scan(&change.Name, &change.Action, &change.Lang, &change.Operator,
+			&change.OldCategory, &change.OldDescription, &change.NewCategory, &change.NewDescription,
+			&changedAt); err != nil {
+			err = errors.Wrap(err, "scan cookie history failed")
+			return
+		}
+		change.ChangedAt = time.Unix(changedAt, 0).UTC()
+		changes = append(changes, change)
+	}
+
+	err = errors.Wrap(rows.Err(), "iterate cookie history failed")
+
+	return
+}
+
+func (c *Classifier) ensureTables() (err error) {
+	c.tablesLock.Lock()
+	defer c.tablesLock.Unlock()
+
+	if c.tablesReady {
+		return
+	}
+
+	if _, err = c.db.Exec(`CREATE TABLE IF NOT EXISTS cookies_audit (
+	cookie_name TEXT NOT NULL,
+	action TEXT NOT NULL,
+	lang TEXT NOT NULL DEFAULT '',
+	operator TEXT NOT NULL,
+	old_type TEXT NOT NULL DEFAULT '',
+	old_desc TEXT NOT NULL DEFAULT '',
+	new_type TEXT NOT NULL DEFAULT '',
+	new_desc TEXT NOT NULL DEFAULT '',
+	changed_at INTEGER NOT NULL
+)`); err != nil {
+		err = errors.Wrap(err, "create classifier audit table failed")
+		return
+	}
+
+	if _, err = c.db.Exec(`CREATE TABLE IF NOT EXISTS cookie_translations (
+	cookie_name TEXT NOT NULL,
+	lang TEXT NOT NULL,
+	cookie_desc TEXT NOT NULL,
+	PRIMARY KEY (cookie_name, lang)
+)`); err != nil {
+		err = errors.Wrap(err, "create classifier translation table failed")
+		return
+	}
+
+	c.tablesReady = true
+
+	return
+}
+
+// modify applies the definition change and records the audit entry in a single transaction.
+func (c *Classifier) modify(change *ClassifierChange, apply func(tx *sql.Tx) error) (err error) {
+	if change.Operator == "" {
+		return errors.New("operator is required to modify classifier")
+	}
+	if err = c.ensureTables(); err != nil {
+		return
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = errors.Wrap(err, "begin classifier transaction failed")
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = apply(tx); err != nil {
+		err = errors.Wrapf(err, "%s cookie %s failed", change.Action, change.Name)
+		return
+	}
+
+	if _, err = tx.Exec(`INSERT INTO cookies_audit
+(cookie_name, action, lang, operator, old_type, old_desc, new_type, new_desc, changed_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		change.Name, change.Action, change.Lang, change.Operator, change.OldCategory, change.OldDescription,
+		change.NewCategory, change.NewDescription, time.Now().Unix()); err != nil {
+		err = errors.Wrap(err, "record classifier change failed")
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = errors.Wrap(err, "commit classifier change failed")
+		return
+	}
+
+	purgeClassifierCache()
+
+	return
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testDir(t *testing.T) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "cookiescanner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup = func() {
+		_ = os.RemoveAll(dir)
+	}
+	return
+}
+
+func newTestClassifier(t *testing.T, dir string) *Classifier {
+	c, err := NewClassifier("sqlite://" + filepath.Join(dir, "cookies.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.db.Exec(`CREATE TABLE cookies (cookie_name TEXT PRIMARY KEY, cookie_type TEXT NOT NULL,
+cookie_desc TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestUpdateCookieRequiresChange(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+	c := newTestClassifier(t, dir)
+
+	if err := c.AddCookie(&CookieDefinition{Name: "_ga", Category: "Performance"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateCookie(&CookieDefinition{Name: "_ga"}, "alice"); err == nil {
+		t.Error("expected error of update without category and description")
+	}
+	if err := c.UpdateCookie(&CookieDefinition{Name: "_ga", Description: "client id"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := c.History("_ga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("audit records = %d, want add and update only", len(history))
+	}
+}
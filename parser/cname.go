@@ -0,0 +1,167 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cnameLookupWorkers is the max number of concurrent cname lookups of a scan.
+const cnameLookupWorkers = 8
+
+// cnameCloak is a first-party host aliased to a tracker domain.
+type cnameCloak struct {
+	chain   []string
+	tracker *trackerInfo
+}
+
+// matchCNAMECloak returns the tracker of the first cname target matching a known tracker, consent managers
+// and other strictly necessary services are not regarded as cloaking.
+func matchCNAMECloak(chain []string) *trackerInfo {
+	for _, target := range chain[1:] {
+		if tracker := matchTracker(target); tracker != nil && tracker.Category != CategoryStrictlyNecessary {
+			return tracker
+		}
+	}
+	return nil
+}
+
+// cookieHosts returns the first-party hosts setting or receiving the cookie.
+func cookieHosts(c *reportCookieRecord) (hosts []string) {
+	seen := map[string]bool{}
+	add := func(host string) {
+		host = strings.ToLower(host)
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err == nil {
+			add(u.Hostname())
+		}
+	}
+	if !strings.HasPrefix(c.Domain, ".") {
+		// host-only cookie
+		add(c.Domain)
+	}
+	for _, d := range c.Destinations {
+		if !d.ThirdParty {
+			add(d.Host)
+		}
+	}
+
+	return
+}
+
+// detectCNAMECloaking resolves first-party hosts of cookies and marks the cookies served by trackers behind cname
+// aliases as third-party, returns the number of cloaked cookies.
+func (t *Task) detectCNAMECloaking(records []*reportRecord) (cloaked int) {
+	if t.cfg.Resolver == nil {
+		return
+	}
+
+	hostSet := map[string]bool{}
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			if c.ThirdParty {
+				continue
+			}
+			for _, host := range cookieHosts(c) {
+				hostSet[host] = true
+			}
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		hosts  = make(chan string)
+		cloaks = map[string]*cnameCloak{}
+	)
+
+	for i := 0; i < cnameLookupWorkers && i < len(hostSet); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for host := range hosts {
+				chain, err := t.cfg.Resolver.LookupCNAME(host)
+				if err != nil {
+					logrus.WithError(err).WithField("host", host).Debug("resolve cname failed")
+					continue
+				}
+				if len(chain) < 2 {
+					continue
+				}
+				if tracker := matchCNAMECloak(chain); tracker != nil {
+					lock.Lock()
+					cloaks[host] = &cnameCloak{chain: chain, tracker: tracker}
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for host := range hostSet {
+		hosts <- host
+	}
+	close(hosts)
+
+	wg.Wait()
+
+	if len(cloaks) == 0 {
+		return
+	}
+
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			if c.ThirdParty {
+				continue
+			}
+
+			hosts := cookieHosts(c)
+			sort.Strings(hosts)
+
+			for _, host := range hosts {
+				if cloak, ok := cloaks[host]; ok {
+					c.CNAMECloaked = true
+					c.CNAMEChain = cloak.chain
+					c.CNAMETracker = cloak.tracker.Vendor
+					c.ThirdParty = true
+					c.Party = PartyThird
+					cloaked++
+					break
+				}
+			}
+
+			for _, d := range c.Destinations {
+				if _, ok := cloaks[d.Host]; ok {
+					d.ThirdParty = true
+				}
+			}
+		}
+	}
+
+	return
+}
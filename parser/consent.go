@@ -0,0 +1,380 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ConsentGeneric is the documented generic json consent-manager configuration.
+	ConsentGeneric = "generic"
+	// ConsentKlaro is the Klaro! javascript configuration.
+	ConsentKlaro = "klaro"
+
+	consentConfigVersion = 1
+
+	consentRuleScript   = "script"
+	consentRuleResource = "resource"
+
+	klaroRegexpPrefix = "klaro-regexp:"
+)
+
+var (
+	// cookie names ending with an id like _ga_XXXXXXXX or _hjSession_123456 are exported as prefix patterns
+	cookieIDSuffix   = regexp.MustCompile(`^(.+?[_\-.])([0-9A-Za-z]*[0-9][0-9A-Za-z]*)$`)
+	klaroRegexpValue = regexp.MustCompile(`"` + klaroRegexpPrefix + `((?:[^"\\]|\\.)*)"`)
+	serviceNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ConsentFormats returns the supported consent-manager configuration formats.
+func ConsentFormats() []string {
+	return []string{ConsentGeneric, ConsentKlaro}
+}
+
+type consentCookie struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Domain  string `json:"domain"`
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+}
+
+type consentRule struct {
+	Type    string   `json:"type"`
+	URL     string   `json:"url"`
+	Host    string   `json:"host"`
+	Cookies []string `json:"cookies"`
+}
+
+type consentService struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title"`
+	Description string           `json:"description,omitempty"`
+	Cookies     []*consentCookie `json:"cookies"`
+	Rules       []*consentRule   `json:"blocking_rules"`
+}
+
+type consentCategory struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Required bool              `json:"required"`
+	Services []*consentService `json:"services"`
+}
+
+type consentConfig struct {
+	Version           int                `json:"version"`
+	Lang              string             `json:"lang"`
+	Site              string             `json:"site"`
+	ScanTime          time.Time          `json:"scan_time"`
+	ClassifierVersion string             `json:"classifier_version,omitempty"`
+	Categories        []*consentCategory `json:"categories"`
+}
+
+// cookieNamePattern returns the regular expression matching cookie name and its id suffixed variants.
+func cookieNamePattern(name string) string {
+	if m := cookieIDSuffix.FindStringSubmatch(name); m != nil && len(m[2]) >= 4 {
+		return "^" + regexp.QuoteMeta(m[1])
+	}
+
+	return "^" + regexp.QuoteMeta(name) + "$"
+}
+
+func consentServiceName(title string) string {
+	return strings.Trim(serviceNameChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+}
+
+// stripURLQuery removes query and fragment of the url used by blocking rules.
+func stripURLQuery(rawURL string) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", ""
+	}
+
+	u.RawQuery, u.Fragment, u.User = "", "", nil
+	return u.String(), u.Hostname()
+}
+
+// consentBlockingRule returns the script or resource to be blocked until consent for a cookie set by http response,
+// cookies set by scripts carry no initiator and are only matched by name.
+func consentBlockingRule(siteHost string, c *reportCookieRecord) *consentRule {
+	switch {
+	case c.Initiator == "script" && c.Source != "":
+		if u, host := stripURLQuery(c.Source); u != "" {
+			return &consentRule{Type: consentRuleScript, URL: u, Host: host}
+		}
+	case c.URL != "" && strings.Contains(c.MimeType, "javascript"):
+		if u, host := stripURLQuery(c.URL); u != "" {
+			return &consentRule{Type: consentRuleScript, URL: u, Host: host}
+		}
+	case c.URL != "" && c.Initiator == "parser":
+		// images, iframes and other embedded resources of the page
+		if u, host := stripURLQuery(c.URL); u != "" && isThirdParty(siteHost, host) {
+			return &consentRule{Type: consentRuleResource, URL: u, Host: host}
+		}
+	}
+
+	return nil
+}
+
+func newConsentConfig(data *reportData) *consentConfig {
+	lang := data.Lang
+	cfg := &consentConfig{
+		Version:           consentConfigVersion,
+		Lang:              lang,
+		Site:              data.ScanURL,
+		ScanTime:          data.ScanTime,
+		ClassifierVersion: data.ClassifierVersion,
+	}
+
+	var siteHost string
+	if u, err := url.Parse(data.ScanURL); err == nil {
+		siteHost = u.Hostname()
+	}
+
+	var (
+		categories = map[string]*consentCategory{}
+		services   = map[string]*consentService{}
+		rules      = map[string]*consentRule{}
+		patterns   = map[string]bool{}
+	)
+
+	for _, record := range data.Records {
+		id, name := record.CanonicalCategory, Translate(lang, record.Category)
+		if record.Category == "" || id == "" {
+			id, name = CategoryIDUnclassified, Translate(lang, "Unclassified")
+		}
+
+		category, ok := categories[id]
+		if !ok {
+			category = &consentCategory{ID: id, Name: name, Required: record.Category == CategoryStrictlyNecessary}
+			categories[id] = category
+			cfg.Categories = append(cfg.Categories, category)
+		}
+
+		for _, c := range record.Cookies {
+			provider := strings.TrimPrefix(c.Domain, ".")
+			title := provider
+
+			for _, candidate := range []*trackerInfo{matchTrackerURL(c.Source), matchTrackerURL(c.URL), matchTracker(c.Domain)} {
+				if candidate != nil {
+					title = candidate.Vendor
+					break
+				}
+			}
+
+			serviceKey := id + "/" + consentServiceName(title)
+			service, ok := services[serviceKey]
+			if !ok {
+				service = &consentService{
+					Name:    consentServiceName(title),
+					Title:   title,
+					Cookies: []*consentCookie{},
+					Rules:   []*consentRule{},
+				}
+				services[serviceKey] = service
+				category.Services = append(category.Services, service)
+			}
+
+			if pattern := cookieNamePattern(c.Name); !patterns[serviceKey+"/"+pattern+"/"+c.Domain] {
+				patterns[serviceKey+"/"+pattern+"/"+c.Domain] = true
+				path := c.Path
+				if path == "" {
+					path = "/"
+				}
+				service.Cookies = append(service.Cookies, &consentCookie{
+					Name:    c.Name,
+					Pattern: pattern,
+					Domain:  c.Domain,
+					Path:    path,
+					Type:    c.Type,
+				})
+			}
+
+			if category.Required {
+				continue
+			}
+
+			if rule := consentBlockingRule(siteHost, c); rule != nil {
+				if existing, ok := rules[serviceKey+"/"+rule.URL]; ok {
+					existing.Cookies = append(existing.Cookies, c.Name)
+				} else {
+					rule.Cookies = []string{c.Name}
+					rules[serviceKey+"/"+rule.URL] = rule
+					service.Rules = append(service.Rules, rule)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(cfg.Categories, func(i, j int) bool {
+		return consentCategoryRank(cfg.Categories[i]) < consentCategoryRank(cfg.Categories[j])
+	})
+
+	for _, category := range cfg.Categories {
+		sort.SliceStable(category.Services, func(i, j int) bool {
+			return category.Services[i].Name < category.Services[j].Name
+		})
+	}
+
+	return cfg
+}
+
+func consentCategoryRank(c *consentCategory) int {
+	if c.ID == CategoryIDUnclassified {
+		return len(taxonomy) + 1
+	}
+	if cat := LookupCategory(c.ID); cat != nil {
+		return categoryRank(cat.Name)
+	}
+	return len(taxonomy)
+}
+
+type klaroService struct {
+	Name     string          `json:"name"`
+	Title    string          `json:"title"`
+	Purposes []string        `json:"purposes"`
+	Cookies  [][]interface{} `json:"cookies"`
+	Required bool            `json:"required"`
+	Default  bool            `json:"default"`
+	OptOut   bool            `json:"optOut"`
+	OnlyOnce bool            `json:"onlyOnce"`
+}
+
+type klaroConfig struct {
+	Version       int                               `json:"version"`
+	ElementID     string                            `json:"elementID"`
+	StorageMethod string                            `json:"storageMethod"`
+	CookieName    string                            `json:"cookieName"`
+	Default       bool                              `json:"default"`
+	MustConsent   bool                              `json:"mustConsent"`
+	AcceptAll     bool                              `json:"acceptAll"`
+	Lang          string                            `json:"lang"`
+	Translations  map[string]map[string]interface{} `json:"translations"`
+	Services      []*klaroService                   `json:"services"`
+}
+
+func outputAsKlaro(cfg *consentConfig) (str string, err error) {
+	kc := &klaroConfig{
+		Version:       1,
+		ElementID:     "klaro",
+		StorageMethod: "cookie",
+		CookieName:    "klaro",
+		AcceptAll:     true,
+		Lang:          cfg.Lang,
+		Translations:  map[string]map[string]interface{}{},
+	}
+
+	var (
+		purposes = map[string]interface{}{}
+		header   = new(bytes.Buffer)
+		names    = map[string]int{}
+	)
+
+	// klaro consents per service, a provider with cookies of several categories is split into one service per category
+	for _, category := range cfg.Categories {
+		for _, s := range category.Services {
+			names[s.Name]++
+		}
+	}
+
+	kc.Translations[cfg.Lang] = map[string]interface{}{"purposes": purposes}
+
+	fmt.Fprintf(header, "// Klaro! configuration of %s, generated from the cookie scan at %s.\n",
+		cfg.Site, cfg.ScanTime.Format(time.RFC3339))
+	fmt.Fprintf(header, "// Block the listed scripts and resources until consent by changing their markup, e.g.\n")
+	fmt.Fprintf(header, "// <script type=\"text/plain\" data-type=\"text/javascript\" data-name=\"<service>\" data-src=\"<url>\"></script>\n")
+	fmt.Fprintf(header, "// <img data-name=\"<service>\" data-src=\"<url>\">\n")
+
+	for _, category := range cfg.Categories {
+		purposes[category.ID] = map[string]string{"title": category.Name}
+
+		for _, s := range category.Services {
+			ks := &klaroService{
+				Name:     s.Name,
+				Title:    s.Title,
+				Purposes: []string{category.ID},
+				Cookies:  [][]interface{}{},
+				Required: category.Required,
+				Default:  category.Required,
+				OnlyOnce: true,
+			}
+			if names[s.Name] > 1 {
+				ks.Name = s.Name + "-" + category.ID
+				ks.Title = s.Title + " (" + category.Name + ")"
+			}
+			kc.Services = append(kc.Services, ks)
+
+			for _, c := range s.Cookies {
+				ks.Cookies = append(ks.Cookies, []interface{}{klaroRegexpPrefix + c.Pattern, c.Path, c.Domain})
+			}
+
+			for _, r := range s.Rules {
+				fmt.Fprintf(header, "// %s (%s): %s\n", ks.Name, r.Type, r.URL)
+			}
+		}
+	}
+
+	jsonBlob, err := json.MarshalIndent(kc, "", "  ")
+	if err != nil {
+		return
+	}
+
+	// cookie patterns are javascript regular expression literals in klaro config
+	var replaceErr error
+	configData := klaroRegexpValue.ReplaceAllStringFunc(string(jsonBlob), func(s string) string {
+		var pattern string
+		if err := json.Unmarshal([]byte(`"`+strings.TrimPrefix(s, `"`+klaroRegexpPrefix)), &pattern); err != nil {
+			replaceErr = err
+			return s
+		}
+		return "/" + strings.Replace(pattern, "/", `\/`, -1) + "/"
+	})
+	if replaceErr != nil {
+		err = errors.Wrap(replaceErr, "convert klaro cookie pattern failed")
+		return
+	}
+
+	str = header.String() + "var klaroConfig = " + configData + ";\n"
+	return
+}
+
+func outputAsConsentConfig(data *reportData, format string) (str string, err error) {
+	cfg := newConsentConfig(data)
+
+	switch format {
+	case ConsentGeneric:
+		var jsonBlob []byte
+		jsonBlob, err = json.MarshalIndent(cfg, "", "  ")
+		str = string(jsonBlob)
+	case ConsentKlaro:
+		str, err = outputAsKlaro(cfg)
+	default:
+		err = errors.Errorf("unknown consent-manager format: %s", format)
+	}
+
+	return
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type csvColumn struct {
+	header string
+	value  func(c *reportCookieRecord) string
+}
+
+// csvColumns defines the cookie report columns of csv output.
+var csvColumns = []*csvColumn{
+	{"category", func(c *reportCookieRecord) string { return c.Category }},
+	{"canonical_category", func(c *reportCookieRecord) string { return c.CanonicalCategory }},
+	{"inferred", func(c *reportCookieRecord) string { return strconv.FormatBool(c.Inferred) }},
+	{"confidence", func(c *reportCookieRecord) string { return strconv.FormatFloat(c.Confidence, 'f', 2, 64) }},
+	{"signals", func(c *reportCookieRecord) string { return strings.Join(c.Signals, "; ") }},
+	{"icc_uk", func(c *reportCookieRecord) string {
+		if c.Purposes == nil {
+			return ""
+		}
+		return c.Purposes.ICCUK
+	}},
+	{"cnil", func(c *reportCookieRecord) string {
+		if c.Purposes == nil {
+			return ""
+		}
+		return c.Purposes.CNIL
+	}},
+	{"tcf_purposes", func(c *reportCookieRecord) string {
+		if c.Purposes == nil {
+			return ""
+		}
+		return joinInts(c.Purposes.TCFPurposes, ";")
+	}},
+	{"name", func(c *reportCookieRecord) string { return c.Name }},
+	{"path", func(c *reportCookieRecord) string { return c.Path }},
+	{"domain", func(c *reportCookieRecord) string { return c.Domain }},
+	{"expires", func(c *reportCookieRecord) string {
+		if c.Expires.IsZero() {
+			return ""
+		}
+		return c.Expires.Format(time.RFC3339)
+	}},
+	{"max_age", func(c *reportCookieRecord) string { return strconv.Itoa(c.MaxAge) }},
+	{"expiry", func(c *reportCookieRecord) string { return c.Expiry }},
+	{"secure", func(c *reportCookieRecord) string { return strconv.FormatBool(c.Secure) }},
+	{"http_only", func(c *reportCookieRecord) string { return strconv.FormatBool(c.HttpOnly) }},
+	{"used_requests", func(c *reportCookieRecord) string { return strconv.Itoa(c.UsedRequests) }},
+	{"description", func(c *reportCookieRecord) string { return c.Description }},
+	{"url", func(c *reportCookieRecord) string { return c.URL }},
+	{"remote_addr", func(c *reportCookieRecord) string { return c.RemoteAddr }},
+	{"status", func(c *reportCookieRecord) string { return strconv.Itoa(c.Status) }},
+	{"mime_type", func(c *reportCookieRecord) string { return c.MimeType }},
+	{"initiator", func(c *reportCookieRecord) string { return c.Initiator }},
+	{"source", func(c *reportCookieRecord) string { return c.Source }},
+	{"line_no", func(c *reportCookieRecord) string { return strconv.Itoa(c.LineNo) }},
+	{"type", func(c *reportCookieRecord) string { return c.Type }},
+	{"first_set_ms", func(c *reportCookieRecord) string {
+		if c.FirstSet < 0 {
+			return ""
+		}
+		return strconv.FormatFloat(c.FirstSet, 'f', 0, 64)
+	}},
+	{"first_set_estimated", func(c *reportCookieRecord) string { return strconv.FormatBool(c.FirstSetEstimated) }},
+	{"third_party", func(c *reportCookieRecord) string { return strconv.FormatBool(c.ThirdParty) }},
+	{"destinations", func(c *reportCookieRecord) string {
+		hosts := make([]string, 0, len(c.Destinations))
+		for _, d := range c.Destinations {
+			hosts = append(hosts, d.Host+":"+strconv.Itoa(d.Requests))
+		}
+		return strings.Join(hosts, "; ")
+	}},
+	{"party", func(c *reportCookieRecord) string { return c.Party }},
+	{"registrable_domain", func(c *reportCookieRecord) string { return c.RegistrableDomain }},
+	{"cname_cloaked", func(c *reportCookieRecord) string { return strconv.FormatBool(c.CNAMECloaked) }},
+	{"cname_chain", func(c *reportCookieRecord) string { return strings.Join(c.CNAMEChain, " > ") }},
+	{"cname_tracker", func(c *reportCookieRecord) string { return c.CNAMETracker }},
+	{"tracker", func(c *reportCookieRecord) string { return strconv.FormatBool(c.Tracker) }},
+	{"tracker_kind", func(c *reportCookieRecord) string { return c.TrackerKind }},
+	{"tracker_rule", func(c *reportCookieRecord) string { return c.TrackerRule }},
+	{"server_country", func(c *reportCookieRecord) string {
+		if c.Location == nil {
+			return ""
+		}
+		return c.Location.Country
+	}},
+	{"server_continent", func(c *reportCookieRecord) string {
+		if c.Location == nil {
+			return ""
+		}
+		return c.Location.Continent
+	}},
+	{"server_asn", func(c *reportCookieRecord) string { return c.Location.Network() }},
+	{"server_outside_eea", func(c *reportCookieRecord) string {
+		if c.Location == nil || c.Location.Country == "" {
+			return ""
+		}
+		return strconv.FormatBool(!c.Location.EEA)
+	}},
+}
+
+func outputAsCSV(data *reportData) (str string, err error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+
+	row := make([]string, 0, len(csvColumns))
+	for _, col := range csvColumns {
+		row = append(row, col.header)
+	}
+	_ = w.Write(row)
+
+	for _, record := range data.Records {
+		for _, c := range record.Cookies {
+			row = row[:0]
+			for _, col := range csvColumns {
+				row = append(row, col.value(c))
+			}
+			_ = w.Write(row)
+		}
+	}
+
+	w.Flush()
+	err = w.Error()
+	str = buf.String()
+
+	return
+}
+
+func joinInts(v []int, sep string) string {
+	strs := make([]string, 0, len(v))
+	for _, i := range v {
+		strs = append(strs, strconv.Itoa(i))
+	}
+	return strings.Join(strs, sep)
+}
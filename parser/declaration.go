@@ -0,0 +1,280 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DeclarationFragment is an html fragment to be pasted into the cookie policy page.
+	DeclarationFragment = "fragment"
+	// DeclarationPage is a standalone html page.
+	DeclarationPage = "page"
+	// DeclarationScript is a javascript embed rendering the declaration in place.
+	DeclarationScript = "js"
+	// DeclarationJSON is the declaration data used by the javascript embed.
+	DeclarationJSON = "json"
+)
+
+var (
+	declarationTemplate = template.New("declaration_template")
+)
+
+// DeclarationFormats returns the supported cookie declaration formats.
+func DeclarationFormats() []string {
+	return []string{DeclarationFragment, DeclarationPage, DeclarationScript, DeclarationJSON}
+}
+
+type declarationCookie struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Purpose  string `json:"purpose"`
+	Expiry   string `json:"expiry"`
+	Type     string `json:"type"`
+}
+
+type declarationCategory struct {
+	ID      string               `json:"id"`
+	Name    string               `json:"name"`
+	Cookies []*declarationCookie `json:"cookies"`
+}
+
+type declarationData struct {
+	Lang       string                 `json:"lang"`
+	ScanURL    string                 `json:"scan_url"`
+	ScanTime   time.Time              `json:"scan_time"`
+	Labels     map[string]string      `json:"labels"`
+	Categories []*declarationCategory `json:"categories"`
+}
+
+// declarationLabels are the translated headings shared by the html templates and the javascript embed.
+var declarationLabels = map[string]string{
+	"title":    "Cookie declaration",
+	"intro":    "This website uses the following cookies, last checked on %s.",
+	"name":     "Name",
+	"provider": "Provider",
+	"purpose":  "Purpose",
+	"expiry":   "Expiry",
+	"type":     "Type",
+}
+
+func init() {
+	template.Must(declarationTemplate.Funcs(template.FuncMap{
+		"T": func(key string, args ...interface{}) string {
+			return Translate(DefaultLanguage, key, args...)
+		},
+		"declarationStylesheet": func() template.CSS {
+			return template.CSS(declarationStylesheet)
+		},
+	}).Parse(`{{define "fragment"}}<div class="cookie-declaration" lang="{{.Lang}}">
+    <p>{{index .Labels "intro"}}</p>
+    {{range $category := .Categories}}
+    <section class="cookie-declaration-category" data-category="{{$category.ID}}">
+        <h3>{{$category.Name}} ({{len $category.Cookies}})</h3>
+        <table>
+            <thead>
+            <tr>
+                <th>{{index $.Labels "name"}}</th>
+                <th>{{index $.Labels "provider"}}</th>
+                <th>{{index $.Labels "purpose"}}</th>
+                <th>{{index $.Labels "expiry"}}</th>
+                <th>{{index $.Labels "type"}}</th>
+            </tr>
+            </thead>
+            <tbody>
+            {{range $cookie := $category.Cookies}}
+            <tr>
+                <td>{{$cookie.Name}}</td>
+                <td>{{$cookie.Provider}}</td>
+                <td>{{$cookie.Purpose}}</td>
+                <td>{{$cookie.Expiry}}</td>
+                <td>{{T $cookie.Type}}</td>
+            </tr>
+            {{end}}
+            </tbody>
+        </table>
+    </section>
+    {{end}}
+</div>{{end}}{{define "page"}}<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <title>{{index .Labels "title"}}</title>
+    <style>{{declarationStylesheet}}</style>
+</head>
+<body>
+<h2>{{index .Labels "title"}}</h2>
+{{template "fragment" .}}
+</body>
+</html>{{end}}`))
+}
+
+const declarationStylesheet = `body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,"Helvetica Neue",Arial,sans-serif;color:#212529;margin:2rem}
+.cookie-declaration table{width:100%;border-collapse:collapse;margin-bottom:1.5rem}
+.cookie-declaration th,.cookie-declaration td{text-align:left;vertical-align:top;padding:.5rem;border-top:1px solid #dee2e6}
+.cookie-declaration thead th{border-bottom:2px solid #dee2e6}
+`
+
+// declarationScript renders the declaration into the element with id "cookie-declaration",
+// or right before the script tag if there is no such element.
+const declarationScript = `(function (data) {
+  var script = document.currentScript;
+  var el = function (tag, text) {
+    var e = document.createElement(tag);
+    if (text !== undefined) e.textContent = text;
+    return e;
+  };
+  var root = document.getElementById("cookie-declaration");
+  if (!root) {
+    root = el("div");
+    script.parentNode.insertBefore(root, script);
+  }
+  root.className = "cookie-declaration";
+  root.appendChild(el("p", data.labels.intro));
+  data.categories.forEach(function (category) {
+    var section = el("section");
+    section.className = "cookie-declaration-category";
+    section.setAttribute("data-category", category.id);
+    section.appendChild(el("h3", category.name + " (" + category.cookies.length + ")"));
+    var table = el("table"), head = el("tr"), body = el("tbody");
+    ["name", "provider", "purpose", "expiry", "type"].forEach(function (key) {
+      head.appendChild(el("th", data.labels[key]));
+    });
+    table.appendChild(el("thead")).appendChild(head);
+    category.cookies.forEach(function (cookie) {
+      var row = el("tr");
+      [cookie.name, cookie.provider, cookie.purpose, cookie.expiry, data.labels[cookie.type]].forEach(function (v) {
+        row.appendChild(el("td", v));
+      });
+      body.appendChild(row);
+    });
+    table.appendChild(body);
+    section.appendChild(table);
+    root.appendChild(section);
+  });
+})(`
+
+func newDeclarationData(data *reportData) *declarationData {
+	lang := data.Lang
+	d := &declarationData{
+		Lang:     lang,
+		ScanURL:  data.ScanURL,
+		ScanTime: data.ScanTime,
+		Labels:   map[string]string{},
+	}
+
+	for k, v := range declarationLabels {
+		d.Labels[k] = Translate(lang, v)
+	}
+	d.Labels["intro"] = Translate(lang, declarationLabels["intro"], data.ScanTime.Format("2006-01-02"))
+	d.Labels[CookieTypeHTTP] = Translate(lang, CookieTypeHTTP)
+	d.Labels[CookieTypeScript] = Translate(lang, CookieTypeScript)
+
+	// inferred and classified cookies of the same category are declared together
+	categories := map[string]*declarationCategory{}
+
+	for _, record := range data.Records {
+		id, name := record.CanonicalCategory, Translate(lang, record.Category)
+		if record.Category == "" {
+			id, name = CategoryIDUnclassified, Translate(lang, "Unclassified")
+		}
+
+		category, ok := categories[id]
+		if !ok {
+			category = &declarationCategory{ID: id, Name: name}
+			categories[id] = category
+			d.Categories = append(d.Categories, category)
+		}
+
+		for _, c := range record.Cookies {
+			category.Cookies = append(category.Cookies, &declarationCookie{
+				Name:     c.Name,
+				Provider: strings.TrimPrefix(c.Domain, "."),
+				Purpose:  c.Description,
+				Expiry:   c.Expiry,
+				Type:     c.Type,
+			})
+		}
+	}
+
+	rank := func(c *declarationCategory) int {
+		if c.ID == CategoryIDUnclassified {
+			return len(taxonomy) + 1
+		}
+		if cat := LookupCategory(c.ID); cat != nil {
+			return categoryRank(cat.Name)
+		}
+		return len(taxonomy)
+	}
+
+	sort.SliceStable(d.Categories, func(i, j int) bool {
+		return rank(d.Categories[i]) < rank(d.Categories[j])
+	})
+
+	for _, c := range d.Categories {
+		sort.SliceStable(c.Cookies, func(i, j int) bool {
+			return c.Cookies[i].Name < c.Cookies[j].Name
+		})
+	}
+
+	return d
+}
+
+func outputAsDeclaration(data *reportData, format string) (str string, err error) {
+	d := newDeclarationData(data)
+
+	switch format {
+	case DeclarationFragment, DeclarationPage:
+		var tpl *template.Template
+		if tpl, err = declarationTemplate.Clone(); err != nil {
+			return
+		}
+
+		tpl.Funcs(template.FuncMap{
+			"T": func(key string, args ...interface{}) string {
+				return Translate(d.Lang, key, args...)
+			},
+		})
+
+		buf := new(bytes.Buffer)
+		err = tpl.ExecuteTemplate(buf, format, d)
+		str = buf.String()
+	case DeclarationScript, DeclarationJSON:
+		var jsonBlob []byte
+		if jsonBlob, err = json.Marshal(d); err != nil {
+			return
+		}
+
+		if format == DeclarationJSON {
+			str = string(jsonBlob)
+		} else {
+			str = declarationScript + string(jsonBlob) + ");\n"
+		}
+	default:
+		err = errors.Errorf("unknown declaration format: %s", format)
+	}
+
+	return
+}
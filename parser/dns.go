@@ -0,0 +1,311 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDNSTimeout is the timeout of a single dns query.
+	DefaultDNSTimeout = 2 * time.Second
+
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsClassIN   = 1
+	dnsMaxHops   = 16
+
+	dnsRcodeNXDomain = 3
+)
+
+// CNAMEResolver resolves the canonical name chain of a host.
+type CNAMEResolver interface {
+	// LookupCNAME returns the host followed by its canonical name targets, nil if host is not an alias.
+	LookupCNAME(host string) (chain []string, err error)
+}
+
+// DNSResolver is a minimal dns client sending recursive queries to a single name server.
+type DNSResolver struct {
+	Server  string
+	Timeout time.Duration
+}
+
+// NewDNSResolver creates a resolver of the name server address, the first name server of /etc/resolv.conf is used
+// if server is empty.
+func NewDNSResolver(server string, timeout time.Duration) (r *DNSResolver, err error) {
+	if server == "" {
+		if server, err = systemNameServer("/etc/resolv.conf"); err != nil {
+			return
+		}
+	}
+
+	if _, _, splitErr := net.SplitHostPort(server); splitErr != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultDNSTimeout
+	}
+
+	r = &DNSResolver{Server: server, Timeout: timeout}
+
+	return
+}
+
+func systemNameServer(resolvConf string) (server string, err error) {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		err = errors.Wrap(err, "read system dns config failed")
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[0] == "nameserver" {
+			server = fields[1]
+			return
+		}
+	}
+
+	err = errors.New("no name server in system dns config")
+
+	return
+}
+
+// LookupCNAME queries the a record of host and follows the cname records of answers.
+func (r *DNSResolver) LookupCNAME(host string) (chain []string, err error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	query, id, err := dnsQuery(host, dnsTypeA)
+	if err != nil {
+		return
+	}
+
+	resp, err := r.exchange("udp", query)
+	if err == nil && len(resp) > 2 && resp[2]&0x02 != 0 {
+		// truncated, retry with tcp
+		resp, err = r.exchange("tcp", query)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "query %s failed", host)
+		return
+	}
+
+	aliases, err := parseCNAMEAnswers(resp, id)
+	if err != nil {
+		err = errors.Wrapf(err, "parse dns response of %s failed", host)
+		return
+	}
+
+	for name := host; len(chain) < dnsMaxHops; {
+		target, ok := aliases[name]
+		if !ok {
+			break
+		}
+		if len(chain) == 0 {
+			chain = append(chain, host)
+		}
+		chain = append(chain, target)
+		name = target
+	}
+
+	return
+}
+
+func (r *DNSResolver) exchange(network string, query []byte) (resp []byte, err error) {
+	conn, err := net.DialTimeout(network, r.Server, r.Timeout)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.SetDeadline(time.Now().Add(r.Timeout))
+
+	if network == "tcp" {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err = conn.Write(msg); err != nil {
+			return
+		}
+
+		var length [2]byte
+		if _, err = io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(length[:]))
+		_, err = io.ReadFull(conn, resp)
+		return
+	}
+
+	if _, err = conn.Write(query); err != nil {
+		return
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	resp = buf[:n]
+
+	return
+}
+
+// dnsQuery builds a recursive query message of name.
+func dnsQuery(name string, qtype uint16) (msg []byte, id uint16, err error) {
+	id = uint16(rand.Uint32())
+
+	msg = make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	// recursion desired
+	msg[2] = 0x01
+	// one question
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			err = errors.Errorf("invalid dns name: %s", name)
+			return
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], dnsClassIN)
+
+	return
+}
+
+// parseCNAMEAnswers returns the owner to target map of cname records in answer section.
+func parseCNAMEAnswers(msg []byte, id uint16) (aliases map[string]string, err error) {
+	if len(msg) < 12 {
+		err = errors.New("short dns message")
+		return
+	}
+	if binary.BigEndian.Uint16(msg) != id {
+		err = errors.New("dns message id mismatch")
+		return
+	}
+
+	aliases = map[string]string{}
+
+	switch rcode := msg[3] & 0x0f; rcode {
+	case 0:
+	case dnsRcodeNXDomain:
+		return
+	default:
+		err = errors.Errorf("dns server returned code %d", rcode)
+		return
+	}
+
+	qdCount := int(binary.BigEndian.Uint16(msg[4:]))
+	anCount := int(binary.BigEndian.Uint16(msg[6:]))
+	off := 12
+
+	for i := 0; i < qdCount; i++ {
+		if _, off, err = readDNSName(msg, off); err != nil {
+			return
+		}
+		off += 4
+	}
+
+	for i := 0; i < anCount; i++ {
+		var owner string
+		if owner, off, err = readDNSName(msg, off); err != nil {
+			return
+		}
+		if off+10 > len(msg) {
+			err = errors.New("short dns resource record")
+			return
+		}
+
+		rrType := binary.BigEndian.Uint16(msg[off:])
+		rdLength := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdLength > len(msg) {
+			err = errors.New("short dns resource data")
+			return
+		}
+
+		if rrType == dnsTypeCNAME {
+			var target string
+			if target, _, err = readDNSName(msg, off); err != nil {
+				return
+			}
+			aliases[strings.ToLower(owner)] = strings.ToLower(target)
+		}
+
+		off += rdLength
+	}
+
+	return
+}
+
+// readDNSName reads the possibly compressed domain name at offset.
+func readDNSName(msg []byte, off int) (name string, next int, err error) {
+	var (
+		labels []string
+		jumped bool
+	)
+
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 127 {
+			err = errors.New("invalid dns name")
+			return
+		}
+
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			if !jumped {
+				next = off + 1
+			}
+			name = strings.Join(labels, ".")
+			return
+		case length&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				err = errors.New("invalid dns name pointer")
+				return
+			}
+			if !jumped {
+				next = off + 2
+			}
+			jumped = true
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+length > len(msg) {
+				err = errors.New("invalid dns label")
+				return
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
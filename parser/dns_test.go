@@ -0,0 +1,303 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dnsStubAnswer is the response of the dns stub to a query name.
+type dnsStubAnswer struct {
+	rcode    byte
+	cnames   [][2]string
+	truncate bool
+}
+
+func encodeDNSName(name string) (b []byte) {
+	for _, label := range splitDNSName(name) {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func splitDNSName(name string) (labels []string) {
+	start := 0
+	for i := 0; i <= len(name); i++ {
+		if i == len(name) || name[i] == '.' {
+			if i > start {
+				labels = append(labels, name[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return
+}
+
+// dnsStubResponse answers the query with the cname records, the owner of the first record is compressed.
+func dnsStubResponse(query []byte, answer dnsStubAnswer, tcp bool) []byte {
+	msg := make([]byte, 12)
+	copy(msg, query[:2])
+	msg[2] = 0x81
+	if answer.truncate && !tcp {
+		msg[2] |= 0x02
+	}
+	msg[3] = 0x80 | answer.rcode
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	msg = append(msg, query[12:]...)
+
+	if answer.truncate && !tcp {
+		return msg
+	}
+
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answer.cnames)))
+	for i, rr := range answer.cnames {
+		if i == 0 {
+			msg = append(msg, 0xc0, 12)
+		} else {
+			msg = append(msg, encodeDNSName(rr[0])...)
+		}
+		target := encodeDNSName(rr[1])
+		header := make([]byte, 10)
+		binary.BigEndian.PutUint16(header, dnsTypeCNAME)
+		binary.BigEndian.PutUint16(header[2:], dnsClassIN)
+		binary.BigEndian.PutUint32(header[4:], 300)
+		binary.BigEndian.PutUint16(header[8:], uint16(len(target)))
+		msg = append(msg, header...)
+		msg = append(msg, target...)
+	}
+
+	return msg
+}
+
+// startDNSStub serves the answers on udp and tcp of the same local port.
+func startDNSStub(t *testing.T, answers map[string]dnsStubAnswer) (addr string, stop func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = pc.LocalAddr().String()
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		_ = pc.Close()
+		t.Skipf("listen tcp dns stub failed: %v", err)
+	}
+
+	stop = func() {
+		_ = pc.Close()
+		_ = l.Close()
+	}
+
+	answer := func(query []byte) dnsStubAnswer {
+		name, _, err := readDNSName(query, 12)
+		if err != nil {
+			return dnsStubAnswer{rcode: 2}
+		}
+		return answers[name]
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := append([]byte(nil), buf[:n]...)
+			_, _ = pc.WriteTo(dnsStubResponse(query, answer(query), false), from)
+		}
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() {
+					_ = conn.Close()
+				}()
+
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+
+				resp := dnsStubResponse(query, answer(query), true)
+				msg := make([]byte, 2, 2+len(resp))
+				binary.BigEndian.PutUint16(msg, uint16(len(resp)))
+				_, _ = conn.Write(append(msg, resp...))
+			}(conn)
+		}
+	}()
+
+	return
+}
+
+func TestDNSResolverLookupCNAME(t *testing.T) {
+	addr, stop := startDNSStub(t, map[string]dnsStubAnswer{
+		"metrics.example.com": {cnames: [][2]string{
+			{"metrics.example.com", "example.com.eu1.eulerian.net"},
+			{"example.com.eu1.eulerian.net", "Edge.Eulerian.net"},
+		}},
+		"large.example.com": {truncate: true, cnames: [][2]string{
+			{"large.example.com", "large.example.net"},
+		}},
+		"www.example.com":     {},
+		"missing.example.com": {rcode: dnsRcodeNXDomain},
+		"broken.example.com":  {rcode: 2},
+	})
+	defer stop()
+
+	r, err := NewDNSResolver(addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		host    string
+		chain   []string
+		wantErr bool
+	}{
+		{"Metrics.Example.com.", []string{"metrics.example.com", "example.com.eu1.eulerian.net", "edge.eulerian.net"}, false},
+		{"large.example.com", []string{"large.example.com", "large.example.net"}, false},
+		{"www.example.com", nil, false},
+		{"missing.example.com", nil, false},
+		{"broken.example.com", nil, true},
+	} {
+		chain, err := r.LookupCNAME(c.host)
+		if (err != nil) != c.wantErr {
+			t.Errorf("LookupCNAME(%q) error = %v, want error %v", c.host, err, c.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(chain, c.chain) {
+			t.Errorf("LookupCNAME(%q) = %v, want %v", c.host, chain, c.chain)
+		}
+	}
+}
+
+func TestNewDNSResolverDefaultPort(t *testing.T) {
+	for server, want := range map[string]string{
+		"127.0.0.1":      "127.0.0.1:53",
+		"127.0.0.1:5353": "127.0.0.1:5353",
+		"::1":            "[::1]:53",
+		"[::1]:5353":     "[::1]:5353",
+	} {
+		r, err := NewDNSResolver(server, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Server != want || r.Timeout != DefaultDNSTimeout {
+			t.Errorf("NewDNSResolver(%q) = %s %s, want %s %s", server, r.Server, r.Timeout, want, DefaultDNSTimeout)
+		}
+	}
+}
+
+func TestParseCNAMEAnswersInvalid(t *testing.T) {
+	query, id, err := dnsQuery("www.example.com", dnsTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := dnsStubResponse(query, dnsStubAnswer{cnames: [][2]string{{"www.example.com", "cdn.example.net"}}}, true)
+
+	if _, err = parseCNAMEAnswers(resp, id+1); err == nil {
+		t.Error("expected id mismatch error")
+	}
+	if _, err = parseCNAMEAnswers(resp[:len(resp)-3], id); err == nil {
+		t.Error("expected truncated message error")
+	}
+
+	// pointer loop
+	loop := append([]byte(nil), resp[:12]...)
+	loop = append(loop, 0xc0, 12)
+	if _, err = parseCNAMEAnswers(loop, id); err == nil {
+		t.Error("expected invalid name error")
+	}
+}
+
+// countingResolver records the max number of concurrent lookups.
+type countingResolver struct {
+	l        sync.Mutex
+	inflight int
+	max      int
+	chains   map[string][]string
+}
+
+func (r *countingResolver) LookupCNAME(host string) (chain []string, err error) {
+	r.l.Lock()
+	r.inflight++
+	if r.inflight > r.max {
+		r.max = r.inflight
+	}
+	r.l.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	r.l.Lock()
+	r.inflight--
+	r.l.Unlock()
+
+	return r.chains[host], nil
+}
+
+func TestDetectCNAMECloaking(t *testing.T) {
+	resolver := &countingResolver{chains: map[string][]string{
+		"metrics.example.com": {"metrics.example.com", "example.com.eu1.eulerian.net"},
+		"consent.example.com": {"consent.example.com", "cdn.cookielaw.org"},
+	}}
+
+	record := &reportRecord{}
+	for i := 0; i < 4*cnameLookupWorkers; i++ {
+		record.Cookies = append(record.Cookies, &reportCookieRecord{
+			Name:   fmt.Sprintf("c%d", i),
+			Domain: fmt.Sprintf("host%d.example.com", i),
+		})
+	}
+	cloaked := &reportCookieRecord{Name: "etuix", Domain: ".example.com", URL: "https://metrics.example.com/collect"}
+	consent := &reportCookieRecord{Name: "OptanonConsent", Domain: "consent.example.com"}
+	record.Cookies = append(record.Cookies, cloaked, consent)
+
+	task := NewTask(&TaskConfig{Resolver: resolver})
+	if n := task.detectCNAMECloaking([]*reportRecord{record}); n != 1 {
+		t.Errorf("cloaked cookies = %d, want 1", n)
+	}
+
+	if resolver.max > cnameLookupWorkers {
+		t.Errorf("concurrent lookups = %d, want at most %d", resolver.max, cnameLookupWorkers)
+	}
+
+	if !cloaked.CNAMECloaked || !cloaked.ThirdParty || cloaked.CNAMETracker != "Eulerian" {
+		t.Errorf("cookie not marked as cloaked: %+v", cloaked)
+	}
+	if consent.CNAMECloaked || consent.ThirdParty {
+		t.Errorf("consent manager cookie marked as cloaked: %+v", consent)
+	}
+}
@@ -0,0 +1,354 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gobs/args"
+	"github.com/pkg/errors"
+	"github.com/raff/godet"
+	"github.com/sirupsen/logrus"
+)
+
+// Driver is the browser controlled by scan tasks.
+type Driver interface {
+	// Start launches the browser and connects to it.
+	Start() error
+	// Close closes the browser and releases its resources.
+	Close() error
+	Version() (*godet.Version, error)
+	// Subscribe registers the callback of debugger event, previous callback of the method is replaced.
+	Subscribe(method string, cb godet.EventCallback)
+	// Navigate enables page and network events and loads the url in current tab.
+	Navigate(url string) error
+	// Cookies returns all cookies of the browser.
+	Cookies() ([]godet.Cookie, error)
+	// Evaluate returns the value of javascript expression in current page.
+	Evaluate(expression string) (interface{}, error)
+	// Screenshot captures current page, clip is the page area in css pixels, nil for the viewport.
+	Screenshot(format string, quality int, clip map[string]interface{}) ([]byte, error)
+	// FullPageScreenshot captures the whole document of current page.
+	FullPageScreenshot(format string, quality int) (image []byte, width int, height int, err error)
+	// PrintToPDF loads the local html file and prints it as pdf.
+	PrintToPDF(htmlFile string, timeout time.Duration, options ...godet.PrintToPDFOption) ([]byte, error)
+}
+
+// godetDriver runs chrome with remote debugging.
+type godetDriver struct {
+	chromeApp string
+	port      int
+	headless  bool
+	verbose   bool
+	userDir   string
+	debugger  *exec.Cmd
+	remote    *godet.RemoteDebugger
+}
+
+func newGodetDriver(tc *TaskConfig) *godetDriver {
+	return &godetDriver{
+		chromeApp: tc.ChromeApp,
+		port:      tc.DebuggerPort,
+		headless:  tc.Headless,
+		verbose:   tc.Verbose,
+	}
+}
+
+func (d *godetDriver) Start() (err error) {
+	if d.chromeApp == "" {
+		var chromeapp string
+
+		switch runtime.GOOS {
+		case "darwin":
+			for _, c := range []string{
+				"/Applications/Google Chrome Canary.app/Contents/MacOS/Google Chrome Canary",
+				"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			} {
+				// MacOS apps are actually folders
+				if _, err := exec.LookPath(c); err == nil {
+					chromeapp = fmt.Sprintf("%q", c)
+					break
+				}
+			}
+
+		case "linux":
+			for _, c := range []string{
+				"headless_shell",
+				"chromium",
+				"chromium-browser",
+				"google-chrome-beta",
+				"google-chrome-unstable",
+				"google-chrome-stable"} {
+				if _, err := exec.LookPath(c); err == nil {
+					chromeapp = c
+					break
+				}
+			}
+
+		case "windows":
+		}
+
+		if chromeapp != "" {
+			if chromeapp == "headless_shell" {
+				chromeapp += " --no-sandbox"
+			} else {
+				chromeapp += " --headless"
+			}
+
+			chromeapp += fmt.Sprintf(" --remote-debugging-port=%d --no-default-browser-check --no-first-run --hide-scrollbars --bwsi --disable-gpu",
+				d.port)
+
+			if dir, err := ioutil.TempDir("", "gdpr_cookie"); err == nil {
+				d.userDir = dir
+				chromeapp += " --user-data-dir="
+				chromeapp += dir
+			}
+
+			chromeapp += " about:blank"
+		}
+
+		d.chromeApp = chromeapp
+	}
+
+	// start debugger
+	if !d.headless {
+		d.chromeApp = strings.Replace(d.chromeApp, "--headless", "", -1)
+	}
+
+	if d.chromeApp == "" {
+		err = errors.New("no chrome application available")
+		return
+	}
+
+	parts := args.GetArgs(d.chromeApp)
+	cmd := exec.Command(parts[0], parts[1:]...)
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	d.debugger = cmd
+
+	// connect debugger
+	for i := 0; i < 10; i++ {
+		time.Sleep(500 * time.Millisecond)
+
+		d.remote, err = godet.Connect(fmt.Sprintf("localhost:%d", d.port), d.verbose)
+		if err == nil {
+			break
+		}
+
+		logrus.WithError(err).Debug("connect to debugger failed")
+	}
+
+	return
+}
+
+func (d *godetDriver) Close() error {
+	if d.userDir != "" {
+		_ = os.RemoveAll(d.userDir)
+	}
+	if d.remote != nil {
+		d.remote.CloseBrowser()
+		_ = d.remote.Close()
+	}
+	if d.debugger != nil {
+		_ = d.debugger.Process.Signal(syscall.SIGTERM)
+		_ = d.debugger.Wait()
+	}
+	return nil
+}
+
+func (d *godetDriver) Version() (*godet.Version, error) {
+	if d.remote == nil {
+		return nil, errors.New("debugger not connected")
+	}
+	return d.remote.Version()
+}
+
+func (d *godetDriver) Subscribe(method string, cb godet.EventCallback) {
+	d.remote.CallbackEvent(method, cb)
+}
+
+func (d *godetDriver) Navigate(url string) (err error) {
+	_ = d.remote.RuntimeEvents(true)
+	_ = d.remote.NetworkEvents(true)
+	_ = d.remote.PageEvents(true)
+	_ = d.remote.DOMEvents(true)
+	_ = d.remote.LogEvents(true)
+	_ = d.remote.EmulationEvents(true)
+	//_ = remote.EnableRequestInterception(true)
+
+	_, err = d.remote.Navigate(url)
+	return
+}
+
+func (d *godetDriver) Cookies() ([]godet.Cookie, error) {
+	return d.remote.GetAllCookies()
+}
+
+func (d *godetDriver) Evaluate(expression string) (interface{}, error) {
+	return d.remote.Evaluate(expression)
+}
+
+// Screenshot captures current page, clip is the page area in css pixels.
+func (d *godetDriver) Screenshot(format string, quality int, clip map[string]interface{}) (image []byte, err error) {
+	params := godet.Params{
+		"format":      format,
+		"fromSurface": true,
+	}
+	if format != ScreenshotPNG && quality > 0 {
+		params["quality"] = quality
+	}
+	if clip != nil {
+		clip["scale"] = 1
+		params["clip"] = clip
+	}
+
+	res, err := d.remote.SendRequest("Page.captureScreenshot", params)
+	if err != nil {
+		return
+	}
+
+	data, ok := res["data"].(string)
+	if !ok {
+		err = errors.New("no screenshot data")
+		return
+	}
+
+	return base64.StdEncoding.DecodeString(data)
+}
+
+// FullPageScreenshot resizes the viewport to the document size and captures the whole page.
+func (d *godetDriver) FullPageScreenshot(format string, quality int) (image []byte, width int, height int, err error) {
+	res, err := d.remote.SendRequest("Page.getLayoutMetrics", nil)
+	if err != nil {
+		return
+	}
+
+	size, _ := res["cssContentSize"].(map[string]interface{})
+	if size == nil {
+		size, _ = res["contentSize"].(map[string]interface{})
+	}
+	if size == nil {
+		err = errors.New("no page content size")
+		return
+	}
+
+	w, _ := size["width"].(float64)
+	h, _ := size["height"].(float64)
+	width, height = int(w+0.5), int(h+0.5)
+	if height > maxFullPageHeight {
+		height = maxFullPageHeight
+	}
+	if width <= 0 || height <= 0 {
+		err = errors.New("empty page content")
+		return
+	}
+
+	if err = d.remote.SetDeviceMetricsOverride(width, height, 1, false, false); err != nil {
+		return
+	}
+
+	defer func() {
+		_, _ = d.remote.SendRequest("Emulation.clearDeviceMetricsOverride", nil)
+	}()
+
+	image, err = d.Screenshot(format, quality, nil)
+	return
+}
+
+// PrintToPDF opens the html file in a new tab and prints it.
+func (d *godetDriver) PrintToPDF(htmlFile string, timeout time.Duration,
+	options ...godet.PrintToPDFOption) (pdfBytes []byte, err error) {
+	var (
+		tab     *godet.Tab
+		prevTab *godet.Tab
+	)
+
+	htmlFile, _ = filepath.Abs(htmlFile)
+	fileLink := (&url.URL{Scheme: "file", Path: htmlFile}).String()
+
+	// most recently used page is the current tab
+	if tabs, _ := d.remote.TabList("page"); len(tabs) > 0 {
+		prevTab = tabs[0]
+	}
+
+	if tab, err = d.remote.NewTab("about:blank"); err != nil {
+		err = errors.Wrap(err, "open pdf tab failed")
+		return
+	}
+
+	defer func() {
+		_ = d.remote.CloseTab(tab)
+		if prevTab != nil {
+			_ = d.remote.ActivateTab(prevTab)
+		}
+	}()
+
+	if err = d.remote.ActivateTab(tab); err != nil {
+		err = errors.Wrap(err, "activate pdf tab failed")
+		return
+	}
+	if _, err = d.remote.Navigate(fileLink); err != nil {
+		err = errors.Wrap(err, "load html report failed")
+		return
+	}
+	if err = d.waitPageReady(fileLink, timeout); err != nil {
+		return
+	}
+
+	return d.remote.PrintToPDF(options...)
+}
+
+// waitPageReady waits for the load event and web fonts of the page in current tab.
+func (d *godetDriver) waitPageReady(pageURL string, timeout time.Duration) (err error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var res map[string]interface{}
+		res, err = d.remote.SendRequest("Runtime.evaluate", godet.Params{
+			"expression":    pdfReadyScript,
+			"awaitPromise":  true,
+			"returnByValue": true,
+		})
+		if err == nil && res != nil {
+			if result, ok := res["result"].(map[string]interface{}); ok && result["value"] == true {
+				return
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = errors.Errorf("wait for %s to load timeout", pageURL)
+			}
+			err = errors.Wrap(err, "page not ready")
+			return
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -17,7 +17,6 @@
 package parser
 
 import (
-	"bytes"
 	"html/template"
 )
 
@@ -26,11 +25,18 @@ var (
 )
 
 func init() {
-	template.Must(emailTemplate.Parse(`<!DOCTYPE html>
+	template.Must(emailTemplate.Funcs(template.FuncMap{
+		"T": func(key string, args ...interface{}) string {
+			return Translate(DefaultLanguage, key, args...)
+		},
+		"asset": func(name string) template.URL {
+			return emailAssets[name]
+		},
+	}).Parse(`<!DOCTYPE html>
 <html xmlns="http://www.w3.org/1999/xhtml" xmlns:v="urn:schemas-microsoft-com:vml" xmlns:o="urn:schemas-microsoft-com:office:office">
 
 <head>
-  <title> GDPR Expert Cookies Report </title>
+  <title> {{T "GDPR Expert Cookies Report"}} </title>
   <!--[if !mso]><!-- -->
   <meta http-equiv="X-UA-Compatible" content="IE=edge">
   <!--<![endif]-->
@@ -127,15 +133,15 @@ func init() {
 </head>
 
 <body style="background-color:#E7E7E7;">
-  <div style="display:none;font-size:1px;color:#ffffff;line-height:1px;max-height:0px;max-width:0px;opacity:0;overflow:hidden;"> Your website's cookies in-depth report. </div>
+  <div style="display:none;font-size:1px;color:#ffffff;line-height:1px;max-height:0px;max-width:0px;opacity:0;overflow:hidden;"> {{T "Your website's cookies in-depth report."}} </div>
   <div style="background-color:#E7E7E7;">
-    <table align="center" background="https://cdn.jsdelivr.net/gh/CovenantLabs/assets@c59ad83/gdprexpert/bg_small.png" border="0" cellpadding="0" cellspacing="0" role="presentation" style="background:url(https://cdn.jsdelivr.net/gh/CovenantLabs/assets@c59ad83/gdprexpert/bg_small.png) top center / cover no-repeat;width:100%;">
+    <table align="center" background="{{asset "background"}}" border="0" cellpadding="0" cellspacing="0" role="presentation" style="background:url({{asset "background"}}) top center / cover no-repeat;width:100%;">
       <tbody>
         <tr>
           <td>
             <!--[if mso | IE]>
         <v:rect  style="mso-width-percent:1000;" xmlns:v="urn:schemas-microsoft-com:vml" fill="true" stroke="false">
-        <v:fill  origin="0.5, 0" position="0.5, 0" src="https://cdn.jsdelivr.net/gh/CovenantLabs/assets@c59ad83/gdprexpert/bg_small.png" type="tile" />
+        <v:fill  origin="0.5, 0" position="0.5, 0" src="{{asset "background"}}" type="tile" />
         <v:textbox style="mso-fit-shape-to-text:true" inset="0,0,0,0">
 
       <table
@@ -161,12 +167,13 @@ func init() {
           <![endif]-->
                         <div class="mj-column-per-100 outlook-group-fix" style="font-size:13px;text-align:left;direction:ltr;display:inline-block;vertical-align:top;width:100%;">
                           <table border="0" cellpadding="0" cellspacing="0" role="presentation" style="vertical-align:top;" width="100%">
+                            {{block "header" .}}
                             <tr>
                               <td align="center" style="font-size:0px;padding:10px 25px;word-break:break-word;">
                                 <table border="0" cellpadding="0" cellspacing="0" role="presentation" style="border-collapse:collapse;border-spacing:0px;">
                                   <tbody>
                                     <tr>
-                                      <td style="width:200px;"> <img alt="" height="auto" src="https://cdn.jsdelivr.net/gh/CovenantLabs/assets@a92103d/gdprexpert/logo_white.png" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%;" width="200">                                        </td>
+                                      <td style="width:200px;"> <img alt="" height="auto" src="{{if .Branding.LogoURL}}{{.Branding.LogoURL}}{{else}}{{asset "logo"}}{{end}}" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%;" width="200">                                        </td>
                                     </tr>
                                   </tbody>
                                 </table>
@@ -174,7 +181,7 @@ func init() {
                             </tr>
                             <tr>
                               <td align="center" style="font-size:0px;padding:10px 25px;padding-top:30px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:bold;letter-spacing:1px;line-height:24px;text-align:center;text-transform:uppercase;color:#ffffff;"> Cookie Report <br> <span style="color: #979797; font-weight: normal"></span> </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:bold;letter-spacing:1px;line-height:24px;text-align:center;text-transform:uppercase;color:#ffffff;"> {{T "Cookie Report"}} <br> <span style="color: #979797; font-weight: normal"></span> </div>
                               </td>
                             </tr>
                             <tr>
@@ -182,6 +189,7 @@ func init() {
                                 <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:13px;font-weight:bold;letter-spacing:1px;line-height:20px;text-align:center;text-transform:uppercase;color:#0660ff;"> {{.ScanURL}} </div>
                               </td>
                             </tr>
+                            {{end}}
                           </table>
                         </div>
                         <!--[if mso | IE]>
@@ -237,9 +245,9 @@ func init() {
                       <table border="0" cellpadding="0" cellspacing="0" role="presentation" style="border-collapse:collapse;border-spacing:0px;">
                         <tbody>
                           <tr>
-                            <td style="width:600px;"> <a href="https://gdprexpert.io" target="_blank">
+                            <td style="width:600px;"> <a href="{{if ne .Branding.Website ""}}{{.Branding.Website}}{{else}}https://gdprexpert.io{{end}}" target="_blank">
 
-      <img alt="" height="auto" src="data:image/png;base64,{{.ScreenShotImage}}" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%;" width="600">
+      {{with .Screenshot "page"}}<img alt="" height="auto" src="{{.Src}}" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%;" width="600">{{end}}
 
         </a> </td>
                           </tr>
@@ -307,31 +315,33 @@ func init() {
           <![endif]-->
                         <div class="mj-column-per-100 outlook-group-fix" style="font-size:13px;text-align:left;direction:ltr;display:inline-block;vertical-align:top;width:100%;">
                           <table border="0" cellpadding="0" cellspacing="0" role="presentation" style="vertical-align:top;" width="100%">
+                            {{block "summary" .}}
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:20px;font-weight:bold;line-height:24px;text-align:left;color:#212b35;"> Cookies report of {{.ScanURL}} </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:20px;font-weight:bold;line-height:24px;text-align:left;color:#212b35;"> {{T "Cookies report of %s" .ScanURL}} </div>
                               </td>
                             </tr>
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> Hi there, </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> {{T "Hi there,"}} </div>
                               </td>
                             </tr>
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> Attached please find your cookie report of {{.ScanURL}}. </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> {{T "Attached please find your cookie report of %s." .ScanURL}} </div>
                               </td>
                             </tr>
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> Best Regards, </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> {{T "Best Regards,"}} </div>
                               </td>
                             </tr>
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> GDPRExpert Team </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> {{if ne .Branding.CompanyName ""}}{{T "%s Team" .Branding.CompanyName}}{{else}}{{T "GDPRExpert Team"}}{{end}} </div>
                               </td>
                             </tr>
+                            {{end}}
                           </table>
                         </div>
                         <!--[if mso | IE]>
@@ -446,14 +456,31 @@ func init() {
           <![endif]-->
                         <div class="mj-column-per-100 outlook-group-fix" style="font-size:13px;text-align:left;direction:ltr;display:inline-block;vertical-align:top;width:100%;">
                           <table border="0" cellpadding="0" cellspacing="0" role="presentation" style="vertical-align:top;" width="100%">
+                            {{block "contact" .}}
+                            {{if .Branding.IsCustom}}{{if .Branding.HasContact}}
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;padding-bottom:0;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:20px;font-weight:bold;line-height:24px;text-align:left;color:#212b35;"> Come talk to us, we are the GDPR experts! </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:20px;font-weight:bold;line-height:24px;text-align:left;color:#212b35;"> {{T "Contact us"}} </div>
                               </td>
                             </tr>
                             <tr>
                               <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> If you have any enqury about cookie consent policies, notification of privacy incidents, or even a complete GDPR solution. Talk to us! We will help you with the GDPR compliance so you can focus on your business. </div>
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;">
+                                  {{with .Branding.ContactEmail}}<a href="mailto:{{.}}" style="color:#0660ff;">{{.}}</a><br>{{end}}
+                                  {{with .Branding.ContactPhone}}{{.}}<br>{{end}}
+                                  {{with .Branding.ContactAddress}}{{.}}{{end}}
+                                </div>
+                              </td>
+                            </tr>
+                            {{end}}{{else}}
+                            <tr>
+                              <td align="left" style="font-size:0px;padding:10px 25px;padding-bottom:0;word-break:break-word;">
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:20px;font-weight:bold;line-height:24px;text-align:left;color:#212b35;"> {{T "Come talk to us, we are the GDPR experts!"}} </div>
+                              </td>
+                            </tr>
+                            <tr>
+                              <td align="left" style="font-size:0px;padding:10px 25px;word-break:break-word;">
+                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:16px;font-weight:400;line-height:24px;text-align:left;color:#637381;"> {{T "If you have any enqury about cookie consent policies, notification of privacy incidents, or even a complete GDPR solution. Talk to us! We will help you with the GDPR compliance so you can focus on your business."}} </div>
                               </td>
                             </tr>
                             <tr>
@@ -463,7 +490,7 @@ func init() {
                                     <tr>
                                       <td style="width:120px;"> <a href="https://go.crisp.chat/chat/embed/?website_id=d867c91b-30ea-4fba-b69e-352730e254c2" target="_blank">
 
-      <img height="auto" src="https://cdn.jsdelivr.net/gh/CovenantLabs/assets/gdprexpert/crisp_logo.png" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%;" width="120">
+      <img height="auto" src="{{asset "chat"}}" style="border:0;display:block;outline:none;text-decoration:none;height:auto;width:100%;" width="120">
 
         </a> </td>
                                     </tr>
@@ -471,6 +498,8 @@ func init() {
                                 </table>
                               </td>
                             </tr>
+                            {{end}}
+                            {{end}}
                           </table>
                         </div>
                         <!--[if mso | IE]>
@@ -678,16 +707,18 @@ func init() {
       <![endif]-->
                                               </td>
                                             </tr>
+                            {{block "footer" .}}
                                             <tr>
                                               <td align="center" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:11px;font-weight:400;line-height:16px;text-align:center;color:#445566;"> You are receiving this email because you requested for cookies report at GDPRExpert website https://gdprexpert.io and agreed to receive emails from us regarding new features, events and special offers. </div>
+                                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:11px;font-weight:400;line-height:16px;text-align:center;color:#445566;"> {{if .Branding.IsCustom}}{{T "You are receiving this email because you requested a cookie report from %s." (or .Branding.CompanyName .Branding.Website)}}{{else}}{{T "You are receiving this email because you requested for cookies report at GDPRExpert website https://gdprexpert.io and agreed to receive emails from us regarding new features, events and special offers."}}{{end}} </div>
                                               </td>
                                             </tr>
                                             <tr>
                                               <td align="center" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:11px;font-weight:400;line-height:16px;text-align:center;color:#445566;"> &copy; GDPRExpert.io, All Rights Reserved. </div>
+                                                <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:11px;font-weight:400;line-height:16px;text-align:center;color:#445566;"> &copy; {{if ne .Branding.CompanyName ""}}{{.Branding.CompanyName}}{{else}}GDPRExpert.io{{end}}, {{T "All Rights Reserved."}} </div>
                                               </td>
                                             </tr>
+                            {{end}}
                                           </table>
                                         </td>
                                       </tr>
@@ -756,7 +787,7 @@ func init() {
                                             <table border="0" cellpadding="0" cellspacing="0" role="presentation" style="" width="100%">
                                               <tr>
                                                 <td align="center" style="font-size:0px;padding:10px 25px;word-break:break-word;">
-                                                  <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:11px;font-weight:bold;line-height:16px;text-align:center;color:#445566;"> <a class="footer-link" href="https://gdprexper.io/privacy" style="color: #888888;">Privacy</a>&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;<a class="footer-link" href="https://gdprexpert.io" style="color: #888888;">Website</a>                                                    </div>
+                                                  <div style="font-family:'Helvetica Neue', Helvetica, Arial, sans-serif;font-size:11px;font-weight:bold;line-height:16px;text-align:center;color:#445566;"> {{if .Branding.IsCustom}}{{with .Branding.Website}}<a class="footer-link" href="{{.}}" style="color: #888888;">{{T "Website"}}</a>{{end}}{{else}}<a class="footer-link" href="https://gdprexper.io/privacy" style="color: #888888;">{{T "Privacy"}}</a>&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;&#xA0;<a class="footer-link" href="https://gdprexpert.io" style="color: #888888;">{{T "Website"}}</a>{{end}}                                                    </div>
                                                 </td>
                                               </tr>
                                             </table>
@@ -816,9 +847,9 @@ func init() {
 `))
 }
 
-func formatEmailContent(data *reportData) (str string, err error) {
-	buf := new(bytes.Buffer)
-	err = emailTemplate.Execute(buf, data)
-	str = buf.String()
-	return
+func formatEmailContent(templates *Templates, data *reportData) (str string, err error) {
+	if data.Branding == nil {
+		data.Branding = &Branding{}
+	}
+	return executeLocalized(templates.emailTemplate(), data.Lang, data)
 }
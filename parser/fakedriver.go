@@ -0,0 +1,192 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/raff/godet"
+)
+
+// FakeEvent is a debugger event emitted by FakeDriver.
+type FakeEvent struct {
+	Method string
+	Params godet.Params
+}
+
+// FakeDriver is an in-memory driver emitting scripted debugger events on navigation, scans with the driver are
+// deterministic and do not need a browser. It is a supported api for testing code built on the parser, set it as
+// TaskConfig.Driver and use Task.Start and Task.Parse like a chrome scan.
+type FakeDriver struct {
+	// Events are emitted in order when navigating, a page load event is appended if not scripted.
+	Events []FakeEvent
+	// CookieJar is returned as the browser cookies after page load.
+	CookieJar []godet.Cookie
+	// EvaluateResults maps javascript expressions to results, like the consent banner box.
+	EvaluateResults map[string]interface{}
+	// Image is returned by screenshots, screenshots fail if not provided.
+	Image []byte
+	// PDF is returned by pdf printing, printing fails if not provided.
+	PDF []byte
+	// Navigated contains the navigated urls.
+	Navigated []string
+
+	l         sync.Mutex
+	callbacks map[string]godet.EventCallback
+}
+
+// NewFakeDriver returns a fake driver emitting events and returning cookies.
+func NewFakeDriver(events []FakeEvent, cookies []godet.Cookie) *FakeDriver {
+	return &FakeDriver{
+		Events:    events,
+		CookieJar: cookies,
+	}
+}
+
+// NewFakeDriverFromTrace returns a fake driver emitting the events and cookies of a trace recorded by scan.
+func NewFakeDriverFromTrace(r io.Reader) (d *FakeDriver, err error) {
+	d = &FakeDriver{}
+	dec := json.NewDecoder(r)
+
+	for line := 1; ; line++ {
+		var ev traceEvent
+		if err = dec.Decode(&ev); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = errors.Wrapf(err, "decode trace event %d failed", line)
+			return
+		}
+
+		switch ev.Method {
+		case traceScanMethod:
+		case traceCookiesMethod:
+			var jar traceCookies
+			if err = json.Unmarshal(ev.Params, &jar); err != nil {
+				err = errors.Wrap(err, "decode trace cookies failed")
+				return
+			}
+			d.CookieJar = jar.Cookies
+		default:
+			var params godet.Params
+			if err = json.Unmarshal(ev.Params, &params); err != nil {
+				err = errors.Wrapf(err, "decode trace event %d failed", line)
+				return
+			}
+			d.Events = append(d.Events, FakeEvent{Method: ev.Method, Params: params})
+		}
+	}
+
+	return
+}
+
+func (d *FakeDriver) Start() error {
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	d.callbacks = map[string]godet.EventCallback{}
+	return nil
+}
+
+func (d *FakeDriver) Close() error {
+	return nil
+}
+
+func (d *FakeDriver) Version() (*godet.Version, error) {
+	return &godet.Version{Browser: "FakeDriver/1.0", ProtocolVersion: "1.3"}, nil
+}
+
+func (d *FakeDriver) Subscribe(method string, cb godet.EventCallback) {
+	d.l.Lock()
+	defer d.l.Unlock()
+
+	if d.callbacks == nil {
+		d.callbacks = map[string]godet.EventCallback{}
+	}
+	d.callbacks[method] = cb
+}
+
+func (d *FakeDriver) emit(method string, params godet.Params) {
+	d.l.Lock()
+	cb := d.callbacks[method]
+	d.l.Unlock()
+
+	if cb != nil {
+		cb(params)
+	}
+}
+
+// Navigate emits the scripted events synchronously.
+func (d *FakeDriver) Navigate(url string) error {
+	d.l.Lock()
+	d.Navigated = append(d.Navigated, url)
+	d.l.Unlock()
+
+	var (
+		loaded bool
+		lastTs float64
+	)
+
+	for _, ev := range d.Events {
+		if ev.Method == pageEventLoad {
+			loaded = true
+		}
+		if ts, ok := ev.Params["timestamp"].(float64); ok && ts > lastTs {
+			lastTs = ts
+		}
+		d.emit(ev.Method, ev.Params)
+	}
+
+	if !loaded {
+		d.emit(pageEventLoad, godet.Params{"timestamp": lastTs})
+	}
+
+	return nil
+}
+
+func (d *FakeDriver) Cookies() ([]godet.Cookie, error) {
+	return d.CookieJar, nil
+}
+
+func (d *FakeDriver) Evaluate(expression string) (interface{}, error) {
+	return d.EvaluateResults[expression], nil
+}
+
+func (d *FakeDriver) Screenshot(format string, quality int, clip map[string]interface{}) ([]byte, error) {
+	if d.Image == nil {
+		return nil, errors.New("no screenshot scripted")
+	}
+	return d.Image, nil
+}
+
+func (d *FakeDriver) FullPageScreenshot(format string, quality int) (image []byte, width int, height int, err error) {
+	if image, err = d.Screenshot(format, quality, nil); err == nil {
+		width, height = imageSize(image)
+	}
+	return
+}
+
+func (d *FakeDriver) PrintToPDF(htmlFile string, timeout time.Duration, options ...godet.PrintToPDFOption) ([]byte, error) {
+	if d.PDF == nil {
+		return nil, errors.New("no pdf scripted")
+	}
+	return d.PDF, nil
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+func testPNG(t *testing.T, width int, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func fakeScan(t *testing.T, tc *TaskConfig, site string) *Task {
+	if tc.Timeout == 0 {
+		tc.Timeout = 5 * time.Second
+	}
+
+	task := NewTask(tc)
+	if err := task.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer task.Cleanup()
+
+	if err := task.Parse(site); err != nil {
+		t.Fatal(err)
+	}
+
+	return task
+}
+
+func TestFakeDriverTrace(t *testing.T) {
+	f, err := os.Open("testdata/replay.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	d, err := NewFakeDriverFromTrace(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Image = testPNG(t, 40, 30)
+	d.EvaluateResults = map[string]interface{}{
+		bannerScript: map[string]interface{}{"x": 0.0, "y": 20.0, "width": 40.0, "height": 10.0},
+	}
+
+	var trace bytes.Buffer
+	task := fakeScan(t, &TaskConfig{
+		Driver:     d,
+		Trace:      &trace,
+		Screenshot: &ScreenshotOptions{FullPage: true, Banner: true, Inline: true},
+	}, "https://www.example.com/")
+
+	if !reflect.DeepEqual(d.Navigated, []string{"https://www.example.com/"}) {
+		t.Errorf("navigated = %v", d.Navigated)
+	}
+
+	checkReplayReport(t, task)
+
+	for _, want := range []struct {
+		kind          string
+		width, height int
+	}{
+		{ScreenshotBanner, 40, 10},
+		{ScreenshotPage, 40, 30},
+	} {
+		s := task.reportData.Screenshot(want.kind)
+		if s == nil {
+			t.Errorf("%s screenshot not captured", want.kind)
+			continue
+		}
+		if s.Width != want.width || s.Height != want.height || s.MimeType != "image/png" || s.Image == "" {
+			t.Errorf("%s screenshot = %dx%d %s, want %dx%d inlined png", want.kind, s.Width, s.Height, s.MimeType,
+				want.width, want.height)
+		}
+	}
+	if page := task.reportData.Screenshot(ScreenshotPage); page != nil &&
+		task.reportData.ScreenShotImage != page.Image {
+		t.Error("ScreenShotImage is not the inlined page screenshot")
+	}
+
+	// the trace of the scan replays to the same report
+	replayed := NewTask(&TaskConfig{})
+	if err = replayed.Replay(&trace); err != nil {
+		t.Fatal(err)
+	}
+	checkReplayReport(t, replayed)
+}
+
+func TestFakeDriverScripted(t *testing.T) {
+	request := func(id string, ts float64, url string, kind string) FakeEvent {
+		return FakeEvent{Method: "Network.requestWillBeSent", Params: godet.Params{
+			"requestId": id,
+			"timestamp": ts,
+			"wallTime":  1559383200 + ts,
+			"type":      kind,
+			"request":   map[string]interface{}{"url": url, "method": "GET", "headers": map[string]interface{}{}},
+			"initiator": map[string]interface{}{"type": "other"},
+		}}
+	}
+	response := func(id string, ts float64, url string, headers map[string]interface{}) FakeEvent {
+		return FakeEvent{Method: "Network.responseReceived", Params: godet.Params{
+			"requestId": id,
+			"timestamp": ts,
+			"response": map[string]interface{}{
+				"url":             url,
+				"status":          200.0,
+				"headers":         headers,
+				"mimeType":        "text/html",
+				"remoteIPAddress": "192.0.2.1",
+			},
+		}}
+	}
+
+	d := NewFakeDriver([]FakeEvent{
+		request("1", 10, "https://shop.example.org/", "Document"),
+		response("1", 10.1, "https://shop.example.org/", map[string]interface{}{"Set-Cookie": "cart=1; Path=/"}),
+		request("2", 10.2, "https://embed.video.example/player", "Document"),
+		response("2", 10.3, "https://embed.video.example/player", map[string]interface{}{
+			"Set-Cookie": "vid=2; Path=/; Max-Age=86400",
+		}),
+		// data uris are ignored
+		request("3", 10.4, "data:image/png;base64,AAAA", "Image"),
+	}, []godet.Cookie{
+		{Name: "cart", Value: "1", Domain: "shop.example.org", Path: "/", Expires: -1, Session: true},
+		{Name: "vid", Value: "2", Domain: "embed.video.example", Path: "/", Expires: 1559469600},
+	})
+
+	task := fakeScan(t, &TaskConfig{Driver: d}, "https://shop.example.org/")
+	data := task.reportData
+
+	if data.CookieCount != 2 || data.FirstPartyCount != 1 || data.ThirdPartyCount != 1 {
+		t.Errorf("cookies = %d (%d first-party, %d third-party), want 2 (1, 1)",
+			data.CookieCount, data.FirstPartyCount, data.ThirdPartyCount)
+	}
+	if c := findCookie(data, "cart"); c == nil || c.Party != "first-party" || c.URL != "https://shop.example.org/" {
+		t.Errorf("cart cookie = %+v", c)
+	}
+	if c := findCookie(data, "vid"); c == nil || c.Party != "third-party" || c.Type != CookieTypeHTTP {
+		t.Errorf("vid cookie = %+v", c)
+	}
+
+	// no screenshot scripted
+	if len(data.Screenshots) != 0 {
+		t.Errorf("screenshots = %d, want none", len(data.Screenshots))
+	}
+	if _, err := task.OutputPDF(); err == nil {
+		t.Error("expected pdf error without scripted pdf")
+	}
+}
+
+// viewportDriver fails full page screenshots.
+type viewportDriver struct {
+	*FakeDriver
+}
+
+func (d viewportDriver) FullPageScreenshot(format string, quality int) ([]byte, int, int, error) {
+	return nil, 0, 0, errors.New("full page not supported")
+}
+
+func TestScreenshotViewportFallback(t *testing.T) {
+	d := NewFakeDriver(nil, nil)
+	d.Image = testPNG(t, 32, 24)
+
+	task := fakeScan(t, &TaskConfig{
+		Driver:     viewportDriver{d},
+		Screenshot: &ScreenshotOptions{FullPage: true, Inline: true},
+	}, "https://www.example.com/")
+
+	s := task.reportData.Screenshot(ScreenshotPage)
+	if s == nil {
+		t.Fatal("page screenshot not captured")
+	}
+	if s.Width != 32 || s.Height != 24 {
+		t.Errorf("page screenshot = %dx%d, want 32x24", s.Width, s.Height)
+	}
+}
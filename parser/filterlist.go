@@ -0,0 +1,597 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FilterKindTracker labels requests matched by tracker lists like EasyPrivacy.
+	FilterKindTracker = "tracker"
+	// FilterKindAd labels requests matched by advertising lists like EasyList.
+	FilterKindAd = "ad"
+)
+
+// filterTypes maps the adblock plus resource type options to debugger resource types.
+var filterTypes = map[string][]string{
+	"script":            {"Script"},
+	"image":             {"Image"},
+	"stylesheet":        {"Stylesheet"},
+	"xmlhttprequest":    {"XHR", "Fetch"},
+	"subdocument":       {"Document"},
+	"document":          {"Document"},
+	"font":              {"Font"},
+	"media":             {"Media"},
+	"ping":              {"Ping", "CSPViolationReport"},
+	"websocket":         {"WebSocket"},
+	"object":            {"Other"},
+	"object-subrequest": {"Other"},
+	"other":             {"Other", "Manifest", "TextTrack", "EventSource", "SignedExchange"},
+}
+
+// filterIgnoredOptions are options not affecting network matching.
+var filterIgnoredOptions = map[string]bool{
+	"important":  true,
+	"match-case": true,
+	"collapse":   true,
+	"~collapse":  true,
+}
+
+// filterCommonTokens are tokens of almost every url, rules are not indexed by them if possible.
+var filterCommonTokens = map[string]bool{
+	"http":  true,
+	"https": true,
+	"www":   true,
+	"com":   true,
+}
+
+var (
+	filterHostRulePattern = regexp.MustCompile(`^\|\|([a-z0-9][a-z0-9.-]*\.[a-z0-9-]+)\^$`)
+)
+
+// filterRule is a network rule of adblock plus syntax.
+type filterRule struct {
+	text      string
+	list      string
+	kind      string
+	exception bool
+
+	// host is set for "||host^" rules matched by host index
+	host    string
+	pattern *regexp.Regexp
+	// token is a literal word of pattern which every matched url contains
+	token string
+
+	// thirdParty is 1 for third-party only, -1 for first-party only
+	thirdParty      int
+	types           map[string]bool
+	excludedTypes   map[string]bool
+	domains         []string
+	excludedDomains []string
+}
+
+// filterRequest is a request matched against filter rules.
+type filterRequest struct {
+	url          string
+	host         string
+	resourceType string
+	thirdParty   bool
+	siteHost     string
+}
+
+type filterIndex struct {
+	hostRules  map[string][]*filterRule
+	tokenRules map[string][]*filterRule
+	rules      []*filterRule
+}
+
+// filterSet is the rules of lists with same kind, exception rules only allow requests blocked by the same kind.
+type filterSet struct {
+	kind  string
+	block *filterIndex
+	allow *filterIndex
+}
+
+// FilterLists is a network filter engine of adblock plus syntax lists, tracker lists are matched before
+// other kinds of lists.
+type FilterLists struct {
+	sets  []*filterSet
+	count int
+}
+
+// filterMatch is the rule labelling a request.
+type filterMatch struct {
+	Kind string
+	List string
+	Rule string
+}
+
+// NewFilterLists creates an empty filter engine.
+func NewFilterLists() *FilterLists {
+	return &FilterLists{}
+}
+
+func newFilterIndex() *filterIndex {
+	return &filterIndex{
+		hostRules:  map[string][]*filterRule{},
+		tokenRules: map[string][]*filterRule{},
+	}
+}
+
+// set returns the rules of kind, tracker rules are kept first.
+func (f *FilterLists) set(kind string) *filterSet {
+	for _, s := range f.sets {
+		if s.kind == kind {
+			return s
+		}
+	}
+
+	s := &filterSet{kind: kind, block: newFilterIndex(), allow: newFilterIndex()}
+	if kind == FilterKindTracker {
+		f.sets = append([]*filterSet{s}, f.sets...)
+	} else {
+		f.sets = append(f.sets, s)
+	}
+
+	return s
+}
+
+// Load adds the rules of adblock plus list file labelling matched requests with kind.
+func (f *FilterLists) Load(file string, kind string) (err error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		err = errors.Wrapf(err, "open filter list %s failed", file)
+		return
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	return f.Add(fp, filepath.Base(file), kind)
+}
+
+// Add parses the network rules of adblock plus list, element hiding, regular expression and rules with
+// unsupported options are skipped.
+func (f *FilterLists) Add(r io.Reader, list string, kind string) (err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	set := f.set(kind)
+
+	for scanner.Scan() {
+		rule := parseFilterRule(strings.TrimSpace(scanner.Text()))
+		if rule == nil {
+			continue
+		}
+
+		rule.list, rule.kind = list, kind
+		if rule.exception {
+			set.allow.add(rule)
+		} else {
+			set.block.add(rule)
+		}
+		f.count++
+	}
+
+	if err = scanner.Err(); err != nil {
+		err = errors.Wrapf(err, "read filter list %s failed", list)
+	}
+
+	return
+}
+
+// Len returns the number of network rules.
+func (f *FilterLists) Len() int {
+	return f.count
+}
+
+func parseFilterRule(line string) (rule *filterRule) {
+	if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+		return nil
+	}
+	for _, sep := range []string{"##", "#@#", "#?#", "#$#"} {
+		if strings.Contains(line, sep) {
+			// element hiding rules
+			return nil
+		}
+	}
+
+	rule = &filterRule{text: line}
+
+	if strings.HasPrefix(line, "@@") {
+		rule.exception = true
+		line = line[2:]
+	}
+
+	pattern := line
+	if i := strings.LastIndex(line, "$"); i >= 0 {
+		pattern = line[:i]
+		if !rule.parseOptions(line[i+1:]) {
+			return nil
+		}
+	}
+
+	if pattern == "" || (strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") && len(pattern) > 1) {
+		// regular expression rules are not supported
+		return nil
+	}
+
+	pattern = strings.ToLower(pattern)
+
+	if m := filterHostRulePattern.FindStringSubmatch(pattern); m != nil {
+		rule.host = m[1]
+		return
+	}
+
+	var err error
+	if rule.pattern, err = regexp.Compile(filterPatternRegexp(pattern)); err != nil {
+		return nil
+	}
+	rule.token = filterPatternToken(pattern)
+
+	return
+}
+
+// parseOptions parses the comma separated rule options, returns false for unsupported options.
+func (r *filterRule) parseOptions(options string) bool {
+	for _, opt := range strings.Split(strings.ToLower(options), ",") {
+		switch {
+		case opt == "third-party" || opt == "3p" || opt == "~first-party" || opt == "~1p":
+			r.thirdParty = 1
+		case opt == "~third-party" || opt == "~3p" || opt == "first-party" || opt == "1p":
+			r.thirdParty = -1
+		case strings.HasPrefix(opt, "domain="):
+			for _, d := range strings.Split(opt[len("domain="):], "|") {
+				if strings.HasPrefix(d, "~") {
+					r.excludedDomains = append(r.excludedDomains, d[1:])
+				} else if d != "" {
+					r.domains = append(r.domains, d)
+				}
+			}
+		case filterIgnoredOptions[opt]:
+		default:
+			excluded := strings.HasPrefix(opt, "~")
+			types, ok := filterTypes[strings.TrimPrefix(opt, "~")]
+			if !ok {
+				return false
+			}
+
+			for _, t := range types {
+				if excluded {
+					if r.excludedTypes == nil {
+						r.excludedTypes = map[string]bool{}
+					}
+					r.excludedTypes[t] = true
+				} else {
+					if r.types == nil {
+						r.types = map[string]bool{}
+					}
+					r.types[t] = true
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// filterPatternRegexp converts the adblock plus pattern with anchors, wildcards and separators to regular expression.
+func filterPatternRegexp(pattern string) string {
+	buf := new(strings.Builder)
+
+	switch {
+	case strings.HasPrefix(pattern, "||"):
+		buf.WriteString(`^[a-z][a-z0-9+.-]*://([^/?#]*\.)?`)
+		pattern = pattern[2:]
+	case strings.HasPrefix(pattern, "|"):
+		buf.WriteString("^")
+		pattern = pattern[1:]
+	}
+
+	endAnchor := strings.HasSuffix(pattern, "|")
+	pattern = strings.TrimSuffix(pattern, "|")
+
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			buf.WriteString(".*")
+		case '^':
+			buf.WriteString(`(?:[^a-z0-9_.%-]|$)`)
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if endAnchor {
+		buf.WriteString("$")
+	}
+
+	return buf.String()
+}
+
+func isFilterTokenChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '%'
+}
+
+// filterTokens splits the lower case url to words of letters, digits and percent signs.
+func filterTokens(s string) (tokens []string) {
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && isFilterTokenChar(s[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, s[start:i])
+			start = -1
+		}
+	}
+	return
+}
+
+// filterPatternToken returns the longest word of pattern which is a whole word of every matched url,
+// words next to wildcards or unanchored pattern ends could be part of a longer word and are not used.
+func filterPatternToken(pattern string) (token string) {
+	body := pattern
+	startAnchor := strings.HasPrefix(body, "|")
+	body = strings.TrimLeft(body, "|")
+	endAnchor := strings.HasSuffix(body, "|")
+	body = strings.TrimSuffix(body, "|")
+
+	var common string
+	start := -1
+	for i := 0; i <= len(body); i++ {
+		if i < len(body) && isFilterTokenChar(body[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+
+		word := body[start:i]
+		bounded := (start > 0 && body[start-1] != '*') || (start == 0 && startAnchor)
+		bounded = bounded && ((i < len(body) && body[i] != '*') || (i == len(body) && endAnchor))
+		start = -1
+
+		switch {
+		case !bounded || len(word) < 2:
+		case filterCommonTokens[word]:
+			common = word
+		case len(word) > len(token):
+			token = word
+		}
+	}
+
+	if token == "" {
+		token = common
+	}
+
+	return
+}
+
+func (idx *filterIndex) add(r *filterRule) {
+	switch {
+	case r.host != "":
+		idx.hostRules[r.host] = append(idx.hostRules[r.host], r)
+	case r.token != "":
+		idx.tokenRules[r.token] = append(idx.tokenRules[r.token], r)
+	default:
+		idx.rules = append(idx.rules, r)
+	}
+}
+
+// match returns the first rule matching request, rules of the request host and its parent domains are checked
+// before the pattern rules indexed by the words of url.
+func (idx *filterIndex) match(req *filterRequest) *filterRule {
+	for host := req.host; host != ""; {
+		for _, r := range idx.hostRules[host] {
+			if r.matchOptions(req) {
+				return r
+			}
+		}
+
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+
+	lowerURL := strings.ToLower(req.url)
+	seen := map[string]bool{}
+	for _, token := range filterTokens(lowerURL) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+
+		for _, r := range idx.tokenRules[token] {
+			if r.matchOptions(req) && r.pattern.MatchString(lowerURL) {
+				return r
+			}
+		}
+	}
+
+	for _, r := range idx.rules {
+		if r.matchOptions(req) && r.pattern.MatchString(lowerURL) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func matchDomainList(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *filterRule) matchOptions(req *filterRequest) bool {
+	if (r.thirdParty > 0 && !req.thirdParty) || (r.thirdParty < 0 && req.thirdParty) {
+		return false
+	}
+	if r.types != nil && !r.types[req.resourceType] {
+		return false
+	}
+	if r.excludedTypes[req.resourceType] {
+		return false
+	}
+	if len(r.domains) > 0 && !matchDomainList(req.siteHost, r.domains) {
+		return false
+	}
+	if matchDomainList(req.siteHost, r.excludedDomains) {
+		return false
+	}
+	return true
+}
+
+// match returns the blocking rule of request unless an exception rule of the same kind allows it.
+func (f *FilterLists) match(req *filterRequest) *filterMatch {
+	for _, s := range f.sets {
+		rule := s.block.match(req)
+		if rule == nil || s.allow.match(req) != nil {
+			continue
+		}
+
+		return &filterMatch{Kind: rule.kind, List: rule.list, Rule: rule.text}
+	}
+
+	return nil
+}
+
+// contactedTracker is a tracker or ad host contacted by the page.
+type contactedTracker struct {
+	Host              string
+	RegistrableDomain string
+	Kind              string
+	List              string
+	Rule              string
+	Requests          int
+	ThirdParty        bool
+}
+
+// matchFilterLists labels all recorded requests with filter lists, flags the cookies set by matched requests
+// and returns the contacted tracker hosts.
+func (t *Task) matchFilterLists(rc *recordCollector, site string, records []*reportRecord) (
+	matches map[string]*filterMatch, trackers []*contactedTracker) {
+	if t.cfg.FilterLists == nil || t.cfg.FilterLists.Len() == 0 {
+		return
+	}
+
+	var siteHost string
+	if u, err := url.Parse(site); err == nil {
+		siteHost = u.Hostname()
+	}
+
+	matches = map[string]*filterMatch{}
+	hostTrackers := map[string]*contactedTracker{}
+
+	for _, reqRecords := range rc.get() {
+		for _, r := range reqRecords {
+			if !r.isRequest {
+				continue
+			}
+
+			req, _ := r.params["request"].(map[string]interface{})
+			reqURL, _ := req["url"].(string)
+			u, err := url.Parse(reqURL)
+			if err != nil || u.Hostname() == "" {
+				continue
+			}
+
+			fr := &filterRequest{
+				url:        reqURL,
+				host:       strings.ToLower(u.Hostname()),
+				thirdParty: isThirdParty(siteHost, u.Hostname()),
+				siteHost:   siteHost,
+			}
+			fr.resourceType, _ = r.params["type"].(string)
+
+			m := t.cfg.FilterLists.match(fr)
+			if m == nil {
+				continue
+			}
+
+			matches[stripURLFragment(reqURL)] = m
+
+			tracker, ok := hostTrackers[fr.host]
+			if !ok {
+				tracker = &contactedTracker{
+					Host:              fr.host,
+					RegistrableDomain: registrableDomain(fr.host),
+					Kind:              m.Kind,
+					List:              m.List,
+					Rule:              m.Rule,
+					ThirdParty:        fr.thirdParty,
+				}
+				hostTrackers[fr.host] = tracker
+				trackers = append(trackers, tracker)
+			}
+			tracker.Requests++
+		}
+	}
+
+	sort.SliceStable(trackers, func(i, j int) bool {
+		if trackers[i].Requests != trackers[j].Requests {
+			return trackers[i].Requests > trackers[j].Requests
+		}
+		return trackers[i].Host < trackers[j].Host
+	})
+
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			if c.URL == "" {
+				continue
+			}
+			if m := matches[stripURLFragment(c.URL)]; m != nil {
+				c.Tracker = true
+				c.TrackerKind = m.Kind
+				c.TrackerRule = m.Rule
+			}
+		}
+	}
+
+	return
+}
+
+// labelFilterMatches sets the matching filter rules on initiator tree.
+func labelFilterMatches(nodes []*initiatorNode, matches map[string]*filterMatch) {
+	for _, n := range nodes {
+		if m := matches[n.URL]; m != nil {
+			n.FilterKind, n.FilterRule = m.Kind, m.Rule
+		}
+		labelFilterMatches(n.Children, matches)
+	}
+}
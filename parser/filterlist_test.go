@@ -0,0 +1,157 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestFilterLists(t *testing.T, lists ...[2]string) *FilterLists {
+	f := NewFilterLists()
+	for _, l := range lists {
+		if err := f.Add(strings.NewReader(l[1]), l[0]+".txt", l[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func testFilterRequest(reqURL string, resourceType string, site string) *filterRequest {
+	u, _ := url.Parse(reqURL)
+	return &filterRequest{
+		url:          reqURL,
+		host:         strings.ToLower(u.Hostname()),
+		resourceType: resourceType,
+		thirdParty:   isThirdParty(site, u.Hostname()),
+		siteHost:     site,
+	}
+}
+
+type filterCase struct {
+	url          string
+	resourceType string
+	site         string
+	rule         string
+	kind         string
+}
+
+func checkFilterCases(t *testing.T, f *FilterLists, cases []filterCase) {
+	for _, c := range cases {
+		m := f.match(testFilterRequest(c.url, c.resourceType, c.site))
+		switch {
+		case c.rule == "" && m != nil:
+			t.Errorf("%s %s on %s: got rule %s, want no match", c.resourceType, c.url, c.site, m.Rule)
+		case c.rule != "" && m == nil:
+			t.Errorf("%s %s on %s: got no match, want rule %s", c.resourceType, c.url, c.site, c.rule)
+		case c.rule != "" && (m.Rule != c.rule || m.Kind != c.kind):
+			t.Errorf("%s %s on %s: got %s rule %s, want %s rule %s",
+				c.resourceType, c.url, c.site, m.Kind, m.Rule, c.kind, c.rule)
+		}
+	}
+}
+
+func TestFilterListsOptions(t *testing.T) {
+	f := newTestFilterLists(t, [2]string{FilterKindAd, `! comment
+[Adblock Plus 2.0]
+example.org##.banner
+/ads/[0-9]+/
+||ads.example^
+||cdn.example^$third-party
+||self.example^$~third-party
+/pixel.gif$image
+/loader.js$script,domain=shop.example|~old.shop.example
+||widget.example/embed/$subdocument
+ads.js
+@@||ads.example/allowed/
+@@/pixel.gif$image,domain=friend.example
+`})
+
+	if f.Len() != 9 {
+		t.Errorf("rules: got %d, want 9", f.Len())
+	}
+
+	checkFilterCases(t, f, []filterCase{
+		// || anchors match the host and sub domains only
+		{"https://ads.example/banner.png", "Image", "site.example", "||ads.example^", FilterKindAd},
+		{"https://img.ads.example/banner.png", "Image", "site.example", "||ads.example^", FilterKindAd},
+		{"https://badads.example/banner.png", "Image", "site.example", "", ""},
+		{"https://site.example/?ref=ads.example", "Document", "site.example", "", ""},
+		{"https://widget.example/embed/1", "Document", "site.example", "||widget.example/embed/$subdocument", FilterKindAd},
+		{"https://www.widget.example/embed/1", "Document", "site.example", "||widget.example/embed/$subdocument", FilterKindAd},
+		{"https://widget.example/embed/1", "Script", "site.example", "", ""},
+		// $third-party
+		{"https://cdn.example/lib.js", "Script", "site.example", "||cdn.example^$third-party", FilterKindAd},
+		{"https://cdn.example/lib.js", "Script", "www.cdn.example", "", ""},
+		{"https://self.example/lib.js", "Script", "www.self.example", "||self.example^$~third-party", FilterKindAd},
+		{"https://self.example/lib.js", "Script", "site.example", "", ""},
+		// resource types
+		{"https://t.example/pixel.gif?id=1", "Image", "site.example", "/pixel.gif$image", FilterKindAd},
+		{"https://t.example/pixel.gif?id=1", "Script", "site.example", "", ""},
+		{"https://t.example/js/loader.js", "Script", "www.shop.example", "/loader.js$script,domain=shop.example|~old.shop.example", FilterKindAd},
+		{"https://t.example/js/loader.js", "Image", "www.shop.example", "", ""},
+		// $domain= limits the sites
+		{"https://t.example/js/loader.js", "Script", "old.shop.example", "", ""},
+		{"https://t.example/js/loader.js", "Script", "site.example", "", ""},
+		// unindexed patterns match inside words
+		{"https://t.example/myads.js", "Script", "site.example", "ads.js", FilterKindAd},
+		// @@ exceptions
+		{"https://ads.example/allowed/banner.png", "Image", "site.example", "", ""},
+		{"https://t.example/pixel.gif", "Image", "www.friend.example", "", ""},
+	})
+}
+
+func TestFilterListsTrackerPrecedence(t *testing.T) {
+	f := newTestFilterLists(t,
+		[2]string{FilterKindAd, `||analytics.example^
+@@||metrics.example^
+`},
+		[2]string{FilterKindTracker, `/collect?
+||metrics.example^
+`})
+
+	checkFilterCases(t, f, []filterCase{
+		// tracker pattern rules win over ad host rules though ad lists are loaded first
+		{"https://analytics.example/collect?v=1", "XHR", "site.example", "/collect?", FilterKindTracker},
+		{"https://analytics.example/ad.js", "Script", "site.example", "||analytics.example^", FilterKindAd},
+		// exceptions of ad lists do not allow tracker requests
+		{"https://metrics.example/m.js", "Script", "site.example", "||metrics.example^", FilterKindTracker},
+	})
+}
+
+func TestFilterPatternToken(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		token   string
+	}{
+		{"||doubleclick.net/pagead", "doubleclick"},
+		{"/banner/ads/*", "banner"},
+		{"/collect?", "collect"},
+		{"ads.js", ""},
+		{"*pixel*", ""},
+		{"/pixel.gif", "pixel"},
+		{"|https://www.example.com/", "example"},
+		{"|https://www.", "www"},
+		{"/adframe|", "adframe"},
+		{"&utm_", "utm"},
+	} {
+		if token := filterPatternToken(c.pattern); token != c.token {
+			t.Errorf("token of %s: got %q, want %q", c.pattern, token, c.token)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mmdbMetadataMarker starts the metadata section at the end of MaxMind DB files.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbMaxDepth limits nested maps and arrays, a corrupted database could point a value to its own container.
+const mmdbMaxDepth = 32
+
+const (
+	mmdbTypeExtended = iota
+	mmdbTypePointer
+	mmdbTypeString
+	mmdbTypeDouble
+	mmdbTypeBytes
+	mmdbTypeUint16
+	mmdbTypeUint32
+	mmdbTypeMap
+	mmdbTypeInt32
+	mmdbTypeUint64
+	mmdbTypeUint128
+	mmdbTypeArray
+	mmdbTypeContainer
+	mmdbTypeEndMarker
+	mmdbTypeBool
+	mmdbTypeFloat
+)
+
+// eeaCountries contains the EU member states and the EEA countries Iceland, Liechtenstein and Norway.
+var eeaCountries = map[string]bool{
+	"AT": true, "BE": true, "BG": true, "HR": true, "CY": true, "CZ": true, "DK": true,
+	"EE": true, "FI": true, "FR": true, "DE": true, "GR": true, "HU": true, "IE": true,
+	"IT": true, "LV": true, "LT": true, "LU": true, "MT": true, "NL": true, "PL": true,
+	"PT": true, "RO": true, "SK": true, "SI": true, "ES": true, "SE": true,
+	"IS": true, "LI": true, "NO": true,
+}
+
+// geoLocation is the location and network of a server address.
+type geoLocation struct {
+	IP             string
+	Country        string
+	CountryName    string
+	Continent      string
+	ASN            uint
+	ASOrganization string
+	// EEA is set for servers located in the EU/EEA, transfers to other countries need safeguards under GDPR.
+	EEA bool
+}
+
+// Network returns the autonomous system as "AS<number> <organization>".
+func (l *geoLocation) Network() string {
+	if l == nil || l.ASN == 0 {
+		return ""
+	}
+	return strings.TrimSpace("AS" + strconv.FormatUint(uint64(l.ASN), 10) + " " + l.ASOrganization)
+}
+
+// Label returns the country and network of the location.
+func (l *geoLocation) Label() string {
+	if l == nil {
+		return ""
+	}
+
+	var parts []string
+	if l.Country != "" {
+		parts = append(parts, l.Country)
+	}
+	if network := l.Network(); network != "" {
+		parts = append(parts, network)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// mmdbDecoder decodes the MaxMind DB data section format.
+type mmdbDecoder struct {
+	buf []byte
+}
+
+type mmdbReader struct {
+	tree       []byte
+	data       mmdbDecoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+	dbType     string
+}
+
+// GeoIP looks up server addresses in MaxMind format databases like GeoLite2-Country/City and GeoLite2-ASN.
+type GeoIP struct {
+	dbs []*mmdbReader
+}
+
+// LoadGeoIP opens MaxMind format database files, country and asn fields are merged from all databases.
+func LoadGeoIP(files ...string) (g *GeoIP, err error) {
+	g = &GeoIP{}
+
+	for _, file := range files {
+		var buf []byte
+		if buf, err = ioutil.ReadFile(file); err != nil {
+			err = errors.Wrapf(err, "read geoip database %s failed", file)
+			return
+		}
+
+		var r *mmdbReader
+		if r, err = parseMMDB(buf); err != nil {
+			err = errors.Wrapf(err, "parse geoip database %s failed", file)
+			return
+		}
+
+		g.dbs = append(g.dbs, r)
+	}
+
+	return
+}
+
+// Databases returns the database types like GeoLite2-Country.
+func (g *GeoIP) Databases() (types []string) {
+	if g == nil {
+		return
+	}
+	for _, r := range g.dbs {
+		types = append(types, r.dbType)
+	}
+	return
+}
+
+// Lookup returns the location of the address, nil if the address is not found in any database.
+func (g *GeoIP) Lookup(addr string) (l *geoLocation) {
+	if g == nil {
+		return
+	}
+
+	addr = strings.Trim(addr, "[]")
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return
+	}
+
+	for _, r := range g.dbs {
+		v, err := r.lookup(ip)
+		if err != nil || v == nil {
+			continue
+		}
+
+		if l == nil {
+			l = &geoLocation{IP: addr}
+		}
+
+		country := mmdbMap(v, "country")
+		if country == nil {
+			// anycast and satellite networks only carry the registered country
+			country = mmdbMap(v, "registered_country")
+		}
+		if code := mmdbString(country, "iso_code"); code != "" && l.Country == "" {
+			l.Country = code
+			l.CountryName = mmdbString(mmdbMap(country, "names"), "en")
+			l.EEA = eeaCountries[code]
+		}
+		if code := mmdbString(mmdbMap(v, "continent"), "code"); code != "" && l.Continent == "" {
+			l.Continent = code
+		}
+		record, _ := v.(map[string]interface{})
+		if asn, ok := record["autonomous_system_number"].(uint64); ok && l.ASN == 0 {
+			l.ASN = uint(asn)
+			l.ASOrganization = mmdbString(v, "autonomous_system_organization")
+		}
+	}
+
+	return
+}
+
+func mmdbMap(v interface{}, key string) interface{} {
+	m, _ := v.(map[string]interface{})
+	if r, ok := m[key].(map[string]interface{}); ok {
+		return r
+	}
+	return nil
+}
+
+func mmdbString(v interface{}, key string) (s string) {
+	m, _ := v.(map[string]interface{})
+	s, _ = m[key].(string)
+	return
+}
+
+func parseMMDB(buf []byte) (r *mmdbReader, err error) {
+	metaStart := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if metaStart < 0 {
+		err = errors.New("metadata section not found")
+		return
+	}
+
+	metaDecoder := mmdbDecoder{buf: buf[metaStart+len(mmdbMetadataMarker):]}
+	v, _, err := metaDecoder.decode(0)
+	if err != nil {
+		err = errors.Wrap(err, "decode metadata failed")
+		return
+	}
+	meta, ok := v.(map[string]interface{})
+	if !ok {
+		err = errors.New("invalid metadata")
+		return
+	}
+
+	metaUint := func(key string) uint {
+		n, _ := meta[key].(uint64)
+		return uint(n)
+	}
+
+	r = &mmdbReader{
+		nodeCount:  metaUint("node_count"),
+		recordSize: metaUint("record_size"),
+		ipVersion:  metaUint("ip_version"),
+		dbType:     mmdbString(meta, "database_type"),
+	}
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		err = errors.Errorf("unsupported record size %d", r.recordSize)
+		return
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(metaStart) {
+		err = errors.New("search tree exceeds database size")
+		return
+	}
+
+	r.tree = buf[:treeSize]
+	r.data = mmdbDecoder{buf: buf[treeSize+16 : metaStart]}
+
+	// ipv4 addresses are stored in the ::/96 subtree of ipv6 databases
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			if r.ipv4Start, err = r.readNode(r.ipv4Start, 0); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (r *mmdbReader) readNode(node uint, bit uint) (record uint, err error) {
+	size := r.recordSize / 4
+	offset := node * size
+	if offset+size > uint(len(r.tree)) {
+		err = errors.New("invalid search tree node")
+		return
+	}
+	b := r.tree[offset : offset+size]
+
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		record = uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			record = uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		} else {
+			record = uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+		}
+	case 32:
+		record = uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+
+	return
+}
+
+// lookup returns the data record of ip address, nil if not found.
+func (r *mmdbReader) lookup(ip net.IP) (v interface{}, err error) {
+	node := uint(0)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return
+	}
+
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		if node, err = r.readNode(node, bit); err != nil {
+			return
+		}
+	}
+
+	if node <= r.nodeCount {
+		// equal to node count means not found
+		return
+	}
+
+	v, _, err = r.data.decode(node - r.nodeCount - 16)
+	return
+}
+
+func (d *mmdbDecoder) bytes(offset uint, size uint) (b []byte, err error) {
+	if offset+size > uint(len(d.buf)) {
+		err = errors.New("unexpected end of data")
+		return
+	}
+	b = d.buf[offset : offset+size]
+	return
+}
+
+func (d *mmdbDecoder) decode(offset uint) (v interface{}, next uint, err error) {
+	return d.decodeValue(offset, 0, true)
+}
+
+func (d *mmdbDecoder) decodeValue(offset uint, depth int, followPointer bool) (v interface{}, next uint, err error) {
+	if depth > mmdbMaxDepth {
+		err = errors.New("data structure too deep")
+		return
+	}
+
+	b, err := d.bytes(offset, 1)
+	if err != nil {
+		return
+	}
+	ctrl := b[0]
+	next = offset + 1
+	typ := uint(ctrl >> 5)
+
+	if typ == mmdbTypePointer {
+		if !followPointer {
+			err = errors.New("pointer to pointer")
+			return
+		}
+
+		var ptr uint
+		if ptr, next, err = d.pointer(ctrl, next); err != nil {
+			return
+		}
+		v, _, err = d.decodeValue(ptr, depth, false)
+		return
+	}
+
+	if typ == mmdbTypeExtended {
+		if b, err = d.bytes(next, 1); err != nil {
+			return
+		}
+		typ = 7 + uint(b[0])
+		next++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if b, err = d.bytes(next, n); err != nil {
+			return
+		}
+		next += n
+		switch size {
+		case 29:
+			size = 29 + uint(b[0])
+		case 30:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		case 31:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+	}
+
+	switch typ {
+	case mmdbTypeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			if key, next, err = d.decodeValue(next, depth+1, true); err != nil {
+				return
+			}
+			if value, next, err = d.decodeValue(next, depth+1, true); err != nil {
+				return
+			}
+			k, ok := key.(string)
+			if !ok {
+				err = errors.New("invalid map key")
+				return
+			}
+			m[k] = value
+		}
+		v = m
+		return
+	case mmdbTypeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			if value, next, err = d.decodeValue(next, depth+1, true); err != nil {
+				return
+			}
+			a = append(a, value)
+		}
+		v = a
+		return
+	case mmdbTypeBool:
+		v = size != 0
+		return
+	case mmdbTypeContainer, mmdbTypeEndMarker:
+		err = errors.Errorf("unsupported data type %d", typ)
+		return
+	}
+
+	if b, err = d.bytes(next, size); err != nil {
+		return
+	}
+	next += size
+
+	switch typ {
+	case mmdbTypeString:
+		v = string(b)
+	case mmdbTypeBytes:
+		v = append([]byte(nil), b...)
+	case mmdbTypeDouble:
+		if size != 8 {
+			err = errors.New("invalid double size")
+			return
+		}
+		v = math.Float64frombits(binary.BigEndian.Uint64(b))
+	case mmdbTypeFloat:
+		if size != 4 {
+			err = errors.New("invalid float size")
+			return
+		}
+		v = float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case mmdbTypeUint16, mmdbTypeUint32, mmdbTypeUint64:
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		v = n
+	case mmdbTypeInt32:
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		v = int64(int32(n))
+	case mmdbTypeUint128:
+		v = new(big.Int).SetBytes(b)
+	default:
+		err = errors.Errorf("unknown data type %d", typ)
+	}
+
+	return
+}
+
+func (d *mmdbDecoder) pointer(ctrl byte, offset uint) (ptr uint, next uint, err error) {
+	n := uint(ctrl>>3)&0x3 + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return
+	}
+	next = offset + n
+
+	vvv := uint(ctrl & 0x7)
+	switch n {
+	case 1:
+		ptr = vvv<<8 | uint(b[0])
+	case 2:
+		ptr = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		ptr = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	case 4:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+
+	return
+}
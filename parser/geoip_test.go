@@ -0,0 +1,139 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// fixtures are written by testdata/gen_mmdb.go
+func TestGeoIPLookup(t *testing.T) {
+	for _, file := range []string{"geoip-ipv6-24.mmdb", "geoip-ipv6-28.mmdb", "geoip-ipv6-32.mmdb", "geoip-ipv4-24.mmdb"} {
+		g, err := LoadGeoIP(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+
+		if dbs := g.Databases(); len(dbs) != 1 || dbs[0] != "Test-GeoIP" {
+			t.Errorf("%s: got databases %v", file, dbs)
+		}
+
+		ipv6 := g.dbs[0].ipVersion == 6
+
+		for _, c := range []struct {
+			addr   string
+			label  string
+			name   string
+			eea    bool
+			found  bool
+			ipv6DB bool
+		}{
+			{"81.2.69.160", "GB, AS64500 Example Networks", "United Kingdom", false, true, false},
+			{"::ffff:81.2.69.160", "GB, AS64500 Example Networks", "United Kingdom", false, true, false},
+			// anycast network without country
+			{"203.0.113.7", "US", "United States", false, true, false},
+			{"2001:db8::1", "DE", "Germany", true, true, true},
+			{"[2001:db8:ffff::1]", "DE", "Germany", true, true, true},
+			{"81.2.70.1", "", "", false, false, false},
+			{"2001:db9::1", "", "", false, false, true},
+			{"not an ip", "", "", false, false, false},
+		} {
+			found := c.found && (ipv6 || !c.ipv6DB)
+
+			l := g.Lookup(c.addr)
+			if !found {
+				if l != nil {
+					t.Errorf("%s: %s should not be found, got %s", file, c.addr, l.Label())
+				}
+				continue
+			}
+
+			if l == nil {
+				t.Errorf("%s: %s not found", file, c.addr)
+				continue
+			}
+			if l.Label() != c.label || l.CountryName != c.name || l.EEA != c.eea {
+				t.Errorf("%s: %s got %s %s eea %v, want %s %s eea %v",
+					file, c.addr, l.Label(), l.CountryName, l.EEA, c.label, c.name, c.eea)
+			}
+		}
+	}
+}
+
+func TestMMDBReadNode(t *testing.T) {
+	for _, c := range []struct {
+		recordSize  uint
+		node        []byte
+		left, right uint
+	}{
+		{24, []byte{0x12, 0x34, 0x56, 0x65, 0x43, 0x21}, 0x123456, 0x654321},
+		{28, []byte{0x12, 0x34, 0x56, 0xab, 0x65, 0x43, 0x21}, 0xa123456, 0xb654321},
+		{32, []byte{0xf1, 0x23, 0x45, 0x67, 0x76, 0x54, 0x32, 0x1f}, 0xf1234567, 0x7654321f},
+	} {
+		r := &mmdbReader{tree: c.node, recordSize: c.recordSize}
+
+		for bit, want := range []uint{c.left, c.right} {
+			record, err := r.readNode(0, uint(bit))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record != want {
+				t.Errorf("%d bit record %d: got %x, want %x", c.recordSize, bit, record, want)
+			}
+		}
+
+		if _, err := r.readNode(1, 0); err == nil {
+			t.Errorf("%d bit record: node out of tree should be rejected", c.recordSize)
+		}
+	}
+}
+
+func TestMMDBDecodeInvalidPointer(t *testing.T) {
+	for name, buf := range map[string][]byte{
+		// {"a": pointer to the map itself}
+		"loop": {0xe1, 0x41, 'a', 0x20, 0x00},
+		// pointer to another pointer
+		"pointer to pointer": {0x20, 0x02, 0x20, 0x00},
+		// pointer out of data
+		"out of data": {0x20, 0x10},
+	} {
+		d := &mmdbDecoder{buf: buf}
+		if _, _, err := d.decode(0); err == nil {
+			t.Errorf("%s should be rejected", name)
+		}
+	}
+
+	// pointers to values are followed
+	d := &mmdbDecoder{buf: []byte{0xe1, 0x41, 'a', 0x20, 0x05, 0x42, 'o', 'k'}}
+	v, next, err := d.decode(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mmdbString(v, "a") != "ok" || next != 5 {
+		t.Errorf("got %v next %d, want map of ok next 5", v, next)
+	}
+}
+
+func TestLoadGeoIPInvalid(t *testing.T) {
+	if _, err := LoadGeoIP(filepath.Join("testdata", "replay.har")); err == nil {
+		t.Error("file without metadata should be rejected")
+	}
+	if _, err := LoadGeoIP(filepath.Join("testdata", "missing.mmdb")); err == nil {
+		t.Error("missing file should be rejected")
+	}
+}
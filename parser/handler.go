@@ -17,18 +17,10 @@
 package parser
 
 import (
-	"encoding/base64"
 	"fmt"
-	"io/ioutil"
 	"net/url"
-	"os"
-	"os/exec"
-	"runtime"
-	"strings"
-	"syscall"
 	"time"
 
-	"github.com/gobs/args"
 	"github.com/pkg/errors"
 	"github.com/raff/godet"
 	"github.com/sirupsen/logrus"
@@ -37,110 +29,21 @@ import (
 func (t *Task) Start() (err error) {
 	t.startTime = time.Now().UTC()
 
-	if t.cfg.ChromeApp == "" {
-		var chromeapp string
-
-		switch runtime.GOOS {
-		case "darwin":
-			for _, c := range []string{
-				"/Applications/Google Chrome Canary.app/Contents/MacOS/Google Chrome Canary",
-				"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
-			} {
-				// MacOS apps are actually folders
-				if _, err := exec.LookPath(c); err == nil {
-					chromeapp = fmt.Sprintf("%q", c)
-					break
-				}
-			}
-
-		case "linux":
-			for _, c := range []string{
-				"headless_shell",
-				"chromium",
-				"chromium-browser",
-				"google-chrome-beta",
-				"google-chrome-unstable",
-				"google-chrome-stable"} {
-				if _, err := exec.LookPath(c); err == nil {
-					chromeapp = c
-					break
-				}
-			}
-
-		case "windows":
-		}
-
-		if chromeapp != "" {
-			if chromeapp == "headless_shell" {
-				chromeapp += " --no-sandbox"
-			} else {
-				chromeapp += " --headless"
-			}
-
-			chromeapp += fmt.Sprintf(" --remote-debugging-port=%d --no-default-browser-check --no-first-run --hide-scrollbars --bwsi --disable-gpu",
-				t.cfg.DebuggerPort)
-
-			if dir, err := ioutil.TempDir("", "gdpr_cookie"); err == nil {
-				t.userDir = dir
-				chromeapp += " --user-data-dir="
-				chromeapp += dir
-			}
-
-			chromeapp += " about:blank"
-		}
-
-		t.cfg.ChromeApp = chromeapp
-	}
-
-	// start debugger
-	if !t.cfg.Headless {
-		t.cfg.ChromeApp = strings.Replace(t.cfg.ChromeApp, "--headless", "", -1)
+	if t.driver = t.cfg.Driver; t.driver == nil {
+		t.driver = newGodetDriver(t.cfg)
 	}
 
-	if t.cfg.ChromeApp == "" {
-		err = errors.New("no chrome application available")
-		return
-	}
-
-	parts := args.GetArgs(t.cfg.ChromeApp)
-	cmd := exec.Command(parts[0], parts[1:]...)
-	if err = cmd.Start(); err != nil {
-		return
-	}
-
-	t.debugger = cmd
-
-	// connect debugger
-	for i := 0; i < 10; i++ {
-		time.Sleep(500 * time.Millisecond)
-
-		t.remote, err = godet.Connect(fmt.Sprintf("localhost:%d", t.cfg.DebuggerPort), t.cfg.Verbose)
-		if err == nil {
-			break
-		}
-
-		logrus.WithError(err).Debug("connect to debugger failed")
-	}
-
-	return
+	return t.driver.Start()
 }
 
 func (t *Task) Cleanup() {
-	if t.userDir != "" {
-		_ = os.RemoveAll(t.userDir)
-	}
-	if t.remote != nil {
-		t.remote.CloseBrowser()
-		_ = t.remote.Close()
-	}
-	if t.debugger != nil {
-		_ = t.debugger.Process.Signal(syscall.SIGTERM)
-		_ = t.debugger.Wait()
+	if t.driver != nil {
+		_ = t.driver.Close()
 	}
 }
 
 func (t *Task) Version() (*godet.Version, error) {
-	return t.remote.Version()
+	return t.driver.Version()
 }
 
 func (t *Task) Parse(site string) (err error) {
@@ -156,40 +59,28 @@ func (t *Task) Parse(site string) (err error) {
 
 	site = siteURL.String()
 	rc := newRecordCollector()
+	trace := newTraceWriter(t.cfg.Trace)
+	trace.scan(site, t.startTime)
+
+	// events are recorded to trace and collected before running the callback
+	on := func(method string, cb godet.EventCallback) {
+		t.driver.Subscribe(method, func(params godet.Params) {
+			trace.event(method, params)
+			rc.handleEvent(method, params)
+			if cb != nil {
+				cb(params)
+			}
+		})
+	}
 
-	// request callbacks
-	t.remote.CallbackEvent("Network.requestWillBeSent", func(params godet.Params) {
-		req := params.Map("request")
-		var reqURL string
-		if rawReqURL, ok := req["url"]; !ok {
-			return
-		} else if reqURL, ok = rawReqURL.(string); !ok {
-			return
-		}
-		if strings.HasPrefix(reqURL, "data:") {
-			// data uri is ignored
-			return
-		}
-
-		rc.addRequest(params)
-	})
-
-	// response callbacks
-	t.remote.CallbackEvent("Network.responseReceived", func(params godet.Params) {
-		resp := params.Map("response")
-		var respURL string
-		if rawRespURL, ok := resp["url"]; !ok {
-			return
-		} else if respURL, ok = rawRespURL.(string); !ok {
-			return
-		}
-		if strings.HasPrefix(respURL, "data:") {
-			// data uri is ignored
-			return
+	for _, method := range collectedEvents {
+		on(method, nil)
+	}
+	if t.cfg.Trace != nil {
+		for _, method := range tracedEvents {
+			on(method, nil)
 		}
-
-		rc.addResponse(params)
-	})
+	}
 
 	pageWait := make(chan struct{}, 1)
 
@@ -203,7 +94,7 @@ func (t *Task) Parse(site string) (err error) {
 	defer tm.Stop()
 
 	// page stopped loading event
-	t.remote.CallbackEvent("Page.frameStoppedLoading", func(params godet.Params) {
+	on("Page.frameStoppedLoading", func(params godet.Params) {
 		logrus.WithField("site", site).Debug("page frame stopped loading")
 		go func() {
 			time.Sleep(t.cfg.WaitAfterPageLoad)
@@ -215,7 +106,7 @@ func (t *Task) Parse(site string) (err error) {
 	})
 
 	// page load event fired
-	t.remote.CallbackEvent("Page.loadEventFired", func(params godet.Params) {
+	on(pageEventLoad, func(params godet.Params) {
 		logrus.WithField("site", site).Debug("page load fired")
 		go func() {
 			time.Sleep(t.cfg.WaitAfterPageLoad)
@@ -228,12 +119,12 @@ func (t *Task) Parse(site string) (err error) {
 
 	// debugger log
 	if t.cfg.Verbose {
-		t.remote.CallbackEvent("Log.entryAdded", func(params godet.Params) {
+		on("Log.entryAdded", func(params godet.Params) {
 			logrus.WithFields(logrus.Fields(params.Map("entry"))).WithField("site", site).Debug("debugger logged")
 		})
 
 		// console log
-		t.remote.CallbackEvent("Runtime.consoleAPICalled", func(params godet.Params) {
+		on("Runtime.consoleAPICalled", func(params godet.Params) {
 			f := logrus.Fields{
 				"type": params["type"].(string),
 			}
@@ -273,15 +164,7 @@ func (t *Task) Parse(site string) (err error) {
 		})
 	}
 
-	_ = t.remote.RuntimeEvents(true)
-	_ = t.remote.NetworkEvents(true)
-	_ = t.remote.PageEvents(true)
-	_ = t.remote.DOMEvents(true)
-	_ = t.remote.LogEvents(true)
-	_ = t.remote.EmulationEvents(true)
-	//_ = remote.EnableRequestInterception(true)
-
-	_, err = t.remote.Navigate(site)
+	err = t.driver.Navigate(site)
 
 	if err != nil {
 		err = errors.Wrap(err, "send request failed")
@@ -290,30 +173,83 @@ func (t *Task) Parse(site string) (err error) {
 
 	<-pageWait
 
+	// load all cookies from browser api
+	cookies, err := t.driver.Cookies()
+	if err != nil {
+		err = errors.Wrapf(err, "get all cookies from debugger failed")
+		return
+	}
+
+	trace.cookies(cookies)
+	rc.setCookies(cookies)
+
+	if err = trace.Err(); err != nil {
+		logrus.WithError(err).WithField("site", site).Warning("write trace failed")
+		err = nil
+	}
+
+	if err = t.analyze(rc, site); err != nil {
+		return
+	}
+
+	// take snapshots of current page
+	t.reportData.Screenshots = t.takeScreenshots(site)
+	if s := t.reportData.Screenshot(ScreenshotPage); s != nil {
+		t.reportData.ScreenShotImage = s.Image
+	}
+
+	return
+}
+
+// analyze builds the report from collected events, it is shared by live scans and recording replays.
+func (t *Task) analyze(rc *recordCollector, site string) (err error) {
 	// parse response
 	var (
 		cookieCount   int
 		reportRecords []*reportRecord
+		timeline      *reportTimeline
 	)
-	cookieCount, reportRecords, err = t.parseResponse(rc)
+	cookieCount, reportRecords, timeline, err = t.parseResponse(rc)
 	if err != nil {
 		return
 	}
 
+	t.records = rc
+
+	siteDomain, firstParty, thirdParty := classifyParties(site, reportRecords)
+	cloaked := t.detectCNAMECloaking(reportRecords)
+	filterMatches, trackers := t.matchFilterLists(rc, site, reportRecords)
+	firstParty, thirdParty = firstParty-cloaked, thirdParty+cloaked
+
+	t.recordUnknownCookies(site, reportRecords)
+
 	// assemble with other page info
 	t.reportData = &reportData{
-		ScanTime:    t.startTime,
-		ScanURL:     site,
-		CookieCount: cookieCount,
-		Records:     reportRecords,
+		Lang:              NormalizeLanguage(t.cfg.Lang),
+		ScanTime:          t.startTime,
+		ScanURL:           site,
+		SiteDomain:        siteDomain,
+		CookieCount:       cookieCount,
+		FirstPartyCount:   firstParty,
+		ThirdPartyCount:   thirdParty,
+		CNAMECloakedCount: cloaked,
+		Timeline:          timeline,
+		Initiators:        newInitiatorTree(rc, site, reportRecords),
+		TrackersContacted: trackers,
+		ThirdPartyHosts:   collectThirdPartyRequests(rc, site),
+		GeoIPDatabases:    t.cfg.GeoIP.Databases(),
+		Records:           reportRecords,
+		Branding:          t.cfg.Branding,
 	}
 
-	// take snapshot of current page
-	screenShotImage, err := t.remote.CaptureScreenshot("png", 0, true)
-	if err == nil {
-		t.reportData.ScreenShotImage = base64.StdEncoding.EncodeToString(screenShotImage)
-	} else {
-		err = nil
+	labelFilterMatches(t.reportData.Initiators, filterMatches)
+
+	var hostLocations map[string]*geoLocation
+	t.reportData.DataTransfers, hostLocations = t.locateServers(rc, site, reportRecords, t.reportData.ThirdPartyHosts)
+	labelLocations(t.reportData.Initiators, hostLocations)
+
+	if t.cfg.Classifier != nil {
+		t.reportData.ClassifierVersion = t.cfg.Classifier.Version()
 	}
 
 	return
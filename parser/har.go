@@ -0,0 +1,537 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/jsonq"
+	"github.com/pkg/errors"
+)
+
+const (
+	harVersion        = "1.2"
+	harCreatorName    = "CookieScanner"
+	harCreatorVersion = "1.0"
+	harPageID         = "page_1"
+)
+
+// har types follow the HTTP Archive 1.2 specification, custom fields are prefixed with underscore.
+type harFile struct {
+	Log *harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator *harCreator `json:"creator"`
+	Browser *harCreator `json:"browser,omitempty"`
+	Pages   []*harPage  `json:"pages"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime time.Time       `json:"startedDateTime"`
+	ID              string          `json:"id"`
+	Title           string          `json:"title"`
+	PageTimings     *harPageTimings `json:"pageTimings"`
+}
+
+type harPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type harEntry struct {
+	Pageref         string        `json:"pageref"`
+	StartedDateTime time.Time     `json:"startedDateTime"`
+	Time            float64       `json:"time"`
+	Request         *harRequest   `json:"request"`
+	Response        *harResponse  `json:"response"`
+	Cache           struct{}      `json:"cache"`
+	Timings         *harTimings   `json:"timings"`
+	ServerIPAddress string        `json:"serverIPAddress,omitempty"`
+	Connection      string        `json:"connection,omitempty"`
+	Initiator       *harInitiator `json:"_initiator,omitempty"`
+	ResourceType    string        `json:"_resourceType,omitempty"`
+	TransferSize    int           `json:"_transferSize"`
+	Error           string        `json:"_error,omitempty"`
+
+	reqTs     float64
+	respTs    float64
+	timing    map[string]interface{}
+	reqHeader map[string]interface{}
+}
+
+type harInitiator struct {
+	Type       string `json:"type"`
+	URL        string `json:"url,omitempty"`
+	LineNumber int    `json:"lineNumber,omitempty"`
+}
+
+type harRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harCookie    `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	QueryString []*harNameValue `json:"queryString"`
+	PostData    *harPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harCookie    `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	Content     *harContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly"`
+	Secure   bool   `json:"secure"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+func harHeaders(headers map[string]interface{}) (list []*harNameValue) {
+	list = []*harNameValue{}
+	for k, vs := range headers {
+		s, _ := vs.(string)
+		for _, v := range strings.Split(s, "\n") {
+			list = append(list, &harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return
+}
+
+func harHeader(headers map[string]interface{}, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			s, _ := v.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+func harCookies(cookies []*http.Cookie) (list []*harCookie) {
+	list = []*harCookie{}
+	for _, c := range cookies {
+		hc := &harCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.Format(time.RFC3339)
+		}
+		list = append(list, hc)
+	}
+	return
+}
+
+// harQueryString returns the query parameters in url order.
+func harQueryString(rawURL string) (list []*harNameValue) {
+	list = []*harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return
+	}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		nv := strings.SplitN(pair, "=", 2)
+		p := &harNameValue{Name: nv[0]}
+		if len(nv) > 1 {
+			p.Value = nv[1]
+		}
+		if v, err := url.QueryUnescape(p.Name); err == nil {
+			p.Name = v
+		}
+		if v, err := url.QueryUnescape(p.Value); err == nil {
+			p.Value = v
+		}
+		list = append(list, p)
+	}
+	return
+}
+
+// harHTTPVersion converts the debugger protocol name like h2 to har http version.
+func harHTTPVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "":
+		return ""
+	case "h2":
+		return "HTTP/2.0"
+	case "h3", "quic", "http/2+quic/43", "http/2+quic/46":
+		return "HTTP/3"
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
+
+func harTextSize(s string) int {
+	if s == "" {
+		return -1
+	}
+	return len(s)
+}
+
+func (t *Task) newHAREntry(r *record) (e *harEntry) {
+	q := jsonq.NewQuery(map[string]interface{}(r.params))
+
+	e = &harEntry{
+		Pageref: harPageID,
+		reqTs:   r.reqSeq,
+		respTs:  r.reqSeq,
+	}
+
+	reqURL, _ := q.String("request", "url")
+	method, _ := q.String("request", "method")
+	e.reqHeader, _ = q.Object("request", "headers")
+
+	e.Request = &harRequest{
+		Method:      method,
+		URL:         reqURL,
+		Cookies:     harCookies(t.parseHeaders(true, e.reqHeader)),
+		Headers:     harHeaders(e.reqHeader),
+		QueryString: harQueryString(reqURL),
+		HeadersSize: -1,
+	}
+
+	if postData, err := q.String("request", "postData"); err == nil {
+		e.Request.PostData = &harPostData{
+			MimeType: harHeader(e.reqHeader, "Content-Type"),
+			Text:     postData,
+		}
+		e.Request.BodySize = len(postData)
+	}
+
+	e.ResourceType, _ = q.String("type")
+	if initiatorType, _ := q.String("initiator", "type"); initiatorType != "" {
+		e.Initiator = &harInitiator{Type: initiatorType}
+		e.Initiator.URL, _ = q.String("initiator", "url")
+		e.Initiator.LineNumber, _ = q.Int("initiator", "lineNumber")
+	}
+
+	e.Response = &harResponse{
+		Cookies:     []*harCookie{},
+		Headers:     []*harNameValue{},
+		Content:     &harContent{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	return
+}
+
+// setHARResponse fills the response of entry from the debugger response object.
+func (t *Task) setHARResponse(e *harEntry, resp map[string]interface{}, ts float64) {
+	q := jsonq.NewQuery(resp)
+
+	e.respTs = ts
+	headers, _ := q.Object("headers")
+	headersText, _ := q.String("headersText")
+	requestHeadersText, _ := q.String("requestHeadersText")
+	protocol, _ := q.String("protocol")
+
+	if requestHeaders, err := q.Object("requestHeaders"); err == nil {
+		// actual request headers contain cookies and headers added by network stack
+		e.Request.Headers = harHeaders(requestHeaders)
+		e.Request.Cookies = harCookies(t.parseHeaders(true, e.reqHeader, requestHeaders))
+	}
+	e.Request.HeadersSize = harTextSize(requestHeadersText)
+	e.Request.HTTPVersion = harHTTPVersion(protocol)
+
+	setCookies := t.parseHeaders(false, headers)
+	if reqURLObj, _ := url.Parse(e.Request.URL); reqURLObj != nil {
+		for _, c := range setCookies {
+			if c.Domain == "" {
+				c.Domain = reqURLObj.Host
+			}
+		}
+	}
+
+	e.Response.Status, _ = q.Int("status")
+	e.Response.StatusText, _ = q.String("statusText")
+	e.Response.HTTPVersion = harHTTPVersion(protocol)
+	e.Response.Cookies = harCookies(setCookies)
+	e.Response.Headers = harHeaders(headers)
+	e.Response.HeadersSize = harTextSize(headersText)
+	e.Response.Content.MimeType, _ = q.String("mimeType")
+	e.Response.RedirectURL = harHeader(headers, "Location")
+
+	e.ServerIPAddress, _ = q.String("remoteIPAddress")
+	e.ServerIPAddress = strings.Trim(e.ServerIPAddress, "[]")
+	if connectionID, err := q.Int("connectionId"); err == nil && connectionID > 0 {
+		e.Connection = strconv.Itoa(connectionID)
+	}
+	if encoded, err := q.Int("encodedDataLength"); err == nil {
+		e.TransferSize = encoded
+	}
+	if fromCache, _ := q.Bool("fromDiskCache"); fromCache {
+		e.Response.BodySize = 0
+	}
+
+	e.timing, _ = q.Object("timing")
+}
+
+// setHARLoading completes the entry with the loading finished or failed event.
+func setHARLoading(e *harEntry, p map[string]interface{}) {
+	q := jsonq.NewQuery(p)
+
+	if ts, err := q.Float("timestamp"); err == nil && ts > e.respTs {
+		e.respTs = ts
+	}
+	if errorText, _ := q.String("errorText"); errorText != "" {
+		e.Error = errorText
+		return
+	}
+	if encoded, err := q.Int("encodedDataLength"); err == nil && encoded >= e.TransferSize {
+		if e.Response.BodySize != 0 {
+			// response encoded data length counts the received headers
+			e.Response.BodySize = encoded - e.TransferSize
+			e.Response.Content.Size = e.Response.BodySize
+		}
+		e.TransferSize = encoded
+	}
+}
+
+// finish calculates the entry timings in milliseconds.
+func (e *harEntry) finish(wallOffset float64) {
+	e.StartedDateTime = harTime(e.reqTs + wallOffset)
+	e.Timings = &harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+
+	value := func(key string) float64 {
+		if v, ok := e.timing[key].(float64); ok {
+			return v
+		}
+		return -1
+	}
+
+	requestTime := value("requestTime")
+	if e.timing == nil || requestTime < 0 {
+		e.Timings.Wait = harMillis(offsetMillis(e.reqTs, e.respTs))
+		e.Time = e.Timings.Wait
+		return
+	}
+
+	// relative timing values are milliseconds since request time
+	blocked := offsetMillis(e.reqTs, requestTime)
+	for _, key := range []string{"dnsStart", "connectStart", "sendStart"} {
+		if v := value(key); v >= 0 {
+			blocked += v
+			break
+		}
+	}
+	e.Timings.Blocked = blocked
+
+	if start := value("dnsStart"); start >= 0 {
+		e.Timings.DNS = math.Max(value("dnsEnd")-start, 0)
+	}
+	if start := value("connectStart"); start >= 0 {
+		e.Timings.Connect = math.Max(value("connectEnd")-start, 0)
+	}
+	if start := value("sslStart"); start >= 0 {
+		e.Timings.SSL = math.Max(value("sslEnd")-start, 0)
+	}
+
+	sendStart, sendEnd, headersEnd := value("sendStart"), value("sendEnd"), value("receiveHeadersEnd")
+	e.Timings.Send = math.Max(sendEnd-sendStart, 0)
+	e.Timings.Wait = math.Max(headersEnd-sendEnd, 0)
+	e.Timings.Receive = math.Max(offsetMillis(requestTime, e.respTs)-headersEnd, 0)
+
+	// ssl time is included in connect time
+	e.Time = e.Timings.Blocked + e.Timings.Send + e.Timings.Wait + e.Timings.Receive +
+		math.Max(e.Timings.DNS, 0) + math.Max(e.Timings.Connect, 0)
+
+	for _, v := range []*float64{&e.Time, &e.Timings.Blocked, &e.Timings.DNS, &e.Timings.Connect,
+		&e.Timings.Send, &e.Timings.Wait, &e.Timings.Receive, &e.Timings.SSL} {
+		*v = harMillis(*v)
+	}
+}
+
+// harMillis rounds milliseconds to microsecond precision.
+func harMillis(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+func harTime(seconds float64) time.Time {
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+// harWallOffset returns the offset from monotonic debugger timestamps to unix time in seconds.
+func harWallOffset(resp map[string][]*record, navStart float64, startTime time.Time) float64 {
+	for _, records := range resp {
+		for _, r := range records {
+			if wallTime, ok := r.params["wallTime"].(float64); ok && r.isRequest && wallTime > 0 {
+				return wallTime - r.reqSeq
+			}
+		}
+	}
+
+	// fallback to scan start time as navigation start
+	return float64(startTime.UnixNano())/1e9 - navStart
+}
+
+func (t *Task) newHAR(rc *recordCollector, site string) (har *harFile) {
+	resp := rc.get()
+	navStart, _ := navigationStart(resp)
+	wallOffset := harWallOffset(resp, navStart, t.startTime)
+
+	page := &harPage{
+		StartedDateTime: harTime(navStart + wallOffset),
+		ID:              harPageID,
+		Title:           site,
+		PageTimings:     &harPageTimings{OnContentLoad: -1, OnLoad: -1},
+	}
+	if ts, ok := rc.pageEvent(pageEventDOMContentLoaded); ok {
+		page.PageTimings.OnContentLoad = harMillis(offsetMillis(navStart, ts))
+	}
+	if ts, ok := rc.pageEvent(pageEventLoad); ok {
+		page.PageTimings.OnLoad = harMillis(offsetMillis(navStart, ts))
+	}
+
+	har = &harFile{Log: &harLog{
+		Version: harVersion,
+		Creator: &harCreator{Name: harCreatorName, Version: harCreatorVersion},
+		Pages:   []*harPage{page},
+		Entries: []*harEntry{},
+	}}
+
+	for reqID, records := range resp {
+		var e *harEntry
+
+		for _, r := range records {
+			if !r.isRequest {
+				if e != nil {
+					t.setHARResponse(e, r.params.Map("response"), r.reqSeq)
+				}
+				continue
+			}
+
+			if e != nil {
+				// redirected requests share the request id and carry the response of previous url
+				if redirect, ok := r.params["redirectResponse"].(map[string]interface{}); ok {
+					t.setHARResponse(e, redirect, r.reqSeq)
+				}
+				e.finish(wallOffset)
+				har.Log.Entries = append(har.Log.Entries, e)
+			}
+
+			e = t.newHAREntry(r)
+		}
+
+		if e == nil {
+			continue
+		}
+		if p, ok := rc.loadingEvent(reqID); ok {
+			setHARLoading(e, p)
+		}
+		e.finish(wallOffset)
+		har.Log.Entries = append(har.Log.Entries, e)
+	}
+
+	sort.SliceStable(har.Log.Entries, func(i, j int) bool {
+		return har.Log.Entries[i].reqTs < har.Log.Entries[j].reqTs
+	})
+
+	return
+}
+
+// OutputHAR returns the network activity of last scan as HTTP Archive 1.2.
+func (t *Task) OutputHAR() (str string, err error) {
+	if t.records == nil {
+		err = errors.New("har requires the network events of a scan")
+		return
+	}
+
+	har := t.newHAR(t.records, t.reportData.ScanURL)
+
+	if t.driver != nil {
+		if ver, verErr := t.driver.Version(); verErr == nil && ver.Browser != "" {
+			name, version := ver.Browser, ""
+			if i := strings.IndexByte(name, '/'); i >= 0 {
+				name, version = name[:i], name[i+1:]
+			}
+			har.Log.Browser = &harCreator{Name: name, Version: version}
+		}
+	}
+
+	jsonBlob, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		err = errors.Wrap(err, "encode har failed")
+		return
+	}
+
+	str = string(jsonBlob)
+	return
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// minInferenceScore is the minimal combined signal score to report an inferred category.
+	minInferenceScore = 0.3
+)
+
+type nameRule struct {
+	pattern  string
+	exact    bool
+	category string
+	weight   float64
+}
+
+// namePrefixRules match the beginning of the lower-cased cookie name.
+var namePrefixRules = []*nameRule{
+	{pattern: "_ga", category: CategoryPerformance, weight: 0.8},
+	{pattern: "_gid", category: CategoryPerformance, weight: 0.8},
+	{pattern: "__utm", category: CategoryPerformance, weight: 0.8},
+	{pattern: "_hj", category: CategoryPerformance, weight: 0.7},
+	{pattern: "_clck", category: CategoryPerformance, weight: 0.7},
+	{pattern: "_clsk", category: CategoryPerformance, weight: 0.7},
+	{pattern: "mp_", category: CategoryPerformance, weight: 0.6},
+	{pattern: "ajs_", category: CategoryPerformance, weight: 0.6},
+	{pattern: "_fbp", category: CategoryTargeting, weight: 0.8},
+	{pattern: "_fbc", category: CategoryTargeting, weight: 0.8},
+	{pattern: "_gcl", category: CategoryTargeting, weight: 0.8},
+	{pattern: "_uet", category: CategoryTargeting, weight: 0.7},
+	{pattern: "_pin", category: CategoryTargeting, weight: 0.6},
+	{pattern: "_ttp", category: CategoryTargeting, weight: 0.7},
+	{pattern: "_scid", category: CategoryTargeting, weight: 0.6},
+	{pattern: "__cf_bm", category: CategoryStrictlyNecessary, weight: 0.7},
+	{pattern: "cf_clearance", category: CategoryStrictlyNecessary, weight: 0.7},
+	{pattern: "awsalb", category: CategoryStrictlyNecessary, weight: 0.7},
+}
+
+// nameTokenRules match the tokens of cookie name split by non alphanumeric characters.
+var nameTokenRules = []*nameRule{
+	{pattern: "fr", exact: true, category: CategoryTargeting, weight: 0.5},
+	{pattern: "ide", exact: true, category: CategoryTargeting, weight: 0.6},
+	{pattern: "muid", exact: true, category: CategoryTargeting, weight: 0.5},
+	{pattern: "uuid2", exact: true, category: CategoryTargeting, weight: 0.5},
+	{pattern: "criteo", category: CategoryTargeting, weight: 0.7},
+	{pattern: "ads", exact: true, category: CategoryTargeting, weight: 0.4},
+	{pattern: "analytics", category: CategoryPerformance, weight: 0.5},
+	{pattern: "track", category: CategoryPerformance, weight: 0.3},
+	{pattern: "sid", exact: true, category: CategoryStrictlyNecessary, weight: 0.5},
+	{pattern: "sess", category: CategoryStrictlyNecessary, weight: 0.6},
+	{pattern: "phpsessid", exact: true, category: CategoryStrictlyNecessary, weight: 0.8},
+	{pattern: "jsessionid", exact: true, category: CategoryStrictlyNecessary, weight: 0.8},
+	{pattern: "csrf", category: CategoryStrictlyNecessary, weight: 0.8},
+	{pattern: "xsrf", category: CategoryStrictlyNecessary, weight: 0.8},
+	{pattern: "consent", category: CategoryStrictlyNecessary, weight: 0.7},
+	{pattern: "optanonconsent", exact: true, category: CategoryStrictlyNecessary, weight: 0.8},
+	{pattern: "euconsent", category: CategoryStrictlyNecessary, weight: 0.7},
+	{pattern: "gdpr", category: CategoryStrictlyNecessary, weight: 0.5},
+	{pattern: "auth", category: CategoryStrictlyNecessary, weight: 0.5},
+	{pattern: "lang", category: CategoryFunctionality, weight: 0.5},
+	{pattern: "locale", category: CategoryFunctionality, weight: 0.5},
+	{pattern: "currency", category: CategoryFunctionality, weight: 0.5},
+	{pattern: "pref", category: CategoryFunctionality, weight: 0.4},
+	{pattern: "theme", category: CategoryFunctionality, weight: 0.4},
+	{pattern: "timezone", category: CategoryFunctionality, weight: 0.4},
+}
+
+type cookieInference struct {
+	Category   string
+	Confidence float64
+	Signals    []string
+}
+
+// inferenceScore combines independent signal weights of categories using noisy-or.
+type inferenceScore struct {
+	scores  map[string]float64
+	signals map[string][]string
+}
+
+func (s *inferenceScore) add(category string, weight float64, signal string) {
+	s.scores[category] = 1 - (1-s.scores[category])*(1-weight)
+	s.signals[category] = append(s.signals[category], signal)
+}
+
+func tokenizeCookieName(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+}
+
+// inferCookieCategory guesses category of an unclassified cookie from its name, domain, lifetime, flags
+// and initiator, returns nil if the signals are too weak.
+func inferCookieCategory(c *reportCookieRecord, lifetime time.Duration) *cookieInference {
+	s := &inferenceScore{
+		scores:  map[string]float64{},
+		signals: map[string][]string{},
+	}
+
+	lowerName := strings.ToLower(c.Name)
+
+	for _, r := range namePrefixRules {
+		if strings.HasPrefix(lowerName, r.pattern) {
+			s.add(r.category, r.weight, fmt.Sprintf("name prefix %q", r.pattern))
+		}
+	}
+
+	for _, token := range tokenizeCookieName(c.Name) {
+		for _, r := range nameTokenRules {
+			if (r.exact && token == r.pattern) || (!r.exact && strings.HasPrefix(token, r.pattern)) {
+				s.add(r.category, r.weight, fmt.Sprintf("name token %q", token))
+			}
+		}
+	}
+
+	if tracker := matchTracker(c.Domain); tracker != nil {
+		s.add(tracker.Category, 0.7, fmt.Sprintf("tracker domain %s (%s)", tracker.Domain, tracker.Vendor))
+	} else if tracker = matchTrackerURL(c.URL); tracker != nil {
+		s.add(tracker.Category, 0.6, fmt.Sprintf("set by tracker %s (%s)", tracker.Domain, tracker.Vendor))
+	}
+
+	if tracker := matchTrackerURL(c.Source); tracker != nil {
+		s.add(tracker.Category, 0.5, fmt.Sprintf("initiator script vendor %s", tracker.Vendor))
+	}
+
+	if lifetime == 0 {
+		s.add(CategoryStrictlyNecessary, 0.2, "session lifetime")
+	} else if lifetime >= 365*24*time.Hour {
+		s.add(CategoryTargeting, 0.25, fmt.Sprintf("long lifetime %s", estimatedDuration(DefaultLanguage, lifetime)))
+	}
+
+	if c.HttpOnly && c.Secure {
+		s.add(CategoryStrictlyNecessary, 0.3, "HttpOnly and Secure flags")
+	} else if c.HttpOnly {
+		s.add(CategoryStrictlyNecessary, 0.15, "HttpOnly flag")
+	}
+
+	var categories []string
+	for category := range s.scores {
+		categories = append(categories, category)
+	}
+
+	if len(categories) == 0 {
+		return nil
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		if s.scores[categories[i]] != s.scores[categories[j]] {
+			return s.scores[categories[i]] > s.scores[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	best := categories[0]
+	if s.scores[best] < minInferenceScore {
+		return nil
+	}
+
+	// conflicting signals of other categories reduce the confidence
+	confidence := s.scores[best]
+	if len(categories) > 1 {
+		confidence *= 1 - s.scores[categories[1]]/2
+	}
+
+	return &cookieInference{
+		Category:   best,
+		Confidence: math.Round(confidence*100) / 100,
+		Signals:    s.signals[best],
+	}
+}
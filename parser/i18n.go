@@ -0,0 +1,453 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultLanguage = "en"
+)
+
+// translations contains the report and email template strings keyed by the english text.
+var translations = map[string]map[string]string{
+	"en": {
+		"exempt":                  "exempt from consent",
+		"exempt-under-conditions": "exempt under conditions",
+		"consent-required":        "consent required",
+		"http":                    "HTTP",
+		"script":                  "Script",
+		"tracker":                 "Tracker",
+		"ad":                      "Ad",
+	},
+	"de": {
+		"Cookie scan report":  "Cookie-Scan-Bericht",
+		"Scan date:":          "Scan-Datum:",
+		"Scan URL:":           "Gescannte URL:",
+		"Cookies (in total):": "Cookies (insgesamt):",
+		"Classifier version:": "Klassifizierer-Version:",
+		"Unclassified":        "Nicht klassifiziert",
+		"(inferred)":          "(abgeleitet)",
+		"These cookies are not in the classifier database, the category is guessed from heuristic signals.":                                "Diese Cookies sind nicht in der Klassifizierungsdatenbank enthalten, die Kategorie wurde anhand heuristischer Merkmale geschätzt.",
+		"We don’t have enough information about this cookie or the website hosting it to be able to assign it to a category at this time.": "Wir haben derzeit nicht genügend Informationen über dieses Cookie oder die Website, um es einer Kategorie zuzuordnen.",
+		"cookie name":                "Cookie-Name",
+		"provider":                   "Anbieter",
+		"expiry":                     "Ablauf",
+		"First found:":               "Zuerst gefunden:",
+		"Initiator:":                 "Auslöser:",
+		"Source:":                    "Quelle:",
+		"Server Address:":            "Serveradresse:",
+		"Mime Type:":                 "MIME-Typ:",
+		"Used Requests:":             "Verwendet in Anfragen:",
+		"HttpOnly:":                  "HttpOnly:",
+		"yes":                        "ja",
+		"no":                         "nein",
+		"Description:":               "Beschreibung:",
+		"Inferred:":                  "Abgeleitet:",
+		"%s confidence (%s)":         "%s Konfidenz (%s)",
+		"Strictly Necessary":         "Unbedingt erforderlich",
+		"Performance":                "Leistung",
+		"Functionality":              "Funktionalität",
+		"Targeting/Advertising":      "Targeting/Werbung",
+		"year":                       "Jahr(e)",
+		"month":                      "Monat(e)",
+		"day":                        "Tag(e)",
+		"hour":                       "Std.",
+		"min":                        "Min.",
+		"sec":                        "Sek.",
+		"Session":                    "Sitzung",
+		"CookieScan Report":          "CookieScan-Bericht",
+		"GDPR Expert Cookies Report": "GDPR Expert Cookie-Bericht",
+		"Your website's cookies in-depth report.": "Der ausführliche Cookie-Bericht Ihrer Website.",
+		"Cookie Report":        "Cookie-Bericht",
+		"Cookies report of %s": "Cookie-Bericht für %s",
+		"Hi there,":            "Hallo,",
+		"Attached please find your cookie report of %s.": "Anbei erhalten Sie Ihren Cookie-Bericht für %s.",
+		"Best Regards,":   "Mit freundlichen Grüßen",
+		"GDPRExpert Team": "Ihr GDPRExpert-Team",
+		"Come talk to us, we are the GDPR experts!": "Sprechen Sie mit uns, wir sind die DSGVO-Experten!",
+		"If you have any enqury about cookie consent policies, notification of privacy incidents, or even a complete GDPR solution. Talk to us! We will help you with the GDPR compliance so you can focus on your business.": "Wenn Sie Fragen zu Cookie-Einwilligungsrichtlinien, zur Meldung von Datenschutzvorfällen oder zu einer vollständigen DSGVO-Lösung haben, sprechen Sie mit uns! Wir helfen Ihnen bei der DSGVO-Konformität, damit Sie sich auf Ihr Geschäft konzentrieren können.",
+		"You are receiving this email because you requested for cookies report at GDPRExpert website https://gdprexpert.io and agreed to receive emails from us regarding new features, events and special offers.":           "Sie erhalten diese E-Mail, weil Sie auf der GDPRExpert-Website https://gdprexpert.io einen Cookie-Bericht angefordert und dem Erhalt von E-Mails über neue Funktionen, Veranstaltungen und Sonderangebote zugestimmt haben.",
+		"ICC UK:":                           "ICC UK:",
+		"CNIL:":                             "CNIL:",
+		"IAB TCF v2 purposes:":              "IAB TCF v2 Zwecke:",
+		"exempt":                            "von der Einwilligung befreit",
+		"exempt-under-conditions":           "unter Bedingungen befreit",
+		"consent-required":                  "Einwilligung erforderlich",
+		"Privacy":                           "Datenschutz",
+		"Website":                           "Website",
+		"Consent banner:":                   "Cookie-Banner:",
+		"Countries outside the EU/EEA:":     "Länder außerhalb von EU/EWR:",
+		"Data transfers outside the EU/EEA": "Datenübermittlungen außerhalb von EU/EWR",
+		"Server locations from %s, transfers to these countries require safeguards such as an adequacy decision or standard contractual clauses.": "Serverstandorte laut %s, Übermittlungen in diese Länder erfordern Garantien wie einen Angemessenheitsbeschluss oder Standardvertragsklauseln.",
+		"No servers outside the EU/EEA were contacted.": "Es wurden keine Server außerhalb von EU/EWR kontaktiert.",
+		"country":                      "Land",
+		"networks":                     "Netzwerke",
+		"cookies":                      "Cookies",
+		"Third-party hosts contacted:": "Kontaktierte Drittanbieter-Hosts:",
+		"Third-party requests":         "Anfragen an Drittanbieter",
+		"Every request to a third party transfers the visitor's IP address, requests without cookies are included.": "Jede Anfrage an einen Drittanbieter überträgt die IP-Adresse des Besuchers, Anfragen ohne Cookies sind enthalten.",
+		"resource types":         "Ressourcentypen",
+		"first contact":          "Erster Kontakt",
+		"before consent":         "vor Einwilligung",
+		"tracker":                "Tracker",
+		"ad":                     "Werbung",
+		"Filter rule:":           "Filterregel:",
+		"Trackers contacted":     "Kontaktierte Tracker",
+		"host":                   "Host",
+		"kind":                   "Art",
+		"requests":               "Anfragen",
+		"filter rule":            "Filterregel",
+		"CNAME cloaked":          "CNAME-getarnt",
+		"CNAME chain:":           "CNAME-Kette:",
+		"CNAME cloaked cookies:": "CNAME-getarnte Cookies:",
+		"First-party cookies:":   "Erstanbieter-Cookies:",
+		"Third-party cookies:":   "Drittanbieter-Cookies:",
+		"Show:":                  "Anzeigen:",
+		"All cookies":            "Alle Cookies",
+		"Sent to:":               "Gesendet an:",
+		"%d hosts":               "%d Hosts",
+		"%d requests":            "%d Anfragen",
+		"Initiators":             "Auslöser",
+		"Requests pulling in third parties and cookies, third-party requests are highlighted.": "Anfragen, die Drittanbieter und Cookies nachladen, Anfragen an Drittanbieter sind hervorgehoben.",
+		"Loaded via:":   "Geladen über:",
+		"sets cookies:": "setzt Cookies:",
+		"Timeline":      "Zeitleiste",
+		"When each cookie was first set, relative to navigation start.": "Wann jedes Cookie erstmals gesetzt wurde, relativ zum Navigationsbeginn.",
+		"Navigation start": "Navigationsbeginn",
+		"Load":             "Geladen",
+		"Third-party":      "Drittanbieter",
+		"First-party":      "Erstanbieter",
+		"Script cookie, set before the first request sending it": "Skript-Cookie, gesetzt vor der ersten Anfrage, die es sendet",
+		"Page":               "Seite",
+		"http":               "HTTP",
+		"script":             "Skript",
+		"Cookie declaration": "Cookie-Erklärung",
+		"This website uses the following cookies, last checked on %s.": "Diese Website verwendet die folgenden Cookies, zuletzt geprüft am %s.",
+		"Name":                  "Name",
+		"Provider":              "Anbieter",
+		"Purpose":               "Zweck",
+		"Expiry":                "Ablauf",
+		"Type":                  "Typ",
+		"%s Team":               "Ihr %s-Team",
+		"Report prepared by %s": "Bericht erstellt von %s",
+		"Contact:":              "Kontakt:",
+		"Contact us":            "Kontakt",
+		"All Rights Reserved.":  "Alle Rechte vorbehalten.",
+		"You are receiving this email because you requested a cookie report from %s.": "Sie erhalten diese E-Mail, weil Sie einen Cookie-Bericht bei %s angefordert haben.",
+	},
+	"fr": {
+		"Cookie scan report":  "Rapport d'analyse des cookies",
+		"Scan date:":          "Date de l'analyse :",
+		"Scan URL:":           "URL analysée :",
+		"Cookies (in total):": "Cookies (au total) :",
+		"Classifier version:": "Version du classificateur :",
+		"Unclassified":        "Non classés",
+		"(inferred)":          "(déduite)",
+		"These cookies are not in the classifier database, the category is guessed from heuristic signals.":                                "Ces cookies ne figurent pas dans la base de classification, la catégorie est estimée à partir d'indices heuristiques.",
+		"We don’t have enough information about this cookie or the website hosting it to be able to assign it to a category at this time.": "Nous ne disposons pas de suffisamment d'informations sur ce cookie ou sur le site qui l'héberge pour lui attribuer une catégorie pour le moment.",
+		"cookie name":                "nom du cookie",
+		"provider":                   "fournisseur",
+		"expiry":                     "expiration",
+		"First found:":               "Première détection :",
+		"Initiator:":                 "Initiateur :",
+		"Source:":                    "Source :",
+		"Server Address:":            "Adresse du serveur :",
+		"Mime Type:":                 "Type MIME :",
+		"Used Requests:":             "Requêtes utilisant le cookie :",
+		"HttpOnly:":                  "HttpOnly :",
+		"yes":                        "oui",
+		"no":                         "non",
+		"Description:":               "Description :",
+		"Inferred:":                  "Déduite :",
+		"%s confidence (%s)":         "confiance %s (%s)",
+		"Strictly Necessary":         "Strictement nécessaires",
+		"Performance":                "Performance",
+		"Functionality":              "Fonctionnalité",
+		"Targeting/Advertising":      "Ciblage/Publicité",
+		"year":                       "an(s)",
+		"month":                      "mois",
+		"day":                        "jour(s)",
+		"hour":                       "h",
+		"min":                        "min",
+		"sec":                        "s",
+		"Session":                    "Session",
+		"CookieScan Report":          "Rapport CookieScan",
+		"GDPR Expert Cookies Report": "Rapport de cookies GDPR Expert",
+		"Your website's cookies in-depth report.": "Le rapport détaillé des cookies de votre site.",
+		"Cookie Report":        "Rapport de cookies",
+		"Cookies report of %s": "Rapport de cookies de %s",
+		"Hi there,":            "Bonjour,",
+		"Attached please find your cookie report of %s.": "Veuillez trouver ci-joint votre rapport de cookies pour %s.",
+		"Best Regards,":   "Cordialement,",
+		"GDPRExpert Team": "L'équipe GDPRExpert",
+		"Come talk to us, we are the GDPR experts!": "Parlons-en, nous sommes les experts du RGPD !",
+		"If you have any enqury about cookie consent policies, notification of privacy incidents, or even a complete GDPR solution. Talk to us! We will help you with the GDPR compliance so you can focus on your business.": "Pour toute question sur les politiques de consentement aux cookies, la notification des incidents de confidentialité ou une solution RGPD complète, parlez-nous ! Nous vous aidons à vous mettre en conformité avec le RGPD pour que vous puissiez vous concentrer sur votre activité.",
+		"You are receiving this email because you requested for cookies report at GDPRExpert website https://gdprexpert.io and agreed to receive emails from us regarding new features, events and special offers.":           "Vous recevez cet e-mail parce que vous avez demandé un rapport de cookies sur le site GDPRExpert https://gdprexpert.io et accepté de recevoir nos e-mails concernant les nouvelles fonctionnalités, événements et offres spéciales.",
+		"ICC UK:":                           "ICC UK :",
+		"CNIL:":                             "CNIL :",
+		"IAB TCF v2 purposes:":              "Finalités IAB TCF v2 :",
+		"exempt":                            "exempté de consentement",
+		"exempt-under-conditions":           "exempté sous conditions",
+		"consent-required":                  "consentement requis",
+		"Privacy":                           "Confidentialité",
+		"Website":                           "Site web",
+		"Consent banner:":                   "Bannière cookies :",
+		"Countries outside the EU/EEA:":     "Pays hors UE/EEE :",
+		"Data transfers outside the EU/EEA": "Transferts de données hors UE/EEE",
+		"Server locations from %s, transfers to these countries require safeguards such as an adequacy decision or standard contractual clauses.": "Localisation des serveurs selon %s, les transferts vers ces pays nécessitent des garanties comme une décision d'adéquation ou des clauses contractuelles types.",
+		"No servers outside the EU/EEA were contacted.": "Aucun serveur hors UE/EEE n'a été contacté.",
+		"country":                      "pays",
+		"networks":                     "réseaux",
+		"cookies":                      "cookies",
+		"Third-party hosts contacted:": "Hôtes tiers contactés :",
+		"Third-party requests":         "Requêtes vers des tiers",
+		"Every request to a third party transfers the visitor's IP address, requests without cookies are included.": "Chaque requête vers un tiers transmet l'adresse IP du visiteur, les requêtes sans cookies sont incluses.",
+		"resource types":         "types de ressources",
+		"first contact":          "premier contact",
+		"before consent":         "avant consentement",
+		"tracker":                "Traceur",
+		"ad":                     "Publicité",
+		"Filter rule:":           "Règle de filtrage :",
+		"Trackers contacted":     "Traceurs contactés",
+		"host":                   "hôte",
+		"kind":                   "type",
+		"requests":               "requêtes",
+		"filter rule":            "règle de filtrage",
+		"CNAME cloaked":          "masqué par CNAME",
+		"CNAME chain:":           "Chaîne CNAME :",
+		"CNAME cloaked cookies:": "Cookies masqués par CNAME :",
+		"First-party cookies:":   "Cookies propriétaires :",
+		"Third-party cookies:":   "Cookies tiers :",
+		"Show:":                  "Afficher :",
+		"All cookies":            "Tous les cookies",
+		"Sent to:":               "Envoyé à :",
+		"%d hosts":               "%d hôtes",
+		"%d requests":            "%d requêtes",
+		"Initiators":             "Initiateurs",
+		"Requests pulling in third parties and cookies, third-party requests are highlighted.": "Requêtes faisant intervenir des tiers et des cookies, les requêtes vers des tiers sont mises en évidence.",
+		"Loaded via:":   "Chargé via :",
+		"sets cookies:": "dépose les cookies :",
+		"Timeline":      "Chronologie",
+		"When each cookie was first set, relative to navigation start.": "Moment où chaque cookie a été déposé pour la première fois, par rapport au début de la navigation.",
+		"Navigation start": "Début de la navigation",
+		"Load":             "Chargement",
+		"Third-party":      "Tiers",
+		"First-party":      "Propriétaire",
+		"Script cookie, set before the first request sending it": "Cookie de script, déposé avant la première requête qui l'envoie",
+		"Page":               "Page",
+		"http":               "HTTP",
+		"script":             "Script",
+		"Cookie declaration": "Déclaration relative aux cookies",
+		"This website uses the following cookies, last checked on %s.": "Ce site utilise les cookies suivants, vérifiés pour la dernière fois le %s.",
+		"Name":                  "Nom",
+		"Provider":              "Fournisseur",
+		"Purpose":               "Finalité",
+		"Expiry":                "Expiration",
+		"Type":                  "Type",
+		"%s Team":               "L'équipe %s",
+		"Report prepared by %s": "Rapport préparé par %s",
+		"Contact:":              "Contact :",
+		"Contact us":            "Contactez-nous",
+		"All Rights Reserved.":  "Tous droits réservés.",
+		"You are receiving this email because you requested a cookie report from %s.": "Vous recevez cet e-mail parce que vous avez demandé un rapport de cookies à %s.",
+	},
+	"es": {
+		"Cookie scan report":  "Informe de análisis de cookies",
+		"Scan date:":          "Fecha del análisis:",
+		"Scan URL:":           "URL analizada:",
+		"Cookies (in total):": "Cookies (en total):",
+		"Classifier version:": "Versión del clasificador:",
+		"Unclassified":        "Sin clasificar",
+		"(inferred)":          "(inferida)",
+		"These cookies are not in the classifier database, the category is guessed from heuristic signals.":                                "Estas cookies no están en la base de datos de clasificación; la categoría se ha estimado a partir de señales heurísticas.",
+		"We don’t have enough information about this cookie or the website hosting it to be able to assign it to a category at this time.": "No disponemos de información suficiente sobre esta cookie o el sitio web que la aloja para asignarle una categoría en este momento.",
+		"cookie name":                "nombre de la cookie",
+		"provider":                   "proveedor",
+		"expiry":                     "caducidad",
+		"First found:":               "Detectada por primera vez:",
+		"Initiator:":                 "Iniciador:",
+		"Source:":                    "Origen:",
+		"Server Address:":            "Dirección del servidor:",
+		"Mime Type:":                 "Tipo MIME:",
+		"Used Requests:":             "Solicitudes que la usan:",
+		"HttpOnly:":                  "HttpOnly:",
+		"yes":                        "sí",
+		"no":                         "no",
+		"Description:":               "Descripción:",
+		"Inferred:":                  "Inferida:",
+		"%s confidence (%s)":         "%s de confianza (%s)",
+		"Strictly Necessary":         "Estrictamente necesarias",
+		"Performance":                "Rendimiento",
+		"Functionality":              "Funcionalidad",
+		"Targeting/Advertising":      "Segmentación/Publicidad",
+		"year":                       "año(s)",
+		"month":                      "mes(es)",
+		"day":                        "día(s)",
+		"hour":                       "h",
+		"min":                        "min",
+		"sec":                        "s",
+		"Session":                    "Sesión",
+		"CookieScan Report":          "Informe de CookieScan",
+		"GDPR Expert Cookies Report": "Informe de cookies de GDPR Expert",
+		"Your website's cookies in-depth report.": "El informe detallado de las cookies de su sitio web.",
+		"Cookie Report":        "Informe de cookies",
+		"Cookies report of %s": "Informe de cookies de %s",
+		"Hi there,":            "Hola:",
+		"Attached please find your cookie report of %s.": "Adjunto encontrará el informe de cookies de %s.",
+		"Best Regards,":   "Saludos cordiales,",
+		"GDPRExpert Team": "El equipo de GDPRExpert",
+		"Come talk to us, we are the GDPR experts!": "¡Hable con nosotros, somos los expertos en RGPD!",
+		"If you have any enqury about cookie consent policies, notification of privacy incidents, or even a complete GDPR solution. Talk to us! We will help you with the GDPR compliance so you can focus on your business.": "Si tiene alguna consulta sobre políticas de consentimiento de cookies, notificación de incidentes de privacidad o una solución completa de RGPD, ¡hable con nosotros! Le ayudaremos a cumplir el RGPD para que pueda centrarse en su negocio.",
+		"You are receiving this email because you requested for cookies report at GDPRExpert website https://gdprexpert.io and agreed to receive emails from us regarding new features, events and special offers.":           "Recibe este correo porque solicitó un informe de cookies en el sitio web de GDPRExpert https://gdprexpert.io y aceptó recibir nuestros correos sobre nuevas funciones, eventos y ofertas especiales.",
+		"ICC UK:":                           "ICC UK:",
+		"CNIL:":                             "CNIL:",
+		"IAB TCF v2 purposes:":              "Finalidades IAB TCF v2:",
+		"exempt":                            "exenta de consentimiento",
+		"exempt-under-conditions":           "exenta bajo condiciones",
+		"consent-required":                  "requiere consentimiento",
+		"Privacy":                           "Privacidad",
+		"Website":                           "Sitio web",
+		"Consent banner:":                   "Banner de cookies:",
+		"Countries outside the EU/EEA:":     "Países fuera de la UE/EEE:",
+		"Data transfers outside the EU/EEA": "Transferencias de datos fuera de la UE/EEE",
+		"Server locations from %s, transfers to these countries require safeguards such as an adequacy decision or standard contractual clauses.": "Ubicación de los servidores según %s, las transferencias a estos países requieren garantías como una decisión de adecuación o cláusulas contractuales tipo.",
+		"No servers outside the EU/EEA were contacted.": "No se contactó con servidores fuera de la UE/EEE.",
+		"country":                      "país",
+		"networks":                     "redes",
+		"cookies":                      "cookies",
+		"Third-party hosts contacted:": "Hosts de terceros contactados:",
+		"Third-party requests":         "Solicitudes a terceros",
+		"Every request to a third party transfers the visitor's IP address, requests without cookies are included.": "Cada solicitud a un tercero transfiere la dirección IP del visitante, se incluyen las solicitudes sin cookies.",
+		"resource types":         "tipos de recurso",
+		"first contact":          "primer contacto",
+		"before consent":         "antes del consentimiento",
+		"tracker":                "Rastreador",
+		"ad":                     "Publicidad",
+		"Filter rule:":           "Regla de filtro:",
+		"Trackers contacted":     "Rastreadores contactados",
+		"host":                   "host",
+		"kind":                   "tipo",
+		"requests":               "solicitudes",
+		"filter rule":            "regla de filtro",
+		"CNAME cloaked":          "encubierta por CNAME",
+		"CNAME chain:":           "Cadena CNAME:",
+		"CNAME cloaked cookies:": "Cookies encubiertas por CNAME:",
+		"First-party cookies:":   "Cookies propias:",
+		"Third-party cookies:":   "Cookies de terceros:",
+		"Show:":                  "Mostrar:",
+		"All cookies":            "Todas las cookies",
+		"Sent to:":               "Enviada a:",
+		"%d hosts":               "%d hosts",
+		"%d requests":            "%d solicitudes",
+		"Initiators":             "Iniciadores",
+		"Requests pulling in third parties and cookies, third-party requests are highlighted.": "Solicitudes que cargan terceros y cookies, las solicitudes a terceros están resaltadas.",
+		"Loaded via:":   "Cargada a través de:",
+		"sets cookies:": "establece las cookies:",
+		"Timeline":      "Cronología",
+		"When each cookie was first set, relative to navigation start.": "Cuándo se estableció cada cookie por primera vez, respecto al inicio de la navegación.",
+		"Navigation start": "Inicio de la navegación",
+		"Load":             "Carga",
+		"Third-party":      "De terceros",
+		"First-party":      "Propias",
+		"Script cookie, set before the first request sending it": "Cookie de script, establecida antes de la primera solicitud que la envía",
+		"Page":               "Página",
+		"http":               "HTTP",
+		"script":             "Script",
+		"Cookie declaration": "Declaración de cookies",
+		"This website uses the following cookies, last checked on %s.": "Este sitio web utiliza las siguientes cookies, revisadas por última vez el %s.",
+		"Name":                  "Nombre",
+		"Provider":              "Proveedor",
+		"Purpose":               "Finalidad",
+		"Expiry":                "Caducidad",
+		"Type":                  "Tipo",
+		"%s Team":               "El equipo de %s",
+		"Report prepared by %s": "Informe preparado por %s",
+		"Contact:":              "Contacto:",
+		"Contact us":            "Contáctenos",
+		"All Rights Reserved.":  "Todos los derechos reservados.",
+		"You are receiving this email because you requested a cookie report from %s.": "Recibe este correo porque solicitó un informe de cookies a %s.",
+	},
+}
+
+// SupportedLanguages returns the languages with translation catalog.
+func SupportedLanguages() (langs []string) {
+	for lang := range translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return
+}
+
+// NormalizeLanguage returns the primary language subtag of lang, "de-AT" becomes "de".
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if idx := strings.IndexAny(lang, "-_"); idx >= 0 {
+		lang = lang[:idx]
+	}
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+	return lang
+}
+
+// IsSupportedLanguage checks if lang has a translation catalog.
+func IsSupportedLanguage(lang string) bool {
+	lang = NormalizeLanguage(lang)
+	_, ok := translations[lang]
+	return ok
+}
+
+// Translate returns the translation of key in lang, falls back to the english key.
+func Translate(lang string, key string, args ...interface{}) string {
+	if catalog, ok := translations[NormalizeLanguage(lang)]; ok {
+		if v, ok := catalog[key]; ok {
+			key = v
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(key, args...)
+	}
+
+	return key
+}
+
+// executeLocalized renders tpl with the "T" translation function bound to lang,
+// the original template is cloned as html/template could not be modified after execution.
+func executeLocalized(tpl *template.Template, lang string, data interface{}) (str string, err error) {
+	localized, err := tpl.Clone()
+	if err != nil {
+		return
+	}
+
+	localized.Funcs(template.FuncMap{
+		"T": func(key string, args ...interface{}) string {
+			return Translate(lang, key, args...)
+		},
+	})
+
+	buf := new(bytes.Buffer)
+	err = localized.Execute(buf, data)
+	str = buf.String()
+	return
+}
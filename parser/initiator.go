@@ -0,0 +1,356 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// InitiatorGraphJSON is the initiator tree in json.
+	InitiatorGraphJSON = "json"
+	// InitiatorGraphDOT is the initiator graph in graphviz dot language.
+	InitiatorGraphDOT = "dot"
+
+	initiatorRedirect = "redirect"
+)
+
+// InitiatorGraphFormats returns the supported initiator graph formats.
+func InitiatorGraphFormats() []string {
+	return []string{InitiatorGraphJSON, InitiatorGraphDOT}
+}
+
+// initiatorNode is a request in the tree of requests loading each other.
+type initiatorNode struct {
+	URL               string
+	Host              string
+	RegistrableDomain string
+	ResourceType      string
+	Initiator         string
+	LineNo            int
+	ThirdParty        bool
+	FilterKind        string           `json:",omitempty"`
+	FilterRule        string           `json:",omitempty"`
+	Location          *geoLocation     `json:",omitempty"`
+	Cookies           []string         `json:",omitempty"`
+	Children          []*initiatorNode `json:",omitempty"`
+
+	parentURL string
+	parent    *initiatorNode
+	seq       float64
+}
+
+// stripURLFragment returns the url without fragment as node key.
+func stripURLFragment(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
+// stackURL returns the innermost script url of the initiator call stack, following the async parent stacks.
+func stackURL(stack map[string]interface{}) (scriptURL string, lineNo int) {
+	for stack != nil {
+		frames, _ := stack["callFrames"].([]interface{})
+		for _, f := range frames {
+			frame, _ := f.(map[string]interface{})
+			if u, _ := frame["url"].(string); u != "" {
+				scriptURL = u
+				if l, ok := frame["lineNumber"].(float64); ok {
+					lineNo = int(l)
+				}
+				return
+			}
+		}
+		stack, _ = stack["parent"].(map[string]interface{})
+	}
+	return
+}
+
+// initiatorParent returns the url of resource which caused the request.
+func initiatorParent(params map[string]interface{}) (parentURL string, initiatorType string, lineNo int) {
+	initiator, _ := params["initiator"].(map[string]interface{})
+	initiatorType, _ = initiator["type"].(string)
+
+	if stack, ok := initiator["stack"].(map[string]interface{}); ok {
+		parentURL, lineNo = stackURL(stack)
+	}
+	if parentURL == "" {
+		parentURL, _ = initiator["url"].(string)
+		if l, ok := initiator["lineNumber"].(float64); ok {
+			lineNo = int(l)
+		}
+	}
+	if parentURL == "" {
+		// navigation of sub frames
+		parentURL, _ = params["documentURL"].(string)
+	}
+
+	return
+}
+
+// newInitiatorTree links all recorded requests to their initiators, the main document is the root.
+func newInitiatorTree(rc *recordCollector, site string, records []*reportRecord) (roots []*initiatorNode) {
+	var siteHost string
+	if u, err := url.Parse(site); err == nil {
+		siteHost = u.Hostname()
+	}
+
+	var (
+		nodes   []*initiatorNode
+		nodeMap = map[string]*initiatorNode{}
+	)
+
+	addNode := func(rawURL string, seq float64) (n *initiatorNode, added bool) {
+		key := stripURLFragment(rawURL)
+		if n = nodeMap[key]; n != nil {
+			if seq < n.seq {
+				n.seq = seq
+			}
+			return
+		}
+		n = &initiatorNode{URL: key, seq: seq}
+		if u, err := url.Parse(key); err == nil {
+			n.Host = u.Hostname()
+			n.RegistrableDomain = registrableDomain(n.Host)
+			n.ThirdParty = isThirdParty(siteHost, n.Host)
+		}
+		nodeMap[key] = n
+		nodes = append(nodes, n)
+		return n, true
+	}
+
+	for _, reqRecords := range rc.get() {
+		var prevURL string
+
+		for _, r := range reqRecords {
+			if !r.isRequest {
+				continue
+			}
+
+			req, _ := r.params["request"].(map[string]interface{})
+			reqURL, _ := req["url"].(string)
+			if reqURL == "" {
+				continue
+			}
+
+			n, added := addNode(reqURL, r.reqSeq)
+			if !added {
+				prevURL = reqURL
+				continue
+			}
+
+			n.ResourceType, _ = r.params["type"].(string)
+
+			if prevURL != "" {
+				// redirected requests share the request id
+				n.parentURL, n.Initiator = stripURLFragment(prevURL), initiatorRedirect
+			} else {
+				n.parentURL, n.Initiator, n.LineNo = initiatorParent(r.params)
+				n.parentURL = stripURLFragment(n.parentURL)
+			}
+
+			prevURL = reqURL
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].seq < nodes[j].seq
+	})
+
+	// cookies first set by request
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			if c.Type != CookieTypeHTTP || c.URL == "" {
+				continue
+			}
+			if n := nodeMap[stripURLFragment(c.URL)]; n != nil {
+				n.Cookies = append(n.Cookies, c.Name)
+			}
+		}
+	}
+
+	// unknown parents like inline or extension scripts are added as roots
+	for _, n := range append([]*initiatorNode(nil), nodes...) {
+		if n.parentURL == "" || n.parentURL == n.URL {
+			continue
+		}
+		p, _ := addNode(n.parentURL, n.seq)
+		if !p.isDescendantOf(n) {
+			n.parent = p
+		}
+	}
+
+	for _, n := range nodes {
+		sort.Strings(n.Cookies)
+		if n.parent != nil {
+			n.parent.Children = append(n.parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	// record the loading chain of cookies set by http responses
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			if c.Type != CookieTypeHTTP || c.URL == "" {
+				continue
+			}
+			if n := nodeMap[stripURLFragment(c.URL)]; n != nil {
+				c.InitiatorChain = n.chain()
+			}
+		}
+	}
+
+	return
+}
+
+func (n *initiatorNode) isDescendantOf(ancestor *initiatorNode) bool {
+	for p := n; p != nil; p = p.parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// chain returns urls from root to the node.
+func (n *initiatorNode) chain() (urls []string) {
+	for p := n; p != nil; p = p.parent {
+		urls = append([]string{p.URL}, urls...)
+	}
+	return
+}
+
+// HasCookies reports whether the request or any request loaded by it sets cookies.
+func (n *initiatorNode) HasCookies() bool {
+	if len(n.Cookies) > 0 {
+		return true
+	}
+	for _, c := range n.Children {
+		if c.HasCookies() {
+			return true
+		}
+	}
+	return false
+}
+
+// prune returns a copy of tree keeping requests to third parties or setting cookies with their initiators.
+func (n *initiatorNode) prune() *initiatorNode {
+	var children []*initiatorNode
+	for _, c := range n.Children {
+		if pc := c.prune(); pc != nil {
+			children = append(children, pc)
+		}
+	}
+	if len(children) == 0 && len(n.Cookies) == 0 && !n.ThirdParty {
+		return nil
+	}
+
+	pn := *n
+	pn.Children = children
+	return &pn
+}
+
+// InitiatorTree returns the requests pulling in third parties and cookies for report template.
+func (d *reportData) InitiatorTree() (roots []*initiatorNode) {
+	for _, n := range d.Initiators {
+		if pn := n.prune(); pn != nil {
+			roots = append(roots, pn)
+		}
+	}
+	return
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func outputAsInitiatorDOT(data *reportData) string {
+	buf := new(bytes.Buffer)
+	id := 0
+
+	buf.WriteString("digraph initiators {\n")
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [shape=box, fontname=\"Helvetica\", fontsize=10];\n")
+	buf.WriteString(fmt.Sprintf("  label=%s;\n", dotQuote(data.ScanURL)))
+
+	var walk func(n *initiatorNode) int
+	walk = func(n *initiatorNode) int {
+		id++
+		nodeID := id
+
+		label := n.URL
+		if len(n.Cookies) > 0 {
+			label += "\ncookies: " + strings.Join(n.Cookies, ", ")
+		}
+
+		attrs := []string{"label=" + dotQuote(label)}
+		if n.ThirdParty {
+			attrs = append(attrs, `color="#dc3545"`)
+		}
+		if len(n.Cookies) > 0 {
+			attrs = append(attrs, `style=filled`, `fillcolor="#ffc107"`)
+		}
+		buf.WriteString(fmt.Sprintf("  n%d [%s];\n", nodeID, strings.Join(attrs, ", ")))
+
+		for _, c := range n.Children {
+			childID := walk(c)
+			edge := fmt.Sprintf("  n%d -> n%d", nodeID, childID)
+			if c.Initiator != "" {
+				edge += " [label=" + dotQuote(c.Initiator) + "]"
+			}
+			buf.WriteString(edge + ";\n")
+		}
+
+		return nodeID
+	}
+
+	for _, n := range data.Initiators {
+		walk(n)
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+func outputAsInitiatorGraph(data *reportData, format string) (str string, err error) {
+	switch format {
+	case InitiatorGraphJSON:
+		roots := data.Initiators
+		if roots == nil {
+			roots = []*initiatorNode{}
+		}
+		var jsonBlob []byte
+		jsonBlob, err = json.MarshalIndent(roots, "", "  ")
+		str = string(jsonBlob)
+	case InitiatorGraphDOT:
+		str = outputAsInitiatorDOT(data)
+	default:
+		err = errors.Errorf("unknown initiator graph format: %s", format)
+	}
+
+	return
+}
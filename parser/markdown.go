@@ -0,0 +1,133 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r", "",
+	"\n", "<br>",
+)
+
+// markdownLabel translates the report label without the trailing colon for table headers.
+func markdownLabel(lang string, key string) string {
+	return strings.TrimRight(Translate(lang, key), " :")
+}
+
+func outputAsMarkdown(data *reportData) (str string, err error) {
+	var (
+		lang = data.Lang
+		b    = new(strings.Builder)
+		yes  = Translate(lang, "yes")
+		no   = Translate(lang, "no")
+	)
+
+	fmt.Fprintf(b, "# %s\n\n", Translate(lang, "Cookie scan report"))
+	fmt.Fprintf(b, "- **%s** %s\n", Translate(lang, "Scan date:"), data.ScanTime.Format(time.RFC3339))
+	fmt.Fprintf(b, "- **%s** %s\n", Translate(lang, "Scan URL:"), markdownEscaper.Replace(data.ScanURL))
+	fmt.Fprintf(b, "- **%s** %d\n", Translate(lang, "Cookies (in total):"), data.CookieCount)
+	if data.ClassifierVersion != "" {
+		fmt.Fprintf(b, "- **%s** %s\n", Translate(lang, "Classifier version:"), data.ClassifierVersion)
+	}
+
+	for _, record := range data.Records {
+		title := Translate(lang, "Unclassified")
+		if record.Category != "" {
+			title = Translate(lang, record.Category)
+			if record.Inferred {
+				title += " " + Translate(lang, "(inferred)")
+			}
+		}
+
+		fmt.Fprintf(b, "\n## %s (%d)\n\n", markdownEscaper.Replace(title), len(record.Cookies))
+
+		if p := record.Purposes; p != nil {
+			var purposes []string
+			if p.ICCUK != "" {
+				purposes = append(purposes, fmt.Sprintf("**%s** %s", Translate(lang, "ICC UK:"), p.ICCUK))
+			}
+			purposes = append(purposes, fmt.Sprintf("**%s** %s", Translate(lang, "CNIL:"), Translate(lang, p.CNIL)))
+			if len(p.TCFPurposes) > 0 {
+				purposes = append(purposes, fmt.Sprintf("**%s** %s",
+					Translate(lang, "IAB TCF v2 purposes:"), joinInts(p.TCFPurposes, ", ")))
+			}
+			fmt.Fprintf(b, "%s\n\n", strings.Join(purposes, " | "))
+		}
+
+		if record.Inferred {
+			fmt.Fprintf(b, "_%s_\n\n", Translate(lang,
+				"These cookies are not in the classifier database, the category is guessed from heuristic signals."))
+		}
+
+		headers := []string{
+			markdownLabel(lang, "cookie name"),
+			markdownLabel(lang, "provider"),
+			markdownLabel(lang, "expiry"),
+			markdownLabel(lang, "Used Requests:"),
+			markdownLabel(lang, "HttpOnly:"),
+			markdownLabel(lang, "First found:"),
+			markdownLabel(lang, "Description:"),
+		}
+
+		fmt.Fprintf(b, "| %s |\n", strings.Join(headers, " | "))
+		fmt.Fprintf(b, "|%s\n", strings.Repeat(" --- |", len(headers)))
+
+		for _, c := range record.Cookies {
+			httpOnly := no
+			if c.HttpOnly {
+				httpOnly = yes
+			}
+
+			desc := c.Description
+			if c.Inferred {
+				desc = Translate(lang, "%s confidence (%s)",
+					fmt.Sprintf("%.0f%%", c.Confidence*100), strings.Join(c.Signals, ", "))
+			}
+
+			firstFound := c.URL
+			if firstFound == "" {
+				firstFound = "-"
+			}
+
+			cells := []string{
+				"`" + strings.Replace(c.Name, "`", "'", -1) + "`",
+				c.Domain,
+				c.Expiry,
+				fmt.Sprint(c.UsedRequests),
+				httpOnly,
+				firstFound,
+				desc,
+			}
+
+			for i := range cells {
+				cells[i] = markdownEscaper.Replace(cells[i])
+			}
+
+			fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+
+	str = b.String()
+
+	return
+}
@@ -21,20 +21,49 @@ import (
 	"io/ioutil"
 	"os"
 	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CookieTypeHTTP is a cookie set by Set-Cookie response header.
+	CookieTypeHTTP = "http"
+	// CookieTypeScript is a cookie set by scripts through document.cookie.
+	CookieTypeScript = "script"
 )
 
 type reportCookieRecord struct {
-	Name         string
-	Path         string
-	Domain       string
-	Expires      time.Time
-	MaxAge       int
-	Expiry       string
-	Secure       bool
-	HttpOnly     bool
-	UsedRequests int
-	Category     string
-	Description  string
+	Name              string
+	Path              string
+	Domain            string
+	Expires           time.Time
+	MaxAge            int
+	Expiry            string
+	Secure            bool
+	HttpOnly          bool
+	UsedRequests      int
+	Category          string
+	CanonicalCategory string
+	Purposes          *CategoryMapping
+	Description       string
+	Inferred          bool
+	Confidence        float64
+	Signals           []string
+	Type              string
+	FirstSet          float64
+	FirstSetEstimated bool
+	ThirdParty        bool
+	Party             string
+	RegistrableDomain string
+	CNAMECloaked      bool
+	CNAMEChain        []string
+	CNAMETracker      string
+	Tracker           bool
+	TrackerKind       string
+	TrackerRule       string
+	InitiatorChain    []string
+	Destinations      []*cookieDestination
+	Location          *geoLocation
 
 	URL        string
 	RemoteAddr string
@@ -45,18 +74,82 @@ type reportCookieRecord struct {
 	LineNo     int
 }
 
+// cookieDestination is a host the cookie was sent to.
+type cookieDestination struct {
+	Host              string
+	RegistrableDomain string
+	Requests          int
+	ThirdParty        bool
+}
+
 type reportRecord struct {
-	Category    string
-	Description string
-	Cookies     []*reportCookieRecord
+	Category          string
+	CanonicalCategory string
+	Purposes          *CategoryMapping
+	Description       string
+	Inferred          bool
+	Cookies           []*reportCookieRecord
+}
+
+// lifetime returns the cookie lifetime relative to scan time, zero for session cookies.
+func (c *reportCookieRecord) lifetime(scanTime time.Time) time.Duration {
+	if c.MaxAge > 0 {
+		return time.Duration(c.MaxAge) * time.Second
+	}
+	if d := c.Expires.Sub(scanTime); d > 0 {
+		return d
+	}
+	return 0
 }
 
 type reportData struct {
-	ScanTime        time.Time
-	ScanURL         string
-	CookieCount     int
-	ScreenShotImage string
-	Records         []*reportRecord
+	Lang              string
+	ClassifierVersion string
+	ScanTime          time.Time
+	ScanURL           string
+	SiteDomain        string
+	CookieCount       int
+	FirstPartyCount   int
+	ThirdPartyCount   int
+	CNAMECloakedCount int
+	// ScreenShotImage is the inlined page screenshot, kept for consumers of reports before Screenshots
+	ScreenShotImage   string `json:",omitempty"`
+	Screenshots       []*reportScreenshot
+	Timeline          *reportTimeline
+	Initiators        []*initiatorNode
+	TrackersContacted []*contactedTracker
+	ThirdPartyHosts   []*thirdPartyHost
+	GeoIPDatabases    []string
+	DataTransfers     []*dataTransfer
+	Records           []*reportRecord
+	Branding          *Branding `json:"-"`
+}
+
+// LoadReport creates a task from a saved json report, the task could render all outputs except pdf
+// without re-running the scan.
+func LoadReport(tc *TaskConfig, jsonData []byte) (t *Task, err error) {
+	t = NewTask(tc)
+	t.reportData = &reportData{}
+
+	if err = json.Unmarshal(jsonData, t.reportData); err != nil {
+		err = errors.Wrap(err, "decode json report failed")
+		return
+	}
+
+	// reports before separate screenshot artifacts inline a png screenshot of viewport
+	if t.reportData.ScreenShotImage != "" && len(t.reportData.Screenshots) == 0 {
+		t.reportData.Screenshots = []*reportScreenshot{{
+			Type:     ScreenshotPage,
+			MimeType: "image/png",
+			Image:    t.reportData.ScreenShotImage,
+		}}
+	}
+
+	t.startTime = t.reportData.ScanTime
+	t.reportData.Lang = NormalizeLanguage(t.reportData.Lang)
+	t.reportData.Branding = tc.Branding
+
+	return
 }
 
 func (t *Task) OutputJSON(pretty bool) (str string, err error) {
@@ -71,10 +164,35 @@ func (t *Task) OutputJSON(pretty bool) (str string, err error) {
 }
 
 func (t *Task) OutputHTML() (str string, err error) {
-	return outputAsHTML(t.reportData)
+	return outputAsHTML(t.cfg.Templates, t.reportData)
+}
+
+func (t *Task) OutputCSV() (str string, err error) {
+	return outputAsCSV(t.reportData)
+}
+
+func (t *Task) OutputMarkdown() (str string, err error) {
+	return outputAsMarkdown(t.reportData)
+}
+
+func (t *Task) OutputDeclaration(format string) (str string, err error) {
+	return outputAsDeclaration(t.reportData, format)
+}
+
+func (t *Task) OutputConsentConfig(format string) (str string, err error) {
+	return outputAsConsentConfig(t.reportData, format)
+}
+
+func (t *Task) OutputInitiatorGraph(format string) (str string, err error) {
+	return outputAsInitiatorGraph(t.reportData, format)
 }
 
 func (t *Task) OutputPDF() (blob []byte, err error) {
+	if t.driver == nil {
+		err = errors.New("pdf requires a started browser")
+		return
+	}
+
 	var f *os.File
 	if f, err = ioutil.TempFile("", "gdpr_cookie*.html"); err != nil {
 		return
@@ -85,7 +203,7 @@ func (t *Task) OutputPDF() (blob []byte, err error) {
 		_ = os.Remove(tempHTML)
 	}()
 
-	htmlData, err := outputAsHTML(t.reportData)
+	htmlData, err := outputAsHTML(t.cfg.Templates, inlineScreenshots(t.reportData))
 	if err != nil {
 		return
 	}
@@ -94,7 +212,17 @@ func (t *Task) OutputPDF() (blob []byte, err error) {
 	_ = f.Sync()
 	_ = f.Close()
 
-	return outputAsPDF(t.remote, tempHTML)
+	options, err := t.cfg.PDF.printOptions(t.reportData)
+	if err != nil {
+		return
+	}
+
+	timeout := t.cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPDFLoadTimeout
+	}
+
+	return t.driver.PrintToPDF(tempHTML, timeout, options...)
 }
 
 func (t *Task) OutputPDFToFile(filename string) (err error) {
@@ -109,5 +237,5 @@ func (t *Task) OutputPDFToFile(filename string) (err error) {
 }
 
 func (t *Task) FormatEmail() (str string, err error) {
-	return formatEmailContent(t.reportData)
+	return formatEmailContent(t.cfg.Templates, inlineScreenshots(t.reportData))
 }
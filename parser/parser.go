@@ -21,11 +21,12 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/jmoiron/jsonq"
-	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 func (t *Task) parseHeaders(isRequest bool, headers ...map[string]interface{}) []*http.Cookie {
@@ -74,9 +75,11 @@ func (t *Task) parseHeaders(isRequest bool, headers ...map[string]interface{}) [
 	}
 }
 
-func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData []*reportRecord, err error) {
+func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData []*reportRecord,
+	timeline *reportTimeline, err error) {
 	resp := rc.get()
 	var outputs []*outputRecord
+	navStart, navRecord := navigationStart(resp)
 
 	for _, records := range resp {
 		var (
@@ -92,6 +95,7 @@ func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData [
 				if lastRecord != nil && lastRecord.isRequest {
 					// this request should contains redirectResponse
 					if redirectResponse, _ := q.Object("redirectResponse"); redirectResponse != nil {
+						output.respSeq = r.reqSeq
 						output.statusCode, _ = q.Int("redirectResponse", "status")
 						headers, _ := q.Object("redirectResponse", "headers")
 						output.setCookies = t.parseHeaders(false, headers)
@@ -132,6 +136,7 @@ func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData [
 				output.lineNo, _ = q.Int("initiator", "lineNumber")
 				lastHeaders = headers
 			} else {
+				output.respSeq = r.reqSeq
 				output.statusCode, _ = q.Int("response", "status")
 				headers, _ := q.Object("response", "headers")
 				output.setCookies = t.parseHeaders(false, headers)
@@ -169,14 +174,29 @@ func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData [
 
 	var (
 		cookieUsedCount = map[string]int{}
+		cookieDestMap   = map[string]map[string]int{}
 		cookieSeqMap    = map[string]int{}
+		cookieFirstUsed = map[string]float64{}
 		httpCookieMap   = map[string]*http.Cookie{}
-		reportRecords   = map[string]*reportRecord{}
 	)
 
 	for idx, output := range outputs {
+		var reqHost string
+		if reqURLObj, _ := url.Parse(output.url); reqURLObj != nil {
+			reqHost = reqURLObj.Hostname()
+		}
+
 		for _, c := range output.usedCookies {
 			cookieUsedCount[c.Name]++
+			if reqHost != "" {
+				if cookieDestMap[c.Name] == nil {
+					cookieDestMap[c.Name] = map[string]int{}
+				}
+				cookieDestMap[c.Name][reqHost]++
+			}
+			if seq, ok := cookieFirstUsed[c.Name]; !ok || output.reqSeq < seq {
+				cookieFirstUsed[c.Name] = output.reqSeq
+			}
 		}
 		for _, c := range output.setCookies {
 			if i, ok := cookieSeqMap[c.Name]; !ok || outputs[i].reqSeq > output.reqSeq {
@@ -186,45 +206,30 @@ func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData [
 		}
 	}
 
-	for c, idx := range cookieSeqMap {
-		var (
-			cookie     = httpCookieMap[c]
-			category   = ""
-			cookieDesc = ""
-			record     *reportRecord
-			ok         bool
-		)
-
-		if t.cfg.Classifier != nil {
-			category, cookieDesc, _ = t.cfg.Classifier.GetCookieDetail(cookie.Name)
-		}
+	var cookies []*reportCookieRecord
 
-		if record, ok = reportRecords[category]; !ok {
-			record = &reportRecord{
-				Category: category,
-			}
-			reportRecords[category] = record
-		}
+	for c, idx := range cookieSeqMap {
+		cookie := httpCookieMap[c]
 
-		record.Cookies = append(record.Cookies, &reportCookieRecord{
+		cookies = append(cookies, &reportCookieRecord{
 			Name:    cookie.Name,
 			Path:    cookie.Path,
 			Domain:  cookie.Domain,
 			Expires: cookie.Expires,
 			Expiry: func(expiry time.Time, maxAge int) string {
 				if maxAge > 0 {
-					return estimatedDuration(time.Second * time.Duration(maxAge))
+					return estimatedDuration(t.cfg.Lang, time.Second*time.Duration(maxAge))
 				}
 
-				return estimatedDuration(expiry.Sub(t.startTime))
+				return estimatedDuration(t.cfg.Lang, expiry.Sub(t.startTime))
 			}(cookie.Expires, cookie.MaxAge),
 			MaxAge:       cookie.MaxAge,
 			Secure:       cookie.Secure,
 			HttpOnly:     cookie.HttpOnly,
 			UsedRequests: cookieUsedCount[c],
-
-			Category:    category,
-			Description: cookieDesc,
+			Type:         CookieTypeHTTP,
+			FirstSet:     offsetMillis(navStart, outputs[idx].respSeq),
+			Destinations: cookieDestinations(cookieDestMap[c]),
 
 			URL:        outputs[idx].url,
 			RemoteAddr: outputs[idx].remoteAddr,
@@ -236,93 +241,186 @@ func (t *Task) parseResponse(rc *recordCollector) (cookieCount int, resultData [
 		})
 	}
 
-	cookieCount = len(cookieSeqMap)
-
-	// load all cookies from browser api
-	allCookies, err := t.remote.GetAllCookies()
-	if err != nil {
-		err = errors.Wrapf(err, "get all cookies from debugger failed")
-		return
-	}
-
-	for _, cookie := range allCookies {
+	// cookies in browser jar not set by responses
+	for _, cookie := range rc.getCookies() {
 		if _, ok := cookieSeqMap[cookie.Name]; !ok {
-			cookieCount++
-
 			// cookie plant by scripts
-			var (
-				category   = ""
-				cookieDesc = ""
-				record     *reportRecord
-				ok         bool
-			)
-
-			if t.cfg.Classifier != nil {
-				category, cookieDesc, _ = t.cfg.Classifier.GetCookieDetail(cookie.Name)
-			}
-
-			if record, ok = reportRecords[category]; !ok {
-				record = &reportRecord{
-					Category: category,
-				}
-				reportRecords[category] = record
-			}
-
 			expireSec, expireDec := math.Modf(cookie.Expires)
 			expireTime := time.Unix(int64(expireSec), int64(expireDec*1e9)).UTC()
 
-			record.Cookies = append(record.Cookies, &reportCookieRecord{
+			c := &reportCookieRecord{
 				Name:         cookie.Name,
 				Path:         cookie.Path,
 				Domain:       cookie.Domain,
 				Expires:      expireTime,
-				Expiry:       estimatedDuration(expireTime.Sub(t.startTime)),
+				Expiry:       estimatedDuration(t.cfg.Lang, expireTime.Sub(t.startTime)),
 				Secure:       cookie.Secure,
 				HttpOnly:     cookie.HttpOnly,
 				UsedRequests: cookieUsedCount[cookie.Name],
+				Type:         CookieTypeScript,
+				FirstSet:     -1,
+				Destinations: cookieDestinations(cookieDestMap[cookie.Name]),
+			}
+
+			// script cookies are set no later than the first request sending them
+			if seq, ok := cookieFirstUsed[cookie.Name]; ok {
+				c.FirstSet = offsetMillis(navStart, seq)
+				c.FirstSetEstimated = true
+			}
 
-				Category:    category,
-				Description: cookieDesc,
-			})
+			cookies = append(cookies, c)
 		}
 	}
 
-	hasUnclassified := false
+	cookieCount = len(cookies)
 
-	// sort unclassified cookies to the end
-	for c, record := range reportRecords {
-		if c != "" {
-			resultData = append(resultData, record)
-		} else {
-			hasUnclassified = true
+	if navRecord {
+		timeline = &reportTimeline{}
+		if ts, ok := rc.pageEvent(pageEventDOMContentLoaded); ok {
+			timeline.DOMContentLoaded = offsetMillis(navStart, ts)
+		}
+		if ts, ok := rc.pageEvent(pageEventLoad); ok {
+			timeline.Load = offsetMillis(navStart, ts)
 		}
 	}
 
-	if hasUnclassified {
-		for c, record := range reportRecords {
-			if c == "" {
-				resultData = append(resultData, record)
+	// classify all cookies in a single batch lookup
+	var details map[string]*CookieDefinition
+
+	if t.cfg.Classifier != nil {
+		names := make([]string, 0, len(cookies))
+		for _, c := range cookies {
+			names = append(names, c.Name)
+		}
+
+		if details, err = t.cfg.Classifier.GetCookieDetails(names, t.cfg.Lang); err != nil {
+			logrus.WithError(err).Warning("classify cookies failed")
+			err = nil
+		}
+	}
+
+	for _, c := range cookies {
+		t.classifyCookie(c, details[c.Name])
+	}
+
+	resultData = groupCookieRecords(cookies)
+
+	return
+}
+
+// cookieDestinations returns the hosts cookie was sent to, most requested first.
+func cookieDestinations(hosts map[string]int) (destinations []*cookieDestination) {
+	for host, count := range hosts {
+		destinations = append(destinations, &cookieDestination{Host: host, Requests: count})
+	}
+
+	sort.Slice(destinations, func(i, j int) bool {
+		if destinations[i].Requests != destinations[j].Requests {
+			return destinations[i].Requests > destinations[j].Requests
+		}
+		return destinations[i].Host < destinations[j].Host
+	})
+
+	return
+}
+
+// classifyCookie fills the category of cookie using classifier definition, falls back to heuristic inference.
+func (t *Task) classifyCookie(c *reportCookieRecord, def *CookieDefinition) {
+	if def != nil {
+		c.Category, c.Description = def.Category, def.Description
+	}
+
+	if c.Category == "" {
+		if inf := inferCookieCategory(c, c.lifetime(t.startTime)); inf != nil {
+			c.Category = inf.Category
+			c.Inferred = true
+			c.Confidence = inf.Confidence
+			c.Signals = inf.Signals
+		}
+	}
+
+	// map free-form category to the canonical taxonomy
+	if category := LookupCategory(c.Category); category != nil {
+		c.Category = category.Name
+		c.CanonicalCategory = category.ID
+		c.Purposes = &category.Mapping
+	} else if c.Category == "" {
+		c.CanonicalCategory = unclassifiedCategory.ID
+		c.Purposes = &unclassifiedCategory.Mapping
+	}
+}
+
+// groupCookieRecords groups cookies by category in canonical order, inferred categories follow
+// the classified ones and unclassified cookies are sorted to the end.
+func groupCookieRecords(cookies []*reportCookieRecord) (resultData []*reportRecord) {
+	type groupKey struct {
+		category string
+		inferred bool
+	}
+
+	reportRecords := map[groupKey]*reportRecord{}
+
+	for _, c := range cookies {
+		key := groupKey{category: c.Category, inferred: c.Inferred}
+		record, ok := reportRecords[key]
+		if !ok {
+			record = &reportRecord{
+				Category:          c.Category,
+				CanonicalCategory: c.CanonicalCategory,
+				Inferred:          c.Inferred,
+				Purposes:          c.Purposes,
 			}
+			reportRecords[key] = record
+			resultData = append(resultData, record)
+		}
+
+		record.Cookies = append(record.Cookies, c)
+	}
+
+	tier := func(r *reportRecord) int {
+		if r.Category == "" {
+			return 2
+		} else if r.Inferred {
+			return 1
 		}
+		return 0
+	}
+
+	sort.SliceStable(resultData, func(i, j int) bool {
+		if ti, tj := tier(resultData[i]), tier(resultData[j]); ti != tj {
+			return ti < tj
+		}
+		if ri, rj := categoryRank(resultData[i].Category), categoryRank(resultData[j].Category); ri != rj {
+			return ri < rj
+		}
+		return resultData[i].Category < resultData[j].Category
+	})
+
+	for _, r := range resultData {
+		sort.SliceStable(r.Cookies, func(i, j int) bool {
+			return r.Cookies[i].Name < r.Cookies[j].Name
+		})
 	}
 
 	return
 }
 
-func estimatedDuration(d time.Duration) string {
-	if d >= 365*24*time.Hour {
-		return fmt.Sprintf("%.1f year", float64(d)/float64(365*24*time.Hour))
-	} else if d >= 30*24*time.Hour {
-		return fmt.Sprintf("%.1f month", float64(d)/float64(30*24*time.Hour))
-	} else if d >= 24*time.Hour {
-		return fmt.Sprintf("%.1f day", float64(d)/float64(24*time.Hour))
-	} else if d >= time.Hour {
-		return fmt.Sprintf("%.1f hour", float64(d)/float64(time.Hour))
-	} else if d >= time.Minute {
-		return fmt.Sprintf("%.1f min", float64(d)/float64(time.Minute))
-	} else if d >= time.Second {
-		return fmt.Sprintf("%.1f sec", float64(d)/float64(time.Second))
-	} else {
-		return "Session"
+func estimatedDuration(lang string, d time.Duration) string {
+	for _, u := range []struct {
+		unit string
+		d    time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"min", time.Minute},
+		{"sec", time.Second},
+	} {
+		if d >= u.d {
+			return fmt.Sprintf("%.1f %s", float64(d)/float64(u.d), Translate(lang, u.unit))
+		}
 	}
+
+	return Translate(lang, "Session")
 }
@@ -17,24 +17,47 @@
 package parser
 
 import (
-	"bytes"
+	"fmt"
 	"html/template"
-	"path/filepath"
 	"reflect"
-	"time"
-
-	"github.com/raff/godet"
+	"strings"
 )
 
 var (
 	reportTemplate = template.New("report_template")
 )
 
+// cookieRowData is the context of the "cookie_row" template block.
+type cookieRowData struct {
+	Index  int
+	Cookie *reportCookieRecord
+}
+
 func init() {
 	template.Must(reportTemplate.Funcs(template.FuncMap{
 		"isEven": func(v int) bool {
 			return v%2 == 0
 		},
+		"percent": func(v float64) string {
+			return fmt.Sprintf("%.0f%%", v*100)
+		},
+		"join":     strings.Join,
+		"joinInts": joinInts,
+		"stylesheet": func() template.CSS {
+			return template.CSS(reportStylesheet)
+		},
+		"logo": func() template.URL {
+			return reportLogo
+		},
+		"millis": func(v float64) string {
+			return fmt.Sprintf("%.0f ms", v)
+		},
+		"cookieRow": func(index int, cookie *reportCookieRecord) *cookieRowData {
+			return &cookieRowData{Index: index, Cookie: cookie}
+		},
+		"T": func(key string, args ...interface{}) string {
+			return Translate(DefaultLanguage, key, args...)
+		},
 		"len": func(v interface{}) int {
 			rv := reflect.ValueOf(v)
 			switch rv.Kind() {
@@ -46,134 +69,385 @@ func init() {
 		},
 	}).Parse(`<!DOCTYPE html>
 <meta charset="UTF-8">
-<html>
+<html lang="{{.Lang}}">
 <head>
-    <title>Cookie scan report</title>
-    <link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/bootstrap@4.3.1/dist/css/bootstrap.min.css"/>
+    <title>{{T "Cookie scan report"}}</title>
+    <style>{{stylesheet}}{{.Branding.Stylesheet}}</style>
 </head>
 <body>
 <div class="container mt-5">
+    {{block "header" .}}
     <section>
-        <a class="text-right d-block mb-3" href="https://gdprexpert.io">
-            <img class="image w-25" src="https://cdn.jsdelivr.net/gh/CovenantLabs/assets/gdprexpert/logo_io.png"/>
+        <a class="text-right d-block mb-3" href="{{if ne .Branding.Website ""}}{{.Branding.Website}}{{else}}https://gdprexpert.io{{end}}">
+            <img class="image w-25" src="{{if .Branding.LogoURL}}{{.Branding.LogoURL}}{{else}}{{logo}}{{end}}" alt="{{if ne .Branding.CompanyName ""}}{{.Branding.CompanyName}}{{else}}GDPRExpert{{end}}"/>
         </a>
     </section>
+    {{end}}
+    {{block "summary" .}}
     <section class="mb-5">
-        <h2 class="mb-3">Cookie scan report</h2>
+        <h2 class="mb-3">{{T "Cookie scan report"}}</h2>
         <div class="row">
             <div class="col-6">
                 <ul class="list-unstyled">
-                    <li><span class="mr-1">Scan date:</span>{{.ScanTime}}</li>
-                    <li><span class="mr-1">Scan URL:</span>{{.ScanURL}}</li>
-                    <li><span class="mr-1">Cookies (in total):</span>{{.CookieCount}}</li>
+                    <li><span class="mr-1">{{T "Scan date:"}}</span>{{.ScanTime}}</li>
+                    <li><span class="mr-1">{{T "Scan URL:"}}</span>{{.ScanURL}}</li>
+                    <li><span class="mr-1">{{T "Cookies (in total):"}}</span>{{.CookieCount}}</li>
+                    {{if ne .SiteDomain ""}}
+                        <li><span class="mr-1">{{T "First-party cookies:"}}</span>{{.FirstPartyCount}}</li>
+                        <li><span class="mr-1">{{T "Third-party cookies:"}}</span>{{.ThirdPartyCount}}</li>
+                        {{with .ThirdPartyHosts}}
+                            <li><span class="mr-1">{{T "Third-party hosts contacted:"}}</span>{{len .}}</li>
+                        {{end}}
+                        {{if .GeoIPDatabases}}
+                            <li{{if .DataTransfers}} class="text-danger"{{end}}><span class="mr-1">{{T "Countries outside the EU/EEA:"}}</span>{{len .DataTransfers}}</li>
+                        {{end}}
+                        {{if gt .CNAMECloakedCount 0}}
+                            <li class="text-danger"><span class="mr-1">{{T "CNAME cloaked cookies:"}}</span>{{.CNAMECloakedCount}}</li>
+                        {{end}}
+                    {{end}}
+                    {{if ne .ClassifierVersion ""}}
+                        <li><span class="mr-1">{{T "Classifier version:"}}</span>{{.ClassifierVersion}}</li>
+                    {{end}}
                 </ul>
             </div>
             <div class="col-6">
-                {{if ne .ScreenShotImage ""}}
-                    <img src="data:image/png;base64,{{.ScreenShotImage}}" class="img-fluid img-thumbnail"/>
+                {{with .Screenshot "page"}}
+                    <img src="{{.Src}}" class="img-fluid img-thumbnail"/>
+                {{end}}
+                {{with .Screenshot "banner"}}
+                    <p class="mb-1 mt-3"><small><strong>{{T "Consent banner:"}}</strong></small></p>
+                    <img src="{{.Src}}" class="img-fluid img-thumbnail"/>
                 {{end}}
             </div>
         </div>
     </section>
+    {{end}}
+    {{block "timeline" .}}
+    {{with .TimelineView}}
+        {{$view := .}}
+        <section class="mb-5">
+            <h3>{{T "Timeline"}}</h3>
+            <p class="text-muted"><small>{{T "When each cookie was first set, relative to navigation start."}}</small></p>
+            <div class="timeline-row">
+                <div class="timeline-label"></div>
+                <div class="timeline-track">
+                    {{range .Markers}}
+                        <span class="timeline-marker" style="{{.Style}}"><small>{{T .Label}}{{if gt .Time 0.0}} {{millis .Time}}{{end}}</small></span>
+                    {{end}}
+                </div>
+                <div class="timeline-time"></div>
+            </div>
+            {{range .Rows}}
+                <div class="timeline-row" data-party="{{.Cookie.Party}}">
+                    <div class="timeline-label"><small><strong>{{.Cookie.Name}}</strong><span class="text-muted ml-1">{{.Cookie.Domain}}</span></small></div>
+                    <div class="timeline-track">
+                        {{range $view.Markers}}<span class="timeline-line" style="{{.Style}}"></span>{{end}}
+                        <span class="timeline-bar" style="{{.Style}}"></span>
+                    </div>
+                    <div class="timeline-time"><small>{{if .Cookie.FirstSetEstimated}}&le; {{end}}{{millis .Cookie.FirstSet}}</small></div>
+                </div>
+            {{end}}
+            <p class="mt-3">
+                <small>
+                    {{range .Legend}}<span class="timeline-legend" style="{{.Style}}"></span>{{if ne .Category ""}}{{T .Category}}{{else}}{{T "Unclassified"}}{{end}}{{end}}
+                    <span class="mx-2">|</span>
+                    <span class="timeline-legend" style="background-color:#6c757d"></span>{{T "Third-party"}}
+                    <span class="timeline-legend" style="background-color:#6c757d;opacity:.45"></span>{{T "First-party"}}
+                    <span class="timeline-legend" style="border:1px dashed #212529"></span>{{T "Script cookie, set before the first request sending it"}}
+                </small>
+            </p>
+        </section>
+    {{end}}
+    {{end}}
+    {{block "initiators" .}}
+    {{with .InitiatorTree}}
+        <section class="mb-5">
+            <h3>{{T "Initiators"}}</h3>
+            <p class="text-muted"><small>{{T "Requests pulling in third parties and cookies, third-party requests are highlighted."}}</small></p>
+            <ul class="initiator-tree border-0 pl-0">
+                {{range .}}{{template "initiator_node" .}}{{end}}
+            </ul>
+        </section>
+    {{end}}
+    {{end}}
+    {{block "trackers" .}}
+    {{with .TrackersContacted}}
+        <section class="mb-5">
+            <h3>{{T "Trackers contacted"}} ({{len .}})</h3>
+            <table class="table">
+                <thead>
+                <tr class="text-uppercase">
+                    <th scope="col" class="border-top-0">{{T "host"}}</th>
+                    <th scope="col" class="border-top-0">{{T "kind"}}</th>
+                    <th scope="col" class="border-top-0">{{T "requests"}}</th>
+                    <th scope="col" class="border-top-0">{{T "filter rule"}}</th>
+                </tr>
+                </thead>
+                <tbody>
+                {{range $index, $tracker := .}}
+                    <tr class="{{if isEven $index}}bg-light{{end}}">
+                        <td><strong>{{$tracker.Host}}</strong>{{if not $tracker.ThirdParty}}<small class="text-muted ml-1">{{T "First-party"}}</small>{{end}}</td>
+                        <td>{{T $tracker.Kind}}</td>
+                        <td>{{$tracker.Requests}}</td>
+                        <td><small><code>{{$tracker.Rule}}</code><span class="text-muted ml-1">{{$tracker.List}}</span></small></td>
+                    </tr>
+                {{end}}
+                </tbody>
+            </table>
+        </section>
+    {{end}}
+    {{end}}
+    {{block "third_party_hosts" .}}
+    {{with .ThirdPartyHosts}}
+        <section class="mb-5">
+            <h3>{{T "Third-party requests"}} ({{len .}})</h3>
+            <p class="text-muted"><small>{{T "Every request to a third party transfers the visitor's IP address, requests without cookies are included."}}</small></p>
+            <table class="table">
+                <thead>
+                <tr class="text-uppercase">
+                    <th scope="col" class="border-top-0">{{T "host"}}</th>
+                    <th scope="col" class="border-top-0">{{T "requests"}}</th>
+                    <th scope="col" class="border-top-0">{{T "resource types"}}</th>
+                    <th scope="col" class="border-top-0">{{T "first contact"}}</th>
+                </tr>
+                </thead>
+                <tbody>
+                {{range $index, $host := .}}
+                    <tr class="{{if isEven $index}}bg-light{{end}}">
+                        <td><strong>{{$host.Host}}</strong>{{with $host.Vendor}}<small class="text-muted ml-1">{{.}}</small>{{end}}
+                            {{if $host.Locations}}{{range $host.Locations}}<br><small class="{{if .EEA}}text-muted{{else}}text-danger{{end}}">{{.IP}} ({{.Label}})</small>{{end}}
+                            {{else}}{{with $host.RemoteAddrs}}<br><small class="text-muted">{{join . ", "}}</small>{{end}}{{end}}</td>
+                        <td>{{$host.Requests}}</td>
+                        <td><small>{{join $host.ResourceTypes ", "}}</small></td>
+                        <td><small>{{millis $host.FirstContact}}{{if $host.PreConsent}}<span class="text-danger ml-1">{{T "before consent"}}</span>{{end}}</small></td>
+                    </tr>
+                {{end}}
+                </tbody>
+            </table>
+        </section>
+    {{end}}
+    {{end}}
+    {{block "data_transfers" .}}
+    {{if .GeoIPDatabases}}
+        <section class="mb-5">
+            <h3>{{T "Data transfers outside the EU/EEA"}} ({{len .DataTransfers}})</h3>
+            <p class="text-muted"><small>{{T "Server locations from %s, transfers to these countries require safeguards such as an adequacy decision or standard contractual clauses." (join .GeoIPDatabases ", ")}}</small></p>
+            {{if .DataTransfers}}
+            <table class="table">
+                <thead>
+                <tr class="text-uppercase">
+                    <th scope="col" class="border-top-0">{{T "country"}}</th>
+                    <th scope="col" class="border-top-0">{{T "host"}}</th>
+                    <th scope="col" class="border-top-0">{{T "networks"}}</th>
+                    <th scope="col" class="border-top-0">{{T "cookies"}}</th>
+                </tr>
+                </thead>
+                <tbody>
+                {{range $index, $transfer := .DataTransfers}}
+                    <tr class="{{if isEven $index}}bg-light{{end}}">
+                        <td><strong>{{$transfer.Country}}</strong>{{with $transfer.CountryName}}<small class="text-muted ml-1">{{.}}</small>{{end}}</td>
+                        <td><small>{{join $transfer.Hosts ", "}}</small></td>
+                        <td><small>{{join $transfer.Networks ", "}}</small></td>
+                        <td><small>{{if $transfer.Cookies}}{{join $transfer.Cookies ", "}}{{else}}-{{end}}</small></td>
+                    </tr>
+                {{end}}
+                </tbody>
+            </table>
+            {{else}}
+            <p>{{T "No servers outside the EU/EEA were contacted."}}</p>
+            {{end}}
+        </section>
+    {{end}}
+    {{end}}
+    {{block "party_filter" .}}
+    {{if ne .SiteDomain ""}}
+        <section class="mb-3 d-print-none">
+            <script>
+                function filterParty(party) {
+                    Array.prototype.forEach.call(document.querySelectorAll("[data-party]"), function (e) {
+                        e.style.display = party === "" || e.getAttribute("data-party") === party ? "" : "none";
+                    });
+                }
+            </script>
+            <small>
+                <strong class="mr-3">{{T "Show:"}}</strong>
+                <label class="mr-3"><input type="radio" name="party" onclick="filterParty('')" checked> {{T "All cookies"}}</label>
+                <label class="mr-3"><input type="radio" name="party" onclick="filterParty('first-party')"> {{T "First-party"}}</label>
+                <label class="mr-3"><input type="radio" name="party" onclick="filterParty('third-party')"> {{T "Third-party"}}</label>
+            </small>
+        </section>
+    {{end}}
+    {{end}}
     {{range $record := .Records}}
         <section>
-            <h3>{{if ne $record.Category ""}}{{$record.Category}}{{else}}Unclassified{{end}}
+            <h3>{{if ne $record.Category ""}}{{T $record.Category}}{{if $record.Inferred}}
+                <small class="text-muted">{{T "(inferred)"}}</small>{{end}}{{else}}{{T "Unclassified"}}{{end}}
                 &nbsp;({{len $record.Cookies}})</h3>
+            {{with $record.Purposes}}
+                <p class="mb-1">
+                    <small>
+                        {{if ne .ICCUK ""}}<strong class="mr-1">{{T "ICC UK:"}}</strong>{{.ICCUK}}<span class="mx-2">|</span>{{end}}
+                        <strong class="mr-1">{{T "CNIL:"}}</strong>{{T .CNIL}}
+                        {{if .TCFPurposes}}<span class="mx-2">|</span><strong class="mr-1">{{T "IAB TCF v2 purposes:"}}</strong>{{joinInts .TCFPurposes ", "}}{{end}}
+                    </small>
+                </p>
+            {{end}}
+            {{if $record.Inferred}}
+                <p class="text-muted"><small>{{T "These cookies are not in the classifier database, the category is guessed from heuristic signals."}}</small></p>
+            {{end}}
             <p class="border-top pt-3">
                 <!--
                 {{if ne $record.Description ""}}
                     {{$record.Description}}
                 {{else}}
-                    We don’t have enough information about this cookie or the website hosting it to be able to assign it to a category at this time.
+                    {{T "We don’t have enough information about this cookie or the website hosting it to be able to assign it to a category at this time."}}
                 {{end}}
                 -->
             </p>
             <table class="table border-top-0">
                 <thead>
                 <tr class="text-uppercase">
-                    <th scope="col" class="border-top-0">cookie name</th>
-                    <th scope="col" class="border-top-0">provider</th>
-                    <th scope="col" class="border-top-0">expiry</th>
+                    <th scope="col" class="border-top-0">{{T "cookie name"}}</th>
+                    <th scope="col" class="border-top-0">{{T "provider"}}</th>
+                    <th scope="col" class="border-top-0">{{T "expiry"}}</th>
                 </tr>
                 </thead>
                 <tbody>
                 {{range $index, $cookie := $record.Cookies}}
-                    <tr class="{{if isEven $index}}bg-light{{end}}">
-                        <td><strong>{{$cookie.Name}}</strong></td>
-                        <td>{{$cookie.Domain}}</td>
-                        <td>{{$cookie.Expiry}}</td>
+                    {{block "cookie_row" (cookieRow $index $cookie)}}
+                    <tr class="{{if isEven .Index}}bg-light{{end}}" data-party="{{.Cookie.Party}}">
+                        <td><strong>{{.Cookie.Name}}</strong></td>
+                        <td>{{.Cookie.Domain}}{{if eq .Cookie.Party "third-party"}}<small class="text-danger ml-1">{{T "Third-party"}}</small>{{end}}{{if .Cookie.CNAMECloaked}}
+                            <small class="text-danger ml-1">{{T "CNAME cloaked"}}</small>{{end}}{{if .Cookie.Tracker}}
+                            <small class="text-danger ml-1">{{T .Cookie.TrackerKind}}</small>{{end}}</td>
+                        <td>{{.Cookie.Expiry}}</td>
                     </tr>
-                    <tr class="{{if isEven $index}}bg-light{{end}}">
+                    <tr class="{{if isEven .Index}}bg-light{{end}}" data-party="{{.Cookie.Party}}">
                         <td colspan="3" class="border-top-0 pt-0">
                             <ul class="list-unstyled">
                                 <li>
-                                    <small><strong class="mr-1">First found:</strong>{{$cookie.URL}}</small>
+                                    <small><strong class="mr-1">{{T "First found:"}}</strong>{{.Cookie.URL}}</small>
                                 </li>
                                 <li>
-                                    <small><strong class="mr-1">Initiator:</strong>{{$cookie.Initiator}}</small>
+                                    <small><strong class="mr-1">{{T "Initiator:"}}</strong>{{.Cookie.Initiator}}</small>
                                 </li>
+                                {{if .Cookie.Tracker}}
+                                    <li>
+                                        <small><strong class="mr-1">{{T "Filter rule:"}}</strong><code>{{.Cookie.TrackerRule}}</code></small>
+                                    </li>
+                                {{end}}
+                                {{if .Cookie.CNAMECloaked}}
+                                    <li>
+                                        <small><strong class="mr-1">{{T "CNAME chain:"}}</strong>{{join .Cookie.CNAMEChain " → "}} ({{.Cookie.CNAMETracker}})</small>
+                                    </li>
+                                {{end}}
+                                {{if gt (len .Cookie.InitiatorChain) 1}}
+                                    <li>
+                                        <small><strong class="mr-1">{{T "Loaded via:"}}</strong>{{join .Cookie.InitiatorChain " → "}}</small>
+                                    </li>
+                                {{end}}
                                 <li>
-                                    <small><strong class="mr-1">Source:</strong>
-                                        {{if ne $cookie.Source "" }}{{$cookie.Source}}{{if gt $cookie.LineNo 0}}: {{$cookie.LineNo}}{{end}}{{else}}-{{end}}
+                                    <small><strong class="mr-1">{{T "Source:"}}</strong>
+                                        {{if ne .Cookie.Source "" }}{{.Cookie.Source}}{{if gt .Cookie.LineNo 0}}: {{.Cookie.LineNo}}{{end}}{{else}}-{{end}}
                                     </small>
                                 </li>
                                 <li>
-                                    <small><strong class="mr-1">Server&nbsp;Address:</strong>{{$cookie.RemoteAddr}}
+                                    <small><strong class="mr-1 text-nowrap">{{T "Server Address:"}}</strong>{{.Cookie.RemoteAddr}}{{with .Cookie.Location}}
+                                        <span class="ml-1{{if not .EEA}} text-danger{{end}}">({{.Label}})</span>{{end}}
                                     </small>
                                 </li>
                                 <li>
                                     <small>
-                                        <strong class="mr-1">Mime&nbsp;Type:</strong>{{if ne $cookie.MimeType ""}}{{$cookie.MimeType}}{{else}}-{{end}}
+                                        <strong class="mr-1 text-nowrap">{{T "Mime Type:"}}</strong>{{if ne .Cookie.MimeType ""}}{{.Cookie.MimeType}}{{else}}-{{end}}
                                     </small>
                                 </li>
                                 <li>
                                     <small>
-                                        <strong class="mr-1">Used&nbsp;Requests:</strong>{{$cookie.UsedRequests}}
+                                        <strong class="mr-1 text-nowrap">{{T "Used Requests:"}}</strong>{{.Cookie.UsedRequests}}
                                     </small>
                                 </li>
+                                {{with .Cookie.Destinations}}
+                                    <li>
+                                        <details>
+                                            <summary><small><strong class="mr-1">{{T "Sent to:"}}</strong>{{T "%d hosts" (len .)}}</small></summary>
+                                            <ul class="list-unstyled pl-3">
+                                                {{range .}}
+                                                    <li><small><span{{if .ThirdParty}} class="text-danger"{{end}}>{{.Host}}</span>
+                                                        <span class="text-muted ml-1">{{T "%d requests" .Requests}}, {{if .ThirdParty}}{{T "Third-party"}}{{else}}{{T "First-party"}}{{end}}</span></small></li>
+                                                {{end}}
+                                            </ul>
+                                        </details>
+                                    </li>
+                                {{end}}
                                 <li>
                                     <small>
-                                        <strong class="mr-1">HttpOnly:</strong>{{if $cookie.HttpOnly}}yes{{else}}no{{end}}
+                                        <strong class="mr-1">{{T "HttpOnly:"}}</strong>{{if .Cookie.HttpOnly}}{{T "yes"}}{{else}}{{T "no"}}{{end}}
                                     </small>
                                 </li>
-                                <li>
-                                    <small><strong class="mr-1">Description:</strong>{{$cookie.Description}}</small>
-                                </li>
+                                {{if .Cookie.Inferred}}
+                                    <li>
+                                        <small><strong class="mr-1">{{T "Inferred:"}}</strong>{{T "%s confidence (%s)" (percent .Cookie.Confidence) (join .Cookie.Signals ", ")}}</small>
+                                    </li>
+                                {{else}}
+                                    <li>
+                                        <small><strong class="mr-1">{{T "Description:"}}</strong>{{.Cookie.Description}}</small>
+                                    </li>
+                                {{end}}
                             </ul>
                         </td>
                     </tr>
+                    {{end}}
                 {{end}}
                 </tbody>
             </table>
         </section>
     {{end}}
+    {{block "footer" .}}
+    {{if .Branding.IsCustom}}
+        <footer class="border-top pt-3 mb-5 text-muted">
+            <small>
+                {{if ne .Branding.CompanyName ""}}
+                    <div>{{T "Report prepared by %s" .Branding.CompanyName}}{{if ne .Branding.Website ""}}
+                        <span class="mx-2">|</span><a href="{{.Branding.Website}}">{{.Branding.Website}}</a>{{end}}</div>
+                {{end}}
+                {{if .Branding.HasContact}}
+                    <div>
+                        <strong class="mr-1">{{T "Contact:"}}</strong>
+                        {{with .Branding.ContactEmail}}<a href="mailto:{{.}}">{{.}}</a>{{end}}
+                        {{with .Branding.ContactPhone}}<span class="mx-2">|</span>{{.}}{{end}}
+                        {{with .Branding.ContactAddress}}<span class="mx-2">|</span>{{.}}{{end}}
+                    </div>
+                {{end}}
+            </small>
+        </footer>
+    {{end}}
+    {{end}}
 </div>
 </body>
-</html>`))
-}
-
-func outputAsHTML(data *reportData) (str string, err error) {
-	buf := new(bytes.Buffer)
-	err = reportTemplate.Execute(buf, data)
-	str = buf.String()
-	return
+</html>
+{{define "initiator_label"}}
+    <small>{{with .Initiator}}<span class="text-muted mr-1">{{.}}</span>{{end}}<span{{if .ThirdParty}} class="text-danger"{{end}}>{{.URL}}</span>{{with .FilterKind}}<small class="text-danger ml-1">[{{T .}}]</small>{{end}}{{with .Location}}<small class="ml-1{{if not .EEA}} text-danger{{end}}">{{.Country}}</small>{{end}}{{with .Cookies}}
+        <strong class="ml-1">{{T "sets cookies:"}}</strong> {{join . ", "}}{{end}}</small>
+{{end}}
+{{define "initiator_node"}}
+    <li>
+        {{if .Children}}
+            <details{{if .HasCookies}} open{{end}}>
+                <summary>{{template "initiator_label" .}}</summary>
+                <ul class="initiator-tree">
+                    {{range .Children}}{{template "initiator_node" .}}{{end}}
+                </ul>
+            </details>
+        {{else}}
+            {{template "initiator_label" .}}
+        {{end}}
+    </li>
+{{end}}`))
 }
 
-func outputAsPDF(remote *godet.RemoteDebugger, htmlFile string) (pdfBytes []byte, err error) {
-	var tab *godet.Tab
-
-	htmlFile, _ = filepath.Abs(htmlFile)
-	fileLink := "file://" + htmlFile
-
-	if tab, err = remote.NewTab(fileLink); err != nil {
-		return
-	}
-	if err = remote.ActivateTab(tab); err != nil {
-		return
+func outputAsHTML(templates *Templates, data *reportData) (str string, err error) {
+	if data.Branding == nil {
+		data.Branding = &Branding{}
 	}
-
-	// wait for page to load
-	time.Sleep(time.Second)
-
-	return remote.PrintToPDF(godet.PortraitMode())
+	return executeLocalized(templates.reportTemplate(), data.Lang, data)
 }
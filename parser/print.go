@@ -0,0 +1,267 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/raff/godet"
+)
+
+const (
+	// DefaultPDFLoadTimeout is the max duration to wait for the report page and its fonts to load.
+	DefaultPDFLoadTimeout = 30 * time.Second
+
+	pdfHeaderFooterStyle = "font-size:8px;width:100%;margin:0 0.4in;display:flex;justify-content:space-between;color:#6c757d;"
+)
+
+// paperSizes are the named paper sizes in inches.
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+}
+
+// PDFOptions contains the pdf rendering options, empty values keep chrome defaults.
+// Lengths accept in, cm, mm and px units and default to inches.
+type PDFOptions struct {
+	// Paper is a named paper size (letter, legal, tabloid, a3, a4, a5) or "<width>x<height>", e.g. 210x297mm.
+	Paper     string
+	Landscape bool
+	// Margin is a css like shorthand with 1, 2 or 4 lengths, e.g. "10mm" or "0.4in 0.6in".
+	Margin string
+	Scale  float64
+	// HeaderFooter enables the page header and footer, default templates show scan metadata and page numbers.
+	HeaderFooter bool
+	// HeaderTemplate and FooterTemplate are html templates executed with report data, elements with class
+	// pageNumber, totalPages, date, title and url are filled by chrome.
+	HeaderTemplate string
+	FooterTemplate string
+}
+
+const defaultPDFHeaderTemplate = `<div style="` + pdfHeaderFooterStyle + `">
+<span>{{.ScanURL}}</span><span>{{T "Scan date:"}} {{.ScanTime.Format "2006-01-02 15:04 MST"}}</span>
+</div>`
+
+const defaultPDFFooterTemplate = `<div style="` + pdfHeaderFooterStyle + `">
+<span>{{if ne .ClassifierVersion ""}}{{T "Classifier version:"}} {{.ClassifierVersion}}{{end}}</span>
+<span>{{T "Page"}} <span class="pageNumber"></span> / <span class="totalPages"></span></span>
+</div>`
+
+// pdfReadyScript resolves true after the load event and web fonts of the page, false if the tab is not navigated yet.
+// Collapsed details of the report are expanded for printing.
+const pdfReadyScript = `new Promise(function (resolve) {
+  if (location.href === "about:blank") {
+    resolve(false);
+    return;
+  }
+  var ready = function () {
+    Array.prototype.forEach.call(document.querySelectorAll("details"), function (d) {
+      d.open = true;
+    });
+    (document.fonts ? document.fonts.ready : Promise.resolve()).then(function () {
+      resolve(true);
+    });
+  };
+  if (document.readyState === "complete") {
+    ready();
+  } else {
+    window.addEventListener("load", ready);
+  }
+  setTimeout(function () {
+    resolve(false);
+  }, 5000);
+})`
+
+// parseLength parses a length with optional unit to inches.
+func parseLength(s string) (inches float64, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	factor := 1.0
+
+	for unit, f := range map[string]float64{"in": 1, "cm": 1 / 2.54, "mm": 1 / 25.4, "px": 1.0 / 96} {
+		if strings.HasSuffix(s, unit) {
+			s, factor = strings.TrimSpace(strings.TrimSuffix(s, unit)), f
+			break
+		}
+	}
+
+	if inches, err = strconv.ParseFloat(s, 64); err != nil || inches < 0 {
+		err = errors.Errorf("invalid length: %s", s)
+		return
+	}
+
+	inches *= factor
+	return
+}
+
+func (o *PDFOptions) paperSize() (width float64, height float64, err error) {
+	paper := strings.ToLower(strings.TrimSpace(o.Paper))
+	if size, ok := paperSizes[paper]; ok {
+		return size[0], size[1], nil
+	}
+
+	parts := strings.Split(paper, "x")
+	if len(parts) != 2 {
+		err = errors.Errorf("invalid paper size: %s", o.Paper)
+		return
+	}
+
+	// unit of height applies to width, e.g. 210x297mm
+	unit := strings.TrimLeft(parts[1], "0123456789. ")
+	if strings.TrimLeft(parts[0], "0123456789. ") == "" {
+		parts[0] += unit
+	}
+
+	if width, err = parseLength(parts[0]); err == nil {
+		height, err = parseLength(parts[1])
+	}
+	if err == nil && (width == 0 || height == 0) {
+		err = errors.Errorf("invalid paper size: %s", o.Paper)
+	}
+
+	return
+}
+
+func (o *PDFOptions) margins() (top, bottom, left, right float64, err error) {
+	var values []float64
+	for _, f := range strings.Fields(o.Margin) {
+		var v float64
+		if v, err = parseLength(f); err != nil {
+			return
+		}
+		values = append(values, v)
+	}
+
+	switch len(values) {
+	case 1:
+		top, bottom, left, right = values[0], values[0], values[0], values[0]
+	case 2:
+		top, bottom, left, right = values[0], values[0], values[1], values[1]
+	case 4:
+		// css order: top, right, bottom, left
+		top, right, bottom, left = values[0], values[1], values[2], values[3]
+	default:
+		err = errors.Errorf("invalid margin: %s", o.Margin)
+	}
+
+	return
+}
+
+// Validate checks the paper size, margin and scale options.
+func (o *PDFOptions) Validate() (err error) {
+	if o == nil {
+		return
+	}
+	if o.Paper != "" {
+		if _, _, err = o.paperSize(); err != nil {
+			return
+		}
+	}
+	if o.Margin != "" {
+		if _, _, _, _, err = o.margins(); err != nil {
+			return
+		}
+	}
+	// chrome accepts scale between 0.1 and 2
+	if o.Scale != 0 && (o.Scale < 0.1 || o.Scale > 2) {
+		err = errors.Errorf("invalid scale: %v, should be between 0.1 and 2", o.Scale)
+	}
+
+	return
+}
+
+// printOptions converts the options to godet print options, header and footer templates are executed with report data.
+func (o *PDFOptions) printOptions(data *reportData) (options []godet.PrintToPDFOption, err error) {
+	options = append(options, godet.PortraitMode(), godet.PrintBackground())
+
+	if o == nil {
+		return
+	}
+
+	if err = o.Validate(); err != nil {
+		return
+	}
+
+	if o.Landscape {
+		options = append(options, godet.LandscapeMode())
+	}
+	if o.Paper != "" {
+		width, height, _ := o.paperSize()
+		options = append(options, godet.Dimensions(width, height))
+	}
+	if o.Margin != "" {
+		top, bottom, left, right, _ := o.margins()
+		options = append(options, godet.Margins(top, bottom, left, right))
+	}
+	if o.Scale != 0 {
+		options = append(options, godet.Scale(o.Scale))
+	}
+
+	if o.HeaderFooter || o.HeaderTemplate != "" || o.FooterTemplate != "" {
+		header, footer := o.HeaderTemplate, o.FooterTemplate
+		if header == "" && footer == "" {
+			header, footer = defaultPDFHeaderTemplate, defaultPDFFooterTemplate
+		}
+
+		if header, err = executePDFTemplate("pdf_header", header, data); err != nil {
+			return
+		}
+		if footer, err = executePDFTemplate("pdf_footer", footer, data); err != nil {
+			return
+		}
+
+		// chrome prints its own default header and footer for empty templates
+		if header == "" {
+			header = "<span></span>"
+		}
+		if footer == "" {
+			footer = "<span></span>"
+		}
+
+		options = append(options, godet.DisplayHeaderFooter(), func(o map[string]interface{}) {
+			o["headerTemplate"] = header
+			o["footerTemplate"] = footer
+		})
+	}
+
+	return
+}
+
+func executePDFTemplate(name string, text string, data *reportData) (str string, err error) {
+	tpl, err := template.New(name).Funcs(template.FuncMap{
+		"T": func(key string, args ...interface{}) string {
+			return Translate(DefaultLanguage, key, args...)
+		},
+	}).Parse(text)
+	if err != nil {
+		err = errors.Wrapf(err, "parse %s template failed", name)
+		return
+	}
+
+	if str, err = executeLocalized(tpl, data.Lang, data); err != nil {
+		err = errors.Wrapf(err, "execute %s template failed", name)
+	}
+
+	return
+}
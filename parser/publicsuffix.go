@@ -0,0 +1,322 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PartyFirst is a cookie or request of the scanned site.
+	PartyFirst = "first-party"
+	// PartyThird is a cookie or request of another site.
+	PartyThird = "third-party"
+)
+
+// PublicSuffixList matches hosts against public suffix rules to find the registrable domain (eTLD+1).
+type PublicSuffixList struct {
+	rules      map[string]bool
+	wildcards  map[string]bool
+	exceptions map[string]bool
+}
+
+var (
+	publicSuffixLock sync.RWMutex
+	publicSuffixes   *PublicSuffixList
+)
+
+func init() {
+	publicSuffixes, _ = ParsePublicSuffixList(strings.NewReader(bundledPublicSuffixList))
+}
+
+// ParsePublicSuffixList parses the rules in the format of https://publicsuffix.org/list/public_suffix_list.dat.
+func ParsePublicSuffixList(r io.Reader) (l *PublicSuffixList, err error) {
+	l = &PublicSuffixList{
+		rules:      map[string]bool{},
+		wildcards:  map[string]bool{},
+		exceptions: map[string]bool{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		// rules end at the first whitespace
+		rule := toASCIIDomain(strings.Fields(line)[0])
+
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			l.exceptions[rule[1:]] = true
+		case strings.HasPrefix(rule, "*."):
+			l.wildcards[rule[2:]] = true
+		default:
+			l.rules[rule] = true
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		err = errors.Wrap(err, "read public suffix list failed")
+		return
+	}
+
+	if len(l.rules) == 0 && len(l.wildcards) == 0 {
+		err = errors.New("empty public suffix list")
+	}
+
+	return
+}
+
+// LoadPublicSuffixList loads the public suffix list file downloaded from publicsuffix.org.
+func LoadPublicSuffixList(file string) (l *PublicSuffixList, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		err = errors.Wrapf(err, "open public suffix list %s failed", file)
+		return
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return ParsePublicSuffixList(f)
+}
+
+// SetPublicSuffixList replaces the bundled public suffix list used by first/third-party classification.
+func SetPublicSuffixList(l *PublicSuffixList) {
+	if l == nil {
+		return
+	}
+
+	publicSuffixLock.Lock()
+	defer publicSuffixLock.Unlock()
+
+	publicSuffixes = l
+}
+
+// Len returns the number of rules.
+func (l *PublicSuffixList) Len() int {
+	return len(l.rules) + len(l.wildcards) + len(l.exceptions)
+}
+
+// PublicSuffix returns the longest public suffix of host, the top level domain if no rule matches.
+func (l *PublicSuffixList) PublicSuffix(host string) string {
+	host = strings.ToLower(strings.Trim(host, "."))
+	labels := strings.Split(host, ".")
+
+	for i := range labels {
+		suffix := strings.Join(labels[i:], ".")
+
+		if l.exceptions[suffix] {
+			return strings.Join(labels[i+1:], ".")
+		}
+		if l.rules[suffix] || (i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")]) {
+			return suffix
+		}
+	}
+
+	// the default "*" rule
+	return labels[len(labels)-1]
+}
+
+// EffectiveTLDPlusOne returns the registrable domain of host, host itself for ip addresses and public suffixes.
+func (l *PublicSuffixList) EffectiveTLDPlusOne(host string) string {
+	host = strings.ToLower(strings.Trim(host, "."))
+	if host == "" || net.ParseIP(host) != nil {
+		return host
+	}
+
+	suffix := l.PublicSuffix(host)
+	if len(host) <= len(suffix) {
+		return host
+	}
+
+	rest := host[:len(host)-len(suffix)-1]
+	if i := strings.LastIndexByte(rest, '.'); i >= 0 {
+		rest = rest[i+1:]
+	}
+
+	return rest + "." + suffix
+}
+
+// registrableDomain returns the eTLD+1 of host using the current public suffix list.
+func registrableDomain(host string) string {
+	publicSuffixLock.RLock()
+	defer publicSuffixLock.RUnlock()
+
+	return publicSuffixes.EffectiveTLDPlusOne(toASCIIDomain(host))
+}
+
+// isThirdParty reports whether the domain belongs to another registrable domain than the scanned site.
+func isThirdParty(siteHost string, domain string) bool {
+	if siteHost == "" || domain == "" {
+		return false
+	}
+	return registrableDomain(siteHost) != registrableDomain(domain)
+}
+
+// classifyParties marks cookies and their destinations as first-party or third-party to the scanned site.
+func classifyParties(site string, records []*reportRecord) (siteDomain string, firstParty int, thirdParty int) {
+	u, err := url.Parse(site)
+	if err != nil {
+		return
+	}
+
+	siteHost := u.Hostname()
+	siteDomain = registrableDomain(siteHost)
+
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			c.RegistrableDomain = registrableDomain(c.Domain)
+			c.ThirdParty = isThirdParty(siteHost, c.Domain)
+			if c.ThirdParty {
+				c.Party = PartyThird
+				thirdParty++
+			} else {
+				c.Party = PartyFirst
+				firstParty++
+			}
+
+			for _, d := range c.Destinations {
+				d.RegistrableDomain = registrableDomain(d.Host)
+				d.ThirdParty = isThirdParty(siteHost, d.Host)
+			}
+		}
+	}
+
+	return
+}
+
+// toASCIIDomain converts the internationalized labels of domain to punycode.
+func toASCIIDomain(domain string) string {
+	labels := strings.Split(strings.ToLower(domain), ".")
+	for i, label := range labels {
+		for _, r := range label {
+			if r >= 0x80 {
+				labels[i] = "xn--" + punycodeEncode(label)
+				break
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// punycodeEncode encodes the label using the bootstring algorithm of RFC 3492.
+func punycodeEncode(label string) string {
+	const (
+		base        = 36
+		tMin        = 1
+		tMax        = 26
+		skew        = 38
+		damp        = 700
+		initialBias = 72
+		initialN    = 128
+	)
+
+	digit := func(d int32) byte {
+		if d < 26 {
+			return byte('a' + d)
+		}
+		return byte('0' + d - 26)
+	}
+
+	adapt := func(delta, points int32, first bool) int32 {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / points
+		k := int32(0)
+		for delta > ((base-tMin)*tMax)/2 {
+			delta /= base - tMin
+			k += base
+		}
+		return k + (base-tMin+1)*delta/(delta+skew)
+	}
+
+	runes := []rune(label)
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+
+	basic := int32(len(out))
+	h := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := int32(initialN), int32(0), int32(initialBias)
+
+	for int(h) < len(runes) {
+		m := int32(0x7fffffff)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		delta += (m - n) * (h + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := int32(base); ; k += base {
+				t := k - bias
+				if t < tMin {
+					t = tMin
+				} else if t > tMax {
+					t = tMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, digit(t+(q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			out = append(out, digit(q))
+
+			bias = adapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(out)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEffectiveTLDPlusOne(t *testing.T) {
+	for _, c := range []struct {
+		host   string
+		domain string
+	}{
+		{"www.example.com", "example.com"},
+		{".example.com", "example.com"},
+		{"example.com", "example.com"},
+		{"com", "com"},
+		{"shop.example.co.uk", "example.co.uk"},
+		{"www.tienda.com.ar", "tienda.com.ar"},
+		{"www.shop.co.th", "shop.co.th"},
+		{"news.site.com.vn", "site.com.vn"},
+		{"a.b.store.com.sa", "store.com.sa"},
+		{"www.bank.com.ph", "bank.com.ph"},
+		{"www.paper.com.ng", "paper.com.ng"},
+		{"www.portal.com.eg", "portal.com.eg"},
+		{"www.daraz.com.pk", "daraz.com.pk"},
+		{"user.github.io", "user.github.io"},
+		{"host.unknowntld", "host.unknowntld"},
+		{"192.168.1.1", "192.168.1.1"},
+		{"::1", "::1"},
+		// wildcard and exception rules, *.kawasaki.jp and !city.kawasaki.jp
+		{"foo.bar.kawasaki.jp", "foo.bar.kawasaki.jp"},
+		{"a.foo.bar.kawasaki.jp", "foo.bar.kawasaki.jp"},
+		{"bar.kawasaki.jp", "bar.kawasaki.jp"},
+		{"city.kawasaki.jp", "city.kawasaki.jp"},
+		{"www.city.kawasaki.jp", "city.kawasaki.jp"},
+		{"b.a.ck", "b.a.ck"},
+		{"www.ck", "www.ck"},
+		// internationalized rules and hosts are matched as punycode
+		{"www.例子.公司.cn", "xn--fsqu00a.xn--55qx5d.cn"},
+		{"www.xn--fsqu00a.xn--55qx5d.cn", "xn--fsqu00a.xn--55qx5d.cn"},
+		{"shop.bücher.example.中国", "example.xn--fiqs8s"},
+		{"www.münchen.de", "xn--mnchen-3ya.de"},
+	} {
+		if domain := registrableDomain(c.host); domain != c.domain {
+			t.Errorf("registrable domain of %s: got %s, want %s", c.host, domain, c.domain)
+		}
+	}
+}
+
+func TestParsePublicSuffixList(t *testing.T) {
+	l, err := ParsePublicSuffixList(strings.NewReader(`// comment
+co.example
+*.wild.example
+!keep.wild.example
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Len() != 3 {
+		t.Errorf("rules: got %d, want 3", l.Len())
+	}
+
+	for _, c := range []struct {
+		host   string
+		suffix string
+		domain string
+	}{
+		{"a.b.co.example", "co.example", "b.co.example"},
+		{"a.b.wild.example", "b.wild.example", "a.b.wild.example"},
+		{"a.keep.wild.example", "wild.example", "keep.wild.example"},
+		{"a.b.other", "other", "b.other"},
+	} {
+		if suffix := l.PublicSuffix(c.host); suffix != c.suffix {
+			t.Errorf("public suffix of %s: got %s, want %s", c.host, suffix, c.suffix)
+		}
+		if domain := l.EffectiveTLDPlusOne(c.host); domain != c.domain {
+			t.Errorf("registrable domain of %s: got %s, want %s", c.host, domain, c.domain)
+		}
+	}
+
+	if _, err = ParsePublicSuffixList(strings.NewReader("// only comments\n")); err == nil {
+		t.Error("empty public suffix list should be rejected")
+	}
+}
+
+func TestIsThirdParty(t *testing.T) {
+	for _, c := range []struct {
+		site, domain string
+		third        bool
+	}{
+		{"www.example.com.ar", ".example.com.ar", false},
+		{"www.example.com.ar", ".other.com.ar", true},
+		{"www.example.co.th", "cdn.example.co.th", false},
+		{"alice.github.io", "bob.github.io", true},
+	} {
+		if third := isThirdParty(c.site, c.domain); third != c.third {
+			t.Errorf("%s on %s: got third-party %v, want %v", c.domain, c.site, third, c.third)
+		}
+	}
+}
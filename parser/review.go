@@ -0,0 +1,247 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusAssigned = "assigned"
+	ReviewStatusPromoted = "promoted"
+)
+
+// ReviewQueue is a local queue of unclassified cookies collected by scans.
+type ReviewQueue struct {
+	db *sql.DB
+}
+
+// UnknownCookie is a cookie which has no classifier definition yet.
+type UnknownCookie struct {
+	Name          string                 `json:"name"`
+	Domain        string                 `json:"domain"`
+	FirstSeenSite string                 `json:"first_seen_site"`
+	Initiator     string                 `json:"initiator"`
+	Lifetime      string                 `json:"lifetime"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	FirstSeen     time.Time              `json:"first_seen"`
+	LastSeen      time.Time              `json:"last_seen"`
+	SeenCount     int                    `json:"seen_count"`
+	Status        string                 `json:"status"`
+	Category      string                 `json:"category"`
+	Description   string                 `json:"description"`
+}
+
+func NewReviewQueue(path string) (q *ReviewQueue, err error) {
+	q = &ReviewQueue{}
+
+	if q.db, err = sql.Open("sqlite3", "file:"+path); err != nil {
+		err = errors.Wrap(err, "open review queue failed")
+		return
+	}
+
+	_, err = q.db.Exec(`CREATE TABLE IF NOT EXISTS unknown_cookies (
+	cookie_name TEXT NOT NULL,
+	cookie_domain TEXT NOT NULL,
+	first_seen_site TEXT NOT NULL,
+	initiator TEXT NOT NULL DEFAULT '',
+	lifetime TEXT NOT NULL DEFAULT '',
+	attributes TEXT NOT NULL DEFAULT '{}',
+	first_seen INTEGER NOT NULL,
+	last_seen INTEGER NOT NULL,
+	seen_count INTEGER NOT NULL DEFAULT 1,
+	status TEXT NOT NULL DEFAULT 'pending',
+	cookie_type TEXT NOT NULL DEFAULT '',
+	cookie_desc TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (cookie_name, cookie_domain)
+)`)
+	err = errors.Wrap(err, "init review queue failed")
+
+	return
+}
+
+// Record adds the cookie to queue or refreshes the last seen time of a queued one.
+func (q *ReviewQueue) Record(c *UnknownCookie) (err error) {
+	attrs, err := json.Marshal(c.Attributes)
+	if err != nil {
+		err = errors.Wrap(err, "encode cookie attributes failed")
+		return
+	}
+
+	now := time.Now().Unix()
+
+	// single statement, concurrent scans recording the same cookie do not conflict
+	_, err = q.db.Exec(`INSERT INTO unknown_cookies
+(cookie_name, cookie_domain, first_seen_site, initiator, lifetime, attributes, first_seen, last_seen)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (cookie_name, cookie_domain) DO UPDATE SET last_seen = excluded.last_seen, seen_count = seen_count + 1`,
+		c.Name, c.Domain, c.FirstSeenSite, c.Initiator, c.Lifetime, string(attrs), now, now)
+	err = errors.Wrap(err, "record review queue failed")
+
+	return
+}
+
+// List returns the queued cookies with the status, empty status returns all of them.
+func (q *ReviewQueue) List(status string) (cookies []*UnknownCookie, err error) {
+	query := `SELECT cookie_name, cookie_domain, first_seen_site, initiator, lifetime, attributes,
+first_seen, last_seen, seen_count, status, cookie_type, cookie_desc FROM unknown_cookies`
+	var args []interface{}
+
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+
+	query += " ORDER BY seen_count DESC, cookie_name"
+
+	rows, err := q.db.Query(query, args...)
+	if err != nil {
+		err = errors.Wrap(err, "query review queue failed")
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var (
+			c                   = new(UnknownCookie)
+			attrs               string
+			firstSeen, lastSeen int64
+		)
+
+		if err = rows.Scan(&c.Name, &c.Domain, &c.FirstSeenSite, &c.Initiator, &c.Lifetime, &attrs,
+			&firstSeen, &lastSeen, &c.SeenCount, &c.Status, &c.Category, &c.Description); err != nil {
+			err = errors.Wrap(err, "scan review queue failed")
+			return
+		}
+
+		_ = json.Unmarshal([]byte(attrs), &c.Attributes)
+		c.FirstSeen = time.Unix(firstSeen, 0).UTC()
+		c.LastSeen = time.Unix(lastSeen, 0).UTC()
+		cookies = append(cookies, c)
+	}
+
+	err = errors.Wrap(rows.Err(), "iterate review queue failed")
+
+	return
+}
+
+// Assign sets the classification of all queued cookies with the name.
+func (q *ReviewQueue) Assign(name string, category string, desc string) (err error) {
+	if category, err = NormalizeCategory(category); err != nil {
+		return
+	}
+
+	res, err := q.db.Exec(`UPDATE unknown_cookies SET status = ?, cookie_type = ?, cookie_desc = ?
+WHERE cookie_name = ? AND status != ?`, ReviewStatusAssigned, category, desc, name, ReviewStatusPromoted)
+	if err != nil {
+		err = errors.Wrap(err, "assign review queue cookie failed")
+		return
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		err = errors.Errorf("cookie %s not found in review queue", name)
+	}
+
+	return
+}
+
+// Promote adds the assigned classification of the cookie to classifier database, an existing definition is updated.
+func (q *ReviewQueue) Promote(name string, c *Classifier, operator string) (err error) {
+	var category, desc string
+
+	err = q.db.QueryRow(`SELECT cookie_type, cookie_desc FROM unknown_cookies
+WHERE cookie_name = ? AND status = ? LIMIT 1`, name, ReviewStatusAssigned).Scan(&category, &desc)
+	if err == sql.ErrNoRows {
+		err = errors.Errorf("cookie %s has no assigned classification", name)
+		return
+	} else if err != nil {
+		err = errors.Wrap(err, "query review queue failed")
+		return
+	}
+
+	def := &CookieDefinition{
+		Name:        name,
+		Category:    category,
+		Description: desc,
+	}
+
+	existing, err := c.GetCookie(name)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case existing == nil:
+		err = c.AddCookie(def, operator)
+	case existing.Category != category || existing.Description != desc:
+		// cookie was added to classifier after it was queued
+		err = c.UpdateCookie(def, operator)
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = q.db.Exec("UPDATE unknown_cookies SET status = ? WHERE cookie_name = ?", ReviewStatusPromoted, name)
+	err = errors.Wrap(err, "update review queue failed")
+
+	return
+}
+
+func (t *Task) recordUnknownCookies(site string, records []*reportRecord) {
+	if t.cfg.ReviewQueue == nil {
+		return
+	}
+
+	for _, record := range records {
+		if record.Category != "" && !record.Inferred {
+			continue
+		}
+
+		for _, c := range record.Cookies {
+			if err := t.cfg.ReviewQueue.Record(&UnknownCookie{
+				Name:          c.Name,
+				Domain:        c.Domain,
+				FirstSeenSite: site,
+				Initiator:     c.Source,
+				Lifetime:      c.Expiry,
+				Attributes: map[string]interface{}{
+					"path":             c.Path,
+					"expires":          c.Expires,
+					"maxAge":           c.MaxAge,
+					"secure":           c.Secure,
+					"httpOnly":         c.HttpOnly,
+					"url":              c.URL,
+					"mimeType":         c.MimeType,
+					"type":             c.Initiator,
+					"inferredCategory": c.Category,
+					"confidence":       c.Confidence,
+				},
+			}); err != nil {
+				logrus.WithField("cookie", c.Name).WithError(err).Warning("record unknown cookie failed")
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReviewQueueRecordConcurrent(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	q, err := NewReviewQueue(filepath.Join(dir, "review.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const scans = 8
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, scans)
+	)
+	for i := 0; i < scans; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- q.Record(&UnknownCookie{
+				Name:          "_hjid",
+				Domain:        ".example.com",
+				FirstSeenSite: fmt.Sprintf("https://site%d.example.com/", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cookies, err := q.List(ReviewStatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].SeenCount != scans {
+		t.Fatalf("queued cookies = %+v, want one seen %d times", cookies, scans)
+	}
+}
+
+func TestReviewQueuePromoteExisting(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	c := newTestClassifier(t, dir)
+	q, err := NewReviewQueue(filepath.Join(dir, "review.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"_hjid", "_fbp", "_gid"} {
+		if err = q.Record(&UnknownCookie{Name: name, Domain: ".example.com", FirstSeenSite: "https://example.com/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// classified after being queued, with a different and an identical definition
+	if err = c.AddCookie(&CookieDefinition{Name: "_fbp", Category: "Performance"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.AddCookie(&CookieDefinition{Name: "_gid", Category: "Performance", Description: "GA"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []struct{ name, category, desc string }{
+		{"_hjid", "Performance", "Hotjar user id"},
+		{"_fbp", "Targeting/Advertising", "Facebook browser id"},
+		{"_gid", "Performance", "GA"},
+	} {
+		if err = q.Assign(a.name, a.category, a.desc); err != nil {
+			t.Fatal(err)
+		}
+		if err = q.Promote(a.name, c, "alice"); err != nil {
+			t.Fatalf("promote %s failed: %v", a.name, err)
+		}
+
+		def, err := c.GetCookie(a.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if def == nil || def.Category != a.category || def.Description != a.desc {
+			t.Errorf("cookie %s = %+v, want %s %q", a.name, def, a.category, a.desc)
+		}
+	}
+
+	if pending, err := q.List(ReviewStatusAssigned); err != nil || len(pending) != 0 {
+		t.Errorf("assigned cookies after promotion = %v %v, want none", pending, err)
+	}
+
+	// identical definition is not changed again
+	if history, err := c.History("_gid"); err != nil || len(history) != 1 {
+		t.Errorf("_gid history = %d records %v, want 1", len(history), err)
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ScreenshotPage is the screenshot of the viewport or the full page.
+	ScreenshotPage = "page"
+	// ScreenshotBanner is the element screenshot of the detected consent banner.
+	ScreenshotBanner = "banner"
+
+	ScreenshotPNG  = "png"
+	ScreenshotJPEG = "jpeg"
+	ScreenshotWebP = "webp"
+
+	// chrome could not capture surfaces higher than its max texture size
+	maxFullPageHeight = 16384
+)
+
+// ScreenshotFormats returns the supported screenshot image formats.
+func ScreenshotFormats() []string {
+	return []string{ScreenshotPNG, ScreenshotJPEG, ScreenshotWebP}
+}
+
+// ScreenshotOptions contains the screenshot capture options, nil options take an inlined viewport png.
+type ScreenshotOptions struct {
+	FullPage bool
+	Banner   bool
+	Format   string
+	// Quality of jpeg and webp screenshots from 0 to 100, zero uses chrome default.
+	Quality int
+	// Dir writes screenshots as files referenced from the report.
+	Dir string
+	// Inline embeds screenshots in the report as base64.
+	Inline bool
+}
+
+type reportScreenshot struct {
+	Type     string
+	MimeType string
+	Width    int    `json:",omitempty"`
+	Height   int    `json:",omitempty"`
+	File     string `json:",omitempty"`
+	Image    string `json:",omitempty"`
+}
+
+// bannerScript returns the bounding box of the consent banner in page coordinates, null if no banner is found.
+const bannerScript = `(function () {
+  var selectors = [
+    "#onetrust-banner-sdk", "#onetrust-consent-sdk", "#CybotCookiebotDialog", "#usercentrics-root",
+    "#didomi-notice", "#didomi-host", ".qc-cmp2-container", "#qc-cmp2-ui", "#truste-consent-track",
+    "#cmpbox", "#cookie-law-info-bar", ".cc-window", ".cookie-notice-container", "#cookie-notice",
+    "#klaro .cookie-notice", "#klaro .cookie-modal", "#sp_message_container", ".fc-consent-root",
+    "#gdpr-cookie-message", "#cookieConsent", "#cookie-banner", ".cookie-banner", "#cookiebanner"
+  ];
+  var visible = function (e) {
+    var r = e.getBoundingClientRect(), s = getComputedStyle(e);
+    return r.width > 0 && r.height > 0 && s.visibility !== "hidden" && s.display !== "none" && s.opacity !== "0";
+  };
+  var found = null;
+  for (var i = 0; i < selectors.length && !found; i++) {
+    var e = document.querySelector(selectors[i]);
+    if (e && visible(e)) found = e;
+  }
+  if (!found) {
+    var pattern = /cookie|consent|gdpr|privacy|datenschutz|rgpd/i, best = 0;
+    var all = document.querySelectorAll("div, section, aside, dialog, footer, form");
+    for (var j = 0; j < all.length; j++) {
+      var c = all[j], pos = getComputedStyle(c).position;
+      if ((pos !== "fixed" && pos !== "sticky") || !visible(c) || !pattern.test(c.innerText || "")) continue;
+      var r = c.getBoundingClientRect(), area = r.width * r.height;
+      if (area > best && area < innerWidth * innerHeight) {
+        best = area;
+        found = c;
+      }
+    }
+  }
+  if (!found) return null;
+  var box = found.getBoundingClientRect();
+  return {x: box.left + scrollX, y: box.top + scrollY, width: box.width, height: box.height};
+})()`
+
+func (o *ScreenshotOptions) format() string {
+	if o == nil || o.Format == "" {
+		return ScreenshotPNG
+	}
+	return o.Format
+}
+
+// Validate checks the image format and quality options.
+func (o *ScreenshotOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	switch o.format() {
+	case ScreenshotPNG, ScreenshotJPEG, ScreenshotWebP:
+	default:
+		return errors.Errorf("unsupported screenshot format: %s", o.Format)
+	}
+
+	if o.Quality < 0 || o.Quality > 100 {
+		return errors.Errorf("invalid screenshot quality: %d, should be between 0 and 100", o.Quality)
+	}
+
+	return nil
+}
+
+// captureBanner detects the consent banner and captures its element screenshot, nil image if no banner is found.
+func captureBanner(driver Driver, format string, quality int) (image []byte, width int, height int, err error) {
+	res, err := driver.Evaluate(bannerScript)
+	if err != nil || res == nil {
+		return
+	}
+
+	box, ok := res.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	w, _ := box["width"].(float64)
+	h, _ := box["height"].(float64)
+	if width, height = int(w+0.5), int(h+0.5); width <= 0 || height <= 0 {
+		return
+	}
+
+	image, err = driver.Screenshot(format, quality, map[string]interface{}{
+		"x":      box["x"],
+		"y":      box["y"],
+		"width":  w,
+		"height": h,
+	})
+	return
+}
+
+// takeScreenshots captures the consent banner and the page, banner is captured first as the full page capture
+// changes the viewport.
+func (t *Task) takeScreenshots(site string) (screenshots []*reportScreenshot) {
+	o := t.cfg.Screenshot
+	if o == nil {
+		o = &ScreenshotOptions{Inline: true}
+	}
+
+	format := o.format()
+
+	add := func(kind string, image []byte, width int, height int) {
+		s := &reportScreenshot{
+			Type:     kind,
+			MimeType: "image/" + format,
+			Width:    width,
+			Height:   height,
+		}
+
+		if o.Dir != "" {
+			if file, err := writeScreenshot(o.Dir, site, t.startTime.Unix(), kind, format, image); err != nil {
+				logrus.WithError(err).WithField("type", kind).Warning("write screenshot failed")
+			} else {
+				s.File = file
+			}
+		}
+		if o.Inline || s.File == "" {
+			s.Image = base64.StdEncoding.EncodeToString(image)
+		}
+
+		screenshots = append(screenshots, s)
+	}
+
+	if o.Banner {
+		image, width, height, err := captureBanner(t.driver, format, o.Quality)
+		if err != nil {
+			logrus.WithError(err).Warning("capture consent banner failed")
+		} else if image != nil {
+			add(ScreenshotBanner, image, width, height)
+		}
+	}
+
+	var (
+		image         []byte
+		width, height int
+		err           error
+	)
+	if o.FullPage {
+		if image, width, height, err = t.driver.FullPageScreenshot(format, o.Quality); err != nil {
+			logrus.WithError(err).Warning("capture full page failed, fallback to viewport")
+		}
+	}
+	if image == nil {
+		if image, err = t.driver.Screenshot(format, o.Quality, nil); err == nil {
+			width, height = imageSize(image)
+		}
+	}
+	if err == nil {
+		add(ScreenshotPage, image, width, height)
+	}
+
+	return
+}
+
+// imageSize returns the dimensions of png and jpeg images, zero for other formats.
+func imageSize(data []byte) (width int, height int) {
+	if c, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		width, height = c.Width, c.Height
+	}
+	return
+}
+
+func writeScreenshot(dir string, site string, ts int64, kind string, format string, image []byte) (file string, err error) {
+	host := "site"
+	if u, _ := url.Parse(site); u != nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	ext := format
+	if ext == ScreenshotJPEG {
+		ext = "jpg"
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	file = filepath.Join(dir, fmt.Sprintf("%s-%d-%s.%s", host, ts, kind, ext))
+	err = ioutil.WriteFile(file, image, 0644)
+	return
+}
+
+// Screenshot returns the screenshot of type kind.
+func (d *reportData) Screenshot(kind string) *reportScreenshot {
+	for _, s := range d.Screenshots {
+		if s.Type == kind {
+			return s
+		}
+	}
+	return nil
+}
+
+// Src returns the image data uri or the file reference.
+func (s *reportScreenshot) Src() template.URL {
+	if s.Image != "" {
+		return template.URL("data:" + s.MimeType + ";base64," + s.Image)
+	}
+	return template.URL((&url.URL{Path: filepath.ToSlash(s.File)}).String())
+}
+
+// inlineScreenshots returns a copy of report data with screenshot files embedded, used by pdf and email outputs
+// which could not refer local files.
+func inlineScreenshots(data *reportData) *reportData {
+	inlined := *data
+	inlined.Screenshots = nil
+
+	for _, s := range data.Screenshots {
+		if s.Image == "" && s.File != "" {
+			image, err := ioutil.ReadFile(s.File)
+			if err != nil {
+				logrus.WithError(err).WithField("file", s.File).Warning("read screenshot failed")
+				continue
+			}
+
+			c := *s
+			c.Image = base64.StdEncoding.EncodeToString(image)
+			s = &c
+		}
+
+		inlined.Screenshots = append(inlined.Screenshots, s)
+	}
+
+	return &inlined
+}
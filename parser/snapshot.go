@@ -0,0 +1,216 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ClassifierVersionLive is the version of a classifier database which is not a snapshot.
+	ClassifierVersionLive = "live"
+
+	// snapshotCloseDelay keeps a replaced snapshot open for scans still using it.
+	snapshotCloseDelay = 10 * time.Minute
+)
+
+// ClassifierSnapshot is a local snapshot file, the snapshot is reopened when sync replaces the file.
+type ClassifierSnapshot struct {
+	path string
+	l    sync.Mutex
+	info os.FileInfo
+	c    *Classifier
+}
+
+// NewClassifierSnapshot watches the snapshot file, the file does not need to exist yet.
+func NewClassifierSnapshot(path string) *ClassifierSnapshot {
+	return &ClassifierSnapshot{path: path}
+}
+
+// Classifier returns the classifier of current snapshot file, nil if the snapshot is not synced yet.
+func (s *ClassifierSnapshot) Classifier() *Classifier {
+	if s == nil {
+		return nil
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return s.c
+	}
+	if s.info != nil && os.SameFile(info, s.info) && info.ModTime().Equal(s.info.ModTime()) &&
+		info.Size() == s.info.Size() {
+		return s.c
+	}
+
+	c, err := NewClassifier("sqlite://" + s.path)
+	if err != nil {
+		logrus.WithError(err).WithField("snapshot", s.path).Warning("open classifier snapshot failed")
+		return s.c
+	}
+
+	if old := s.c; old != nil {
+		time.AfterFunc(snapshotCloseDelay, func() {
+			_ = old.Close()
+		})
+	}
+
+	s.c, s.info = c, info
+	logrus.WithFields(logrus.Fields{"snapshot": s.path, "version": c.Version()}).Info("classifier snapshot loaded")
+
+	return s.c
+}
+
+// Version returns the snapshot version stamp of classifier database, or ClassifierVersionLive.
+func (c *Classifier) Version() string {
+	c.versionOnce.Do(func() {
+		// snapshot meta table only exists in snapshot database
+		if c.db.QueryRow("SELECT value FROM snapshot_meta WHERE key = 'version'").Scan(&c.version) != nil ||
+			c.version == "" {
+			c.version = ClassifierVersionLive
+		}
+	})
+
+	return c.version
+}
+
+// SyncSnapshot copies all classifier definitions to a local SQLite snapshot file with version stamp,
+// the snapshot file is replaced atomically.
+func (c *Classifier) SyncSnapshot(path string, source string) (version string, err error) {
+	defs, err := c.ListCookies()
+	if err != nil {
+		return
+	}
+
+	content, err := json.Marshal(defs)
+	if err != nil {
+		err = errors.Wrap(err, "encode classifier definitions failed")
+		return
+	}
+
+	hash := sha256.Sum256(content)
+	syncTime := time.Now().UTC()
+	version = syncTime.Format("20060102150405") + "-" + hex.EncodeToString(hash[:])[:12]
+
+	// strip credentials and config from source dsn
+	if u, err := url.Parse(source); err == nil {
+		u.RawQuery = ""
+		u.User = nil
+		source = u.String()
+	}
+
+	tempPath := path + ".tmp"
+	_ = os.Remove(tempPath)
+
+	if err = writeSnapshot(tempPath, defs, map[string]string{
+		"version":   version,
+		"source":    source,
+		"synced_at": syncTime.Format(time.RFC3339),
+		"count":     strconv.Itoa(len(defs)),
+	}); err != nil {
+		_ = os.Remove(tempPath)
+		return
+	}
+
+	if err = os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		err = errors.Wrap(err, "replace classifier snapshot failed")
+	}
+
+	return
+}
+
+func writeSnapshot(path string, defs []*CookieDefinition, meta map[string]string) (err error) {
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		err = errors.Wrap(err, "open classifier snapshot failed")
+		return
+	}
+
+	defer func() {
+		_ = db.Close()
+	}()
+
+	for _, q := range []string{
+		"CREATE TABLE cookies (cookie_name TEXT NOT NULL, cookie_type TEXT NOT NULL, cookie_desc TEXT NOT NULL)",
+		"CREATE INDEX cookies_name ON cookies (cookie_name)",
+		`CREATE TABLE cookie_translations (
+	cookie_name TEXT NOT NULL,
+	lang TEXT NOT NULL,
+	cookie_desc TEXT NOT NULL,
+	PRIMARY KEY (cookie_name, lang)
+)`,
+		"CREATE TABLE snapshot_meta (key TEXT PRIMARY KEY, value TEXT NOT NULL)",
+	} {
+		if _, err = db.Exec(q); err != nil {
+			err = errors.Wrap(err, "create classifier snapshot schema failed")
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		err = errors.Wrap(err, "begin classifier snapshot transaction failed")
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, def := range defs {
+		if _, err = tx.Exec("INSERT INTO cookies (cookie_name, cookie_type, cookie_desc) VALUES (?, ?, ?)",
+			def.Name, def.Category, def.Description); err != nil {
+			err = errors.Wrapf(err, "write cookie %s to snapshot failed", def.Name)
+			return
+		}
+
+		for lang, desc := range def.Translations {
+			if _, err = tx.Exec("INSERT OR REPLACE INTO cookie_translations (cookie_name, lang, cookie_desc) VALUES (?, ?, ?)",
+				def.Name, lang, desc); err != nil {
+				err = errors.Wrapf(err, "write cookie %s translation to snapshot failed", def.Name)
+				return
+			}
+		}
+	}
+
+	for k, v := range meta {
+		if _, err = tx.Exec("INSERT INTO snapshot_meta (key, value) VALUES (?, ?)", k, v); err != nil {
+			err = errors.Wrap(err, "write snapshot meta failed")
+			return
+		}
+	}
+
+	err = errors.Wrap(tx.Commit(), "commit classifier snapshot failed")
+
+	return
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifierSnapshotReload(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	c := newTestClassifier(t, dir)
+	path := filepath.Join(dir, "snapshot.db")
+
+	s := NewClassifierSnapshot(path)
+	if s.Classifier() != nil {
+		t.Fatal("classifier of missing snapshot")
+	}
+
+	if err := c.AddCookie(&CookieDefinition{Name: "_ga", Category: "Performance"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := c.SyncSnapshot(path, "sqlite://cookies.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := s.Classifier()
+	if first == nil || first.Version() != v1 {
+		t.Fatalf("snapshot not loaded after sync")
+	}
+	if s.Classifier() != first {
+		t.Error("unchanged snapshot reopened")
+	}
+
+	if err = c.AddCookie(&CookieDefinition{Name: "_gid", Category: "Performance"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	v2, err := c.SyncSnapshot(path, "sqlite://cookies.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := s.Classifier()
+	if second == first || second.Version() != v2 {
+		t.Fatalf("replaced snapshot not reloaded, version %s want %s", second.Version(), v2)
+	}
+	if def, err := second.GetCookie("_gid"); err != nil || def == nil {
+		t.Errorf("cookie of replaced snapshot not found: %v", err)
+	}
+}
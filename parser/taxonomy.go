@@ -0,0 +1,158 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CategoryStrictlyNecessary = "Strictly Necessary"
+	CategoryPerformance       = "Performance"
+	CategoryFunctionality     = "Functionality"
+	CategoryTargeting         = "Targeting/Advertising"
+
+	CategoryIDUnclassified = "unclassified"
+)
+
+const (
+	CNILExempt               = "exempt"
+	CNILExemptUnderCondition = "exempt-under-conditions"
+	CNILConsentRequired      = "consent-required"
+)
+
+// CategoryMapping maps a canonical category to the regulatory and industry frameworks.
+type CategoryMapping struct {
+	// ICCUK is the category of ICC UK Cookie guide.
+	ICCUK string
+	// CNIL is the consent exemption rule of CNIL guidelines.
+	CNIL string
+	// TCFPurposes are the IAB TCF v2 purpose ids.
+	TCFPurposes []int
+	// TCFSpecialPurposes are the IAB TCF v2 special purpose ids.
+	TCFSpecialPurposes []int
+}
+
+// Category is a canonical cookie category.
+type Category struct {
+	ID      string
+	Name    string
+	Aliases []string
+	Mapping CategoryMapping
+}
+
+// taxonomy defines the canonical cookie categories in report order.
+var taxonomy = []*Category{
+	{
+		ID:      "strictly-necessary",
+		Name:    CategoryStrictlyNecessary,
+		Aliases: []string{"necessary", "essential", "required", "technical", "security"},
+		Mapping: CategoryMapping{
+			ICCUK:              "Category 1: Strictly necessary",
+			CNIL:               CNILExempt,
+			TCFPurposes:        []int{1},
+			TCFSpecialPurposes: []int{1, 2},
+		},
+	},
+	{
+		ID:      "performance",
+		Name:    CategoryPerformance,
+		Aliases: []string{"analytics", "statistics", "statistic", "measurement", "audience measurement"},
+		Mapping: CategoryMapping{
+			ICCUK:       "Category 2: Performance",
+			CNIL:        CNILExemptUnderCondition,
+			TCFPurposes: []int{1, 8, 9, 10},
+		},
+	},
+	{
+		ID:      "functionality",
+		Name:    CategoryFunctionality,
+		Aliases: []string{"functional", "preferences", "preference", "personalization", "personalisation"},
+		Mapping: CategoryMapping{
+			ICCUK:       "Category 3: Functionality",
+			CNIL:        CNILExemptUnderCondition,
+			TCFPurposes: []int{1, 5, 6},
+		},
+	},
+	{
+		ID:      "targeting-advertising",
+		Name:    CategoryTargeting,
+		Aliases: []string{"targeting", "advertising", "advertisement", "marketing", "ads", "social media", "tracking"},
+		Mapping: CategoryMapping{
+			ICCUK:       "Category 4: Targeting or advertising",
+			CNIL:        CNILConsentRequired,
+			TCFPurposes: []int{1, 2, 3, 4, 7},
+		},
+	},
+}
+
+// unclassifiedCategory is used for cookies without category, requiring consent to be safe.
+var unclassifiedCategory = &Category{
+	ID: CategoryIDUnclassified,
+	Mapping: CategoryMapping{
+		CNIL: CNILConsentRequired,
+	},
+}
+
+// CookieCategories returns the canonical cookie category names in report order.
+func CookieCategories() (names []string) {
+	for _, c := range taxonomy {
+		names = append(names, c.Name)
+	}
+	return
+}
+
+// LookupCategory finds the canonical category by id, name or alias, returns nil if not mapped.
+func LookupCategory(category string) *Category {
+	category = strings.TrimSpace(category)
+
+	for _, c := range taxonomy {
+		if strings.EqualFold(c.ID, category) || strings.EqualFold(c.Name, category) {
+			return c
+		}
+		for _, alias := range c.Aliases {
+			if strings.EqualFold(alias, category) {
+				return c
+			}
+		}
+	}
+
+	return nil
+}
+
+// NormalizeCategory validates category against the taxonomy and returns the canonical name.
+func NormalizeCategory(category string) (string, error) {
+	if c := LookupCategory(category); c != nil {
+		return c.Name, nil
+	}
+
+	return "", errors.Errorf("invalid cookie category %q, should be one of: %s",
+		category, strings.Join(CookieCategories(), ", "))
+}
+
+// categoryRank returns the canonical order of category, unmapped categories are ranked last.
+func categoryRank(category string) int {
+	for i, c := range taxonomy {
+		if c.Name == category {
+			return i
+		}
+	}
+
+	return len(taxonomy)
+}
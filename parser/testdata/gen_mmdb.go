@@ -0,0 +1,232 @@
+//go:build ignore
+// +build ignore
+
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// gen_mmdb writes the MaxMind DB fixtures of geoip tests, run "go run gen_mmdb.go" in this directory.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+)
+
+type pointer uint
+
+type network struct {
+	cidr string
+	data []byte
+}
+
+type node struct {
+	index    int
+	children [2]*node
+	records  [2]int
+}
+
+func control(typ int, size int) (b []byte) {
+	sizeBits, extra := size, []byte(nil)
+	if size >= 29 {
+		if size >= 285 {
+			panic("size not supported")
+		}
+		sizeBits, extra = 29, []byte{byte(size - 29)}
+	}
+
+	if typ > 7 {
+		b = []byte{byte(sizeBits), byte(typ - 7)}
+	} else {
+		b = []byte{byte(typ<<5 | sizeBits)}
+	}
+
+	return append(b, extra...)
+}
+
+func encode(v interface{}) []byte {
+	buf := new(bytes.Buffer)
+
+	switch v := v.(type) {
+	case string:
+		buf.Write(control(2, len(v)))
+		buf.WriteString(v)
+	case uint16:
+		buf.Write(control(5, 2))
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		buf.Write(control(6, 4))
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case uint64:
+		buf.Write(control(9, 8))
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case pointer:
+		if v >= 2048 {
+			panic("pointer not supported")
+		}
+		buf.Write([]byte{byte(1<<5 | v>>8), byte(v)})
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.Write(control(7, len(v)))
+		for _, k := range keys {
+			buf.Write(encode(k))
+			buf.Write(encode(v[k]))
+		}
+	default:
+		panic(fmt.Sprintf("type %T not supported", v))
+	}
+
+	return buf.Bytes()
+}
+
+func country(code string, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"iso_code": code,
+		"names":    map[string]interface{}{"en": name},
+	}
+}
+
+func build(ipVersion int, recordSize int) []byte {
+	// continents are shared by pointers like in GeoLite2 databases
+	data := new(bytes.Buffer)
+	eu := pointer(data.Len())
+	data.Write(encode(map[string]interface{}{"code": "EU"}))
+	na := pointer(data.Len())
+	data.Write(encode(map[string]interface{}{"code": "NA"}))
+
+	networks := []network{
+		{"81.2.69.0/24", encode(map[string]interface{}{
+			"country":                        country("GB", "United Kingdom"),
+			"continent":                      eu,
+			"autonomous_system_number":       uint32(64500),
+			"autonomous_system_organization": "Example Networks",
+		})},
+		{"203.0.113.0/24", encode(map[string]interface{}{
+			"registered_country": country("US", "United States"),
+			"continent":          na,
+		})},
+	}
+	if ipVersion == 6 {
+		networks = append(networks, network{"2001:db8::/32", encode(map[string]interface{}{
+			"country":   country("DE", "Germany"),
+			"continent": eu,
+		})})
+	}
+
+	root := &node{}
+	nodes := []*node{root}
+
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		ip, mask := ipNet.IP, ipNet.Mask
+		ones, _ := mask.Size()
+		if ipVersion == 6 {
+			if ip4 := ip.To4(); ip4 != nil {
+				// ipv4 networks are stored in ::/96
+				ip, ones = append(make(net.IP, 12), ip4...), ones+96
+			}
+		}
+
+		offset := data.Len()
+		data.Write(n.data)
+
+		cur := root
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				cur.records[bit] = offset + 1
+				break
+			}
+			if cur.children[bit] == nil {
+				cur.children[bit] = &node{index: len(nodes)}
+				nodes = append(nodes, cur.children[bit])
+			}
+			cur = cur.children[bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	record := func(n *node, bit int) uint32 {
+		switch {
+		case n.children[bit] != nil:
+			return uint32(n.children[bit].index)
+		case n.records[bit] > 0:
+			return uint32(nodeCount + 16 + n.records[bit] - 1)
+		default:
+			return uint32(nodeCount)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	for _, n := range nodes {
+		left, right := record(n, 0), record(n, 1)
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24)<<4 | byte(right>>24)&0x0f, byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			_ = binary.Write(out, binary.BigEndian, left)
+			_ = binary.Write(out, binary.BigEndian, right)
+		}
+	}
+
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	out.Write(encode(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1559347200),
+		"database_type":               "Test-GeoIP",
+		"ip_version":                  uint16(ipVersion),
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	}))
+
+	return out.Bytes()
+}
+
+func main() {
+	for _, f := range []struct {
+		name       string
+		ipVersion  int
+		recordSize int
+	}{
+		{"geoip-ipv6-24.mmdb", 6, 24},
+		{"geoip-ipv6-28.mmdb", 6, 28},
+		{"geoip-ipv6-32.mmdb", 6, 32},
+		{"geoip-ipv4-24.mmdb", 4, 24},
+	} {
+		if err := ioutil.WriteFile(f.name, build(f.ipVersion, f.recordSize), 0644); err != nil {
+			panic(err)
+		}
+	}
+}
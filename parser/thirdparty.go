@@ -0,0 +1,118 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// thirdPartyHost is a third-party host contacted by the page, each request transfers the visitor ip address.
+type thirdPartyHost struct {
+	Host              string
+	RegistrableDomain string
+	Vendor            string
+	Requests          int
+	ResourceTypes     []string
+	RemoteAddrs       []string
+	Locations         []*geoLocation
+	// FirstContact is the milliseconds after navigation start of the first request.
+	FirstContact float64
+	// PreConsent is set for requests sent before consent, scans never interact with consent banners.
+	PreConsent bool
+}
+
+func appendUnique(list []string, v string) []string {
+	if v == "" {
+		return list
+	}
+	for _, s := range list {
+		if s == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
+
+// collectThirdPartyRequests groups all third-party requests by host, including requests without cookies.
+func collectThirdPartyRequests(rc *recordCollector, site string) (hosts []*thirdPartyHost) {
+	var siteHost string
+	if u, err := url.Parse(site); err == nil {
+		siteHost = u.Hostname()
+	}
+
+	resp := rc.get()
+	navStart, _ := navigationStart(resp)
+	hostMap := map[string]*thirdPartyHost{}
+
+	for _, records := range resp {
+		for _, r := range records {
+			if !r.isRequest {
+				continue
+			}
+
+			req, _ := r.params["request"].(map[string]interface{})
+			reqURL, _ := req["url"].(string)
+			u, err := url.Parse(reqURL)
+			if err != nil || u.Hostname() == "" || !isThirdParty(siteHost, u.Hostname()) {
+				continue
+			}
+
+			host := strings.ToLower(u.Hostname())
+			offset := offsetMillis(navStart, r.reqSeq)
+
+			h := hostMap[host]
+			if h == nil {
+				h = &thirdPartyHost{
+					Host:              host,
+					RegistrableDomain: registrableDomain(host),
+					FirstContact:      offset,
+					PreConsent:        true,
+				}
+				if tracker := matchTracker(host); tracker != nil {
+					h.Vendor = tracker.Vendor
+				}
+				hostMap[host] = h
+				hosts = append(hosts, h)
+			}
+
+			h.Requests++
+			if offset < h.FirstContact {
+				h.FirstContact = offset
+			}
+
+			resourceType, _ := r.params["type"].(string)
+			h.ResourceTypes = appendUnique(h.ResourceTypes, resourceType)
+		}
+	}
+
+	addrs := serverAddrs(rc)
+	for _, h := range hosts {
+		sort.Strings(h.ResourceTypes)
+		h.RemoteAddrs = addrs[h.Host]
+	}
+
+	sort.SliceStable(hosts, func(i, j int) bool {
+		if hosts[i].FirstContact != hosts[j].FirstContact {
+			return hosts[i].FirstContact < hosts[j].FirstContact
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+
+	return
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+)
+
+const (
+	pageEventDOMContentLoaded = "Page.domContentEventFired"
+	pageEventLoad             = "Page.loadEventFired"
+)
+
+// categoryColors are the timeline bar colors of canonical categories.
+var categoryColors = map[string]string{
+	"strictly-necessary":    "#28a745",
+	"performance":           "#17a2b8",
+	"functionality":         "#ffc107",
+	"targeting-advertising": "#dc3545",
+	CategoryIDUnclassified:  "#6c757d",
+}
+
+// reportTimeline contains page milestones in milliseconds after navigation start, zero if not fired.
+type reportTimeline struct {
+	DOMContentLoaded float64
+	Load             float64
+}
+
+type timelineRow struct {
+	Cookie *reportCookieRecord
+	Style  template.CSS
+}
+
+type timelineMarker struct {
+	Label string
+	Time  float64
+	Style template.CSS
+}
+
+type timelineLegend struct {
+	Category string
+	Style    template.CSS
+}
+
+// timelineView is the waterfall of cookies rendered by report template.
+type timelineView struct {
+	Scale   float64
+	Rows    []*timelineRow
+	Markers []*timelineMarker
+	Legend  []*timelineLegend
+}
+
+// navigationStart returns the timestamp of the main document request which is the earliest one.
+func navigationStart(resp map[string][]*record) (navStart float64, ok bool) {
+	for _, records := range resp {
+		for _, r := range records {
+			if r.isRequest && (!ok || r.reqSeq < navStart) {
+				navStart, ok = r.reqSeq, true
+			}
+		}
+	}
+	return
+}
+
+// offsetMillis returns milliseconds between the monotonic debugger timestamps in seconds.
+func offsetMillis(start float64, ts float64) float64 {
+	if d := (ts - start) * 1000; d > 0 {
+		return d
+	}
+	return 0
+}
+
+func timelineColor(c *reportCookieRecord) string {
+	if color, ok := categoryColors[c.CanonicalCategory]; ok {
+		return color
+	}
+	return categoryColors[CategoryIDUnclassified]
+}
+
+// TimelineView returns the cookie waterfall ordered by first set time, nil if timings are not recorded.
+func (d *reportData) TimelineView() *timelineView {
+	if d.Timeline == nil {
+		return nil
+	}
+
+	v := &timelineView{
+		Scale: d.Timeline.Load,
+	}
+	if d.Timeline.DOMContentLoaded > v.Scale {
+		v.Scale = d.Timeline.DOMContentLoaded
+	}
+
+	var (
+		cookies    []*reportCookieRecord
+		categories = map[string]bool{}
+	)
+
+	for _, r := range d.Records {
+		for _, c := range r.Cookies {
+			if c.FirstSet < 0 {
+				continue
+			}
+			cookies = append(cookies, c)
+			categories[c.CanonicalCategory] = true
+			if c.FirstSet > v.Scale {
+				v.Scale = c.FirstSet
+			}
+		}
+	}
+
+	if len(cookies) == 0 {
+		return nil
+	}
+
+	// leave room for the bar of latest cookie
+	v.Scale = v.Scale*1.1 + 1
+
+	sort.SliceStable(cookies, func(i, j int) bool {
+		return cookies[i].FirstSet < cookies[j].FirstSet
+	})
+
+	left := func(t float64) float64 {
+		return t / v.Scale * 100
+	}
+
+	for _, c := range cookies {
+		style := fmt.Sprintf("left:%.2f%%;background-color:%s;", left(c.FirstSet), timelineColor(c))
+		if !c.ThirdParty {
+			style += "opacity:.45;"
+		}
+		if c.FirstSetEstimated {
+			style += "border:1px dashed #212529;"
+		}
+		v.Rows = append(v.Rows, &timelineRow{Cookie: c, Style: template.CSS(style)})
+	}
+
+	v.Markers = append(v.Markers, &timelineMarker{Label: "Navigation start", Style: "left:0"})
+	if d.Timeline.DOMContentLoaded > 0 {
+		v.Markers = append(v.Markers, &timelineMarker{
+			Label: "DOMContentLoaded",
+			Time:  d.Timeline.DOMContentLoaded,
+			Style: template.CSS(fmt.Sprintf("left:%.2f%%", left(d.Timeline.DOMContentLoaded))),
+		})
+	}
+	if d.Timeline.Load > 0 {
+		v.Markers = append(v.Markers, &timelineMarker{
+			Label: "Load",
+			Time:  d.Timeline.Load,
+			Style: template.CSS(fmt.Sprintf("left:%.2f%%", left(d.Timeline.Load))),
+		})
+	}
+
+	for _, category := range append(append([]*Category(nil), taxonomy...), unclassifiedCategory) {
+		if categories[category.ID] {
+			v.Legend = append(v.Legend, &timelineLegend{
+				Category: category.Name,
+				Style:    template.CSS("background-color:" + categoryColors[category.ID]),
+			})
+		}
+	}
+
+	return v
+}
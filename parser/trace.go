@@ -0,0 +1,441 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/raff/godet"
+)
+
+const (
+	// traceScanMethod is the first line of trace with scanned url and scan time.
+	traceScanMethod = "CookieScanner.scan"
+	// traceCookiesMethod is the last line of trace with the browser cookie jar after page load.
+	traceCookiesMethod = "CookieScanner.cookies"
+)
+
+// collectedEvents are the debugger events used by report.
+var collectedEvents = []string{
+	"Network.requestWillBeSent",
+	"Network.responseReceived",
+	"Network.loadingFinished",
+	"Network.loadingFailed",
+	pageEventDOMContentLoaded,
+	pageEventLoad,
+}
+
+// tracedEvents are recorded to trace in addition to the collected events, debugger only delivers
+// events with registered callback.
+var tracedEvents = []string{
+	"Network.requestWillBeSentExtraInfo",
+	"Network.responseReceivedExtraInfo",
+	"Network.requestServedFromCache",
+	"Network.webSocketCreated",
+	"Page.frameAttached",
+	"Page.frameNavigated",
+	"Page.frameStartedLoading",
+	"Page.frameStoppedLoading",
+	"Page.lifecycleEvent",
+	"Runtime.consoleAPICalled",
+	"Runtime.exceptionThrown",
+	"Log.entryAdded",
+}
+
+type traceEvent struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type traceScan struct {
+	URL       string    `json:"url"`
+	StartTime time.Time `json:"startTime"`
+}
+
+type traceCookies struct {
+	Cookies []godet.Cookie `json:"cookies"`
+}
+
+// traceWriter writes debugger events as ndjson, one {"method", "params"} object per line.
+type traceWriter struct {
+	l   sync.Mutex
+	w   io.Writer
+	err error
+}
+
+func newTraceWriter(w io.Writer) *traceWriter {
+	if w == nil {
+		return nil
+	}
+	return &traceWriter{w: w}
+}
+
+func (tw *traceWriter) write(method string, params interface{}) {
+	if tw == nil {
+		return
+	}
+
+	tw.l.Lock()
+	defer tw.l.Unlock()
+
+	if tw.err != nil {
+		return
+	}
+
+	var line []byte
+	if line, tw.err = json.Marshal(map[string]interface{}{"method": method, "params": params}); tw.err != nil {
+		return
+	}
+	_, tw.err = tw.w.Write(append(line, '\n'))
+}
+
+func (tw *traceWriter) event(method string, params godet.Params) {
+	tw.write(method, params)
+}
+
+func (tw *traceWriter) scan(site string, startTime time.Time) {
+	tw.write(traceScanMethod, &traceScan{URL: site, StartTime: startTime})
+}
+
+func (tw *traceWriter) cookies(cookies []godet.Cookie) {
+	tw.write(traceCookiesMethod, &traceCookies{Cookies: cookies})
+}
+
+// Err returns the first write error of trace.
+func (tw *traceWriter) Err() error {
+	if tw == nil {
+		return nil
+	}
+
+	tw.l.Lock()
+	defer tw.l.Unlock()
+
+	return tw.err
+}
+
+// ParseRecording builds the report from a trace recorded by scan or a har file exported by any browser,
+// the recording is analyzed by the same parser as live scans.
+func (t *Task) ParseRecording(data []byte) (err error) {
+	var har harFile
+	if json.Unmarshal(data, &har) == nil && har.Log != nil {
+		return t.ImportHAR(&har)
+	}
+
+	return t.Replay(bytes.NewReader(data))
+}
+
+// Replay builds the report from a ndjson trace recorded by scan.
+func (t *Task) Replay(r io.Reader) (err error) {
+	var (
+		rc   = newRecordCollector()
+		site string
+		dec  = json.NewDecoder(r)
+	)
+
+	for line := 1; ; line++ {
+		var ev traceEvent
+		if err = dec.Decode(&ev); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = errors.Wrapf(err, "decode trace event %d failed", line)
+			return
+		}
+
+		switch ev.Method {
+		case traceScanMethod:
+			var scan traceScan
+			if err = json.Unmarshal(ev.Params, &scan); err != nil {
+				err = errors.Wrap(err, "decode trace scan failed")
+				return
+			}
+			site, t.startTime = scan.URL, scan.StartTime.UTC()
+		case traceCookiesMethod:
+			var jar traceCookies
+			if err = json.Unmarshal(ev.Params, &jar); err != nil {
+				err = errors.Wrap(err, "decode trace cookies failed")
+				return
+			}
+			rc.setCookies(jar.Cookies)
+		default:
+			var params godet.Params
+			if err = json.Unmarshal(ev.Params, &params); err != nil {
+				err = errors.Wrapf(err, "decode trace event %d failed", line)
+				return
+			}
+			rc.handleEvent(ev.Method, params)
+		}
+	}
+
+	if site == "" {
+		err = errors.New("trace does not contain scanned url")
+		return
+	}
+
+	return t.analyze(rc, site)
+}
+
+// harResourceTypes maps the lowercase resource types of browser har exports to debugger types.
+var harResourceTypes = map[string]string{
+	"xhr":                "XHR",
+	"websocket":          "WebSocket",
+	"texttrack":          "TextTrack",
+	"eventsource":        "EventSource",
+	"signedexchange":     "SignedExchange",
+	"cspviolationreport": "CSPViolationReport",
+}
+
+func harResourceType(t string) string {
+	if t == "" {
+		return "Other"
+	}
+	if v, ok := harResourceTypes[strings.ToLower(t)]; ok {
+		return v
+	}
+	return strings.ToUpper(t[:1]) + strings.ToLower(t[1:])
+}
+
+// harHeaderMap merges headers to debugger format, repeated headers are joined by newline.
+func harHeaderMap(headers []*harNameValue) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, h := range headers {
+		if h == nil || strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		if v, ok := m[h.Name].(string); ok {
+			m[h.Name] = v + "\n" + h.Value
+		} else {
+			m[h.Name] = h.Value
+		}
+	}
+	return m
+}
+
+func harProtocol(httpVersion string) string {
+	switch v := strings.ToLower(httpVersion); v {
+	case "http/2.0", "http/2", "h2":
+		return "h2"
+	case "http/3", "http/3.0", "h3":
+		return "h3"
+	default:
+		return v
+	}
+}
+
+// ImportHAR builds the report from a har file, har does not contain the cookie jar so cookies sent by requests
+// without being set by any response are reported as script cookies.
+func (t *Task) ImportHAR(har *harFile) (err error) {
+	if har == nil || har.Log == nil {
+		err = errors.New("har does not contain any entry")
+		return
+	}
+
+	var entries []*harEntry
+	for _, e := range har.Log.Entries {
+		if e != nil && e.Request != nil {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		err = errors.New("har does not contain any request")
+		return
+	}
+
+	base := entries[0].StartedDateTime
+	for _, e := range entries {
+		if e.StartedDateTime.Before(base) {
+			base = e.StartedDateTime
+		}
+	}
+
+	// debugger timestamps are seconds since first request
+	ts := func(tm time.Time) float64 {
+		return tm.Sub(base).Seconds()
+	}
+
+	t.startTime = base.UTC()
+	rc := newRecordCollector()
+
+	var (
+		site     string
+		jar      []godet.Cookie
+		jarNames = map[string]bool{}
+	)
+
+	for i, e := range entries {
+		reqID := "har." + strconv.Itoa(i)
+		start := ts(e.StartedDateTime)
+		resourceType := harResourceType(e.ResourceType)
+		reqHeaders := harHeaderMap(e.Request.Headers)
+
+		if site == "" && resourceType == "Document" {
+			site = e.Request.URL
+		}
+
+		// some browsers omit the cookie header of requests
+		if harHeader(reqHeaders, "Cookie") == "" && len(e.Request.Cookies) > 0 {
+			pairs := make([]string, 0, len(e.Request.Cookies))
+			for _, c := range e.Request.Cookies {
+				if c != nil {
+					pairs = append(pairs, c.Name+"="+c.Value)
+				}
+			}
+			reqHeaders["Cookie"] = strings.Join(pairs, "; ")
+		}
+
+		request := map[string]interface{}{
+			"url":     e.Request.URL,
+			"method":  e.Request.Method,
+			"headers": reqHeaders,
+		}
+		if e.Request.PostData != nil {
+			request["postData"] = e.Request.PostData.Text
+		}
+
+		initiator := map[string]interface{}{"type": "other"}
+		if e.Initiator != nil && e.Initiator.Type != "" {
+			initiator = map[string]interface{}{
+				"type":       e.Initiator.Type,
+				"url":        e.Initiator.URL,
+				"lineNumber": float64(e.Initiator.LineNumber),
+			}
+		}
+
+		rc.handleEvent("Network.requestWillBeSent", godet.Params{
+			"requestId": reqID,
+			"timestamp": start,
+			"wallTime":  float64(e.StartedDateTime.UnixNano()) / 1e9,
+			"type":      resourceType,
+			"request":   request,
+			"initiator": initiator,
+		})
+
+		end := start + e.Time/1000
+
+		if e.Response == nil || e.Response.Status == 0 {
+			rc.handleEvent("Network.loadingFailed", godet.Params{
+				"requestId": reqID,
+				"timestamp": end,
+				"errorText": e.Error,
+			})
+			continue
+		}
+
+		// response headers are received before the body
+		respTs := start
+		if e.Timings != nil {
+			for _, v := range []float64{e.Timings.Blocked, e.Timings.DNS, e.Timings.Connect, e.Timings.Send,
+				e.Timings.Wait} {
+				if v > 0 {
+					respTs += v / 1000
+				}
+			}
+		}
+
+		mimeType := ""
+		if e.Response.Content != nil {
+			mimeType = strings.TrimSpace(strings.SplitN(e.Response.Content.MimeType, ";", 2)[0])
+		}
+
+		rc.handleEvent("Network.responseReceived", godet.Params{
+			"requestId": reqID,
+			"timestamp": respTs,
+			"type":      resourceType,
+			"response": map[string]interface{}{
+				"url":             e.Request.URL,
+				"status":          float64(e.Response.Status),
+				"statusText":      e.Response.StatusText,
+				"headers":         harHeaderMap(e.Response.Headers),
+				"mimeType":        mimeType,
+				"remoteIPAddress": e.ServerIPAddress,
+				"protocol":        harProtocol(e.Response.HTTPVersion),
+			},
+		})
+
+		rc.handleEvent("Network.loadingFinished", godet.Params{
+			"requestId":         reqID,
+			"timestamp":         end,
+			"encodedDataLength": float64(e.TransferSize),
+		})
+
+		// sent cookies approximate the browser cookie jar
+		var host string
+		if u, err := url.Parse(e.Request.URL); err == nil {
+			host = u.Hostname()
+		}
+		for _, c := range e.Request.Cookies {
+			if c == nil || jarNames[c.Name] {
+				continue
+			}
+			jarNames[c.Name] = true
+			jar = append(jar, harJarCookie(c, host))
+		}
+	}
+
+	if site == "" {
+		site = entries[0].Request.URL
+	}
+
+	if len(har.Log.Pages) > 0 && har.Log.Pages[0] != nil && har.Log.Pages[0].PageTimings != nil {
+		page := har.Log.Pages[0]
+		start := ts(page.StartedDateTime)
+		if page.PageTimings.OnContentLoad >= 0 {
+			rc.handleEvent(pageEventDOMContentLoaded, godet.Params{"timestamp": start + page.PageTimings.OnContentLoad/1000})
+		}
+		if page.PageTimings.OnLoad >= 0 {
+			rc.handleEvent(pageEventLoad, godet.Params{"timestamp": start + page.PageTimings.OnLoad/1000})
+		}
+	}
+
+	rc.setCookies(jar)
+
+	return t.analyze(rc, site)
+}
+
+func harJarCookie(c *harCookie, host string) (cookie godet.Cookie) {
+	cookie = godet.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		HttpOnly: c.HTTPOnly,
+		Secure:   c.Secure,
+		Session:  true,
+		Expires:  -1,
+	}
+
+	if cookie.Domain == "" {
+		cookie.Domain = host
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if expires, err := time.Parse(time.RFC3339, c.Expires); err == nil {
+		cookie.Session = false
+		cookie.Expires = float64(expires.UnixNano()) / 1e9
+	}
+
+	return
+}
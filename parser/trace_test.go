@@ -0,0 +1,184 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+)
+
+// expectedCookie is the expected report entry of a cookie in the replay fixtures.
+type expectedCookie struct {
+	name       string
+	category   string
+	cookieType string
+	party      string
+}
+
+var replayCookies = []expectedCookie{
+	{"sid", "strictly-necessary", CookieTypeHTTP, "first-party"},
+	{"_ga", "performance", CookieTypeScript, "first-party"},
+	{"IDE", "targeting-advertising", CookieTypeHTTP, "third-party"},
+}
+
+func findCookie(data *reportData, name string) *reportCookieRecord {
+	for _, r := range data.Records {
+		for _, c := range r.Cookies {
+			if c.Name == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func checkReplayReport(t *testing.T, task *Task) {
+	data := task.reportData
+
+	if data.ScanURL != "https://www.example.com/" || data.SiteDomain != "example.com" {
+		t.Errorf("scanned %s of %s, want https://www.example.com/ of example.com", data.ScanURL, data.SiteDomain)
+	}
+	if data.CookieCount != 3 || data.FirstPartyCount != 2 || data.ThirdPartyCount != 1 {
+		t.Errorf("cookies = %d (%d first-party, %d third-party), want 3 (2, 1)",
+			data.CookieCount, data.FirstPartyCount, data.ThirdPartyCount)
+	}
+
+	for _, want := range replayCookies {
+		c := findCookie(data, want.name)
+		if c == nil {
+			t.Errorf("cookie %s not reported", want.name)
+			continue
+		}
+		if c.CanonicalCategory != want.category || c.Type != want.cookieType || c.Party != want.party {
+			t.Errorf("cookie %s = %s/%s/%s, want %s/%s/%s", want.name, c.CanonicalCategory, c.Type, c.Party,
+				want.category, want.cookieType, want.party)
+		}
+	}
+
+	if c := findCookie(data, "IDE"); c != nil && (c.RemoteAddr != "142.250.74.34" || c.Status != 200) {
+		t.Errorf("IDE set by %s with status %d, want 142.250.74.34 and 200", c.RemoteAddr, c.Status)
+	}
+	if c := findCookie(data, "sid"); c != nil && (math.Abs(c.FirstSet-120) > 1 || !c.HttpOnly) {
+		t.Errorf("sid first set at %.0fms http only %v, want 120ms and http only", c.FirstSet, c.HttpOnly)
+	}
+
+	if data.Timeline == nil {
+		t.Fatal("timeline not reported")
+	}
+	if math.Abs(data.Timeline.DOMContentLoaded-400) > 1 || math.Abs(data.Timeline.Load-800) > 1 {
+		t.Errorf("timeline = %.0fms/%.0fms, want 400ms/800ms", data.Timeline.DOMContentLoaded, data.Timeline.Load)
+	}
+}
+
+func TestReplayTrace(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/replay.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := NewTask(&TaskConfig{})
+	if err = task.ParseRecording(data); err != nil {
+		t.Fatal(err)
+	}
+
+	checkReplayReport(t, task)
+
+	if want := time.Date(2019, 6, 1, 10, 0, 0, 0, time.UTC); !task.reportData.ScanTime.Equal(want) {
+		t.Errorf("scan time = %s, want %s", task.reportData.ScanTime, want)
+	}
+}
+
+func TestImportHAR(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/replay.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := NewTask(&TaskConfig{})
+	if err = task.ParseRecording(data); err != nil {
+		t.Fatal(err)
+	}
+
+	checkReplayReport(t, task)
+
+	// exported har is importable again
+	exported, err := task.OutputHAR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reimported := NewTask(&TaskConfig{})
+	if err = reimported.ParseRecording([]byte(exported)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sid", "IDE"} {
+		if findCookie(reimported.reportData, name) == nil {
+			t.Errorf("cookie %s not reported after har round trip", name)
+		}
+	}
+}
+
+func TestReplayTraceInvalid(t *testing.T) {
+	for name, trace := range map[string]string{
+		"no scan":   `{"method":"Page.loadEventFired","params":{"timestamp":1}}`,
+		"malformed": `{"method":"CookieScanner.scan","params":{"url":"https://www.example.com/"}}` + "\n{",
+	} {
+		if err := NewTask(&TaskConfig{}).Replay(bytes.NewReader([]byte(trace))); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestImportHARMalformed(t *testing.T) {
+	const valid = `{"startedDateTime":"2019-06-01T10:00:00Z","request":{"method":"GET","url":"https://www.example.com/",` +
+		`"cookies":[null,{"name":"sid","value":"1"}]},"response":{"status":200,"headers":[null]}}`
+
+	for _, c := range []struct {
+		name    string
+		har     string
+		wantErr bool
+	}{
+		{"no entries", `{"log":{"entries":[]}}`, true},
+		{"nil entries", `{"log":{"entries":[null,null]}}`, true},
+		{"no request", `{"log":{"entries":[{"startedDateTime":"2019-06-01T10:00:00Z"}]}}`, true},
+		{"nil first entry", `{"log":{"pages":[null],"entries":[null,{"startedDateTime":"2019-06-01T09:00:00Z"},` + valid + `]}}`, false},
+		{"no response", `{"log":{"entries":[{"request":{"url":"https://www.example.com/"}}]}}`, false},
+	} {
+		var har harFile
+		if err := json.Unmarshal([]byte(c.har), &har); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		task := NewTask(&TaskConfig{})
+		err := task.ImportHAR(&har)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: error = %v, want error %v", c.name, err, c.wantErr)
+			continue
+		}
+		if err == nil && task.reportData.ScanURL != "https://www.example.com/" {
+			t.Errorf("%s: scanned %s, want https://www.example.com/", c.name, task.reportData.ScanURL)
+		}
+	}
+
+	if err := NewTask(&TaskConfig{}).ImportHAR(&harFile{}); err == nil {
+		t.Error("expected error of har without log")
+	}
+}
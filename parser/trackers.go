@@ -0,0 +1,120 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"net/url"
+	"strings"
+)
+
+type trackerInfo struct {
+	Domain   string
+	Vendor   string
+	Category string
+}
+
+// knownTrackers lists well known tracking and advertising domains with the vendor and usual cookie category.
+var knownTrackers = []*trackerInfo{
+	{Domain: "google-analytics.com", Vendor: "Google", Category: CategoryPerformance},
+	{Domain: "googletagmanager.com", Vendor: "Google", Category: CategoryPerformance},
+	{Domain: "doubleclick.net", Vendor: "Google", Category: CategoryTargeting},
+	{Domain: "googlesyndication.com", Vendor: "Google", Category: CategoryTargeting},
+	{Domain: "googleadservices.com", Vendor: "Google", Category: CategoryTargeting},
+	{Domain: "adservice.google.com", Vendor: "Google", Category: CategoryTargeting},
+	{Domain: "facebook.com", Vendor: "Facebook", Category: CategoryTargeting},
+	{Domain: "facebook.net", Vendor: "Facebook", Category: CategoryTargeting},
+	{Domain: "ads-twitter.com", Vendor: "Twitter", Category: CategoryTargeting},
+	{Domain: "analytics.twitter.com", Vendor: "Twitter", Category: CategoryTargeting},
+	{Domain: "ads.linkedin.com", Vendor: "LinkedIn", Category: CategoryTargeting},
+	{Domain: "px.ads.linkedin.com", Vendor: "LinkedIn", Category: CategoryTargeting},
+	{Domain: "snap.licdn.com", Vendor: "LinkedIn", Category: CategoryTargeting},
+	{Domain: "bat.bing.com", Vendor: "Microsoft", Category: CategoryTargeting},
+	{Domain: "clarity.ms", Vendor: "Microsoft", Category: CategoryPerformance},
+	{Domain: "analytics.tiktok.com", Vendor: "TikTok", Category: CategoryTargeting},
+	{Domain: "ct.pinterest.com", Vendor: "Pinterest", Category: CategoryTargeting},
+	{Domain: "sc-static.net", Vendor: "Snap", Category: CategoryTargeting},
+	{Domain: "criteo.com", Vendor: "Criteo", Category: CategoryTargeting},
+	{Domain: "criteo.net", Vendor: "Criteo", Category: CategoryTargeting},
+	{Domain: "adnxs.com", Vendor: "Xandr", Category: CategoryTargeting},
+	{Domain: "adsrvr.org", Vendor: "The Trade Desk", Category: CategoryTargeting},
+	{Domain: "rubiconproject.com", Vendor: "Magnite", Category: CategoryTargeting},
+	{Domain: "pubmatic.com", Vendor: "PubMatic", Category: CategoryTargeting},
+	{Domain: "openx.net", Vendor: "OpenX", Category: CategoryTargeting},
+	{Domain: "casalemedia.com", Vendor: "Index Exchange", Category: CategoryTargeting},
+	{Domain: "taboola.com", Vendor: "Taboola", Category: CategoryTargeting},
+	{Domain: "outbrain.com", Vendor: "Outbrain", Category: CategoryTargeting},
+	{Domain: "quantserve.com", Vendor: "Quantcast", Category: CategoryTargeting},
+	{Domain: "demdex.net", Vendor: "Adobe", Category: CategoryTargeting},
+	{Domain: "everesttech.net", Vendor: "Adobe", Category: CategoryTargeting},
+	{Domain: "omtrdc.net", Vendor: "Adobe", Category: CategoryPerformance},
+	{Domain: "2o7.net", Vendor: "Adobe", Category: CategoryPerformance},
+	{Domain: "scorecardresearch.com", Vendor: "comScore", Category: CategoryPerformance},
+	{Domain: "hotjar.com", Vendor: "Hotjar", Category: CategoryPerformance},
+	{Domain: "mixpanel.com", Vendor: "Mixpanel", Category: CategoryPerformance},
+	{Domain: "segment.io", Vendor: "Segment", Category: CategoryPerformance},
+	{Domain: "segment.com", Vendor: "Segment", Category: CategoryPerformance},
+	{Domain: "amplitude.com", Vendor: "Amplitude", Category: CategoryPerformance},
+	{Domain: "nr-data.net", Vendor: "New Relic", Category: CategoryPerformance},
+	{Domain: "hs-analytics.net", Vendor: "HubSpot", Category: CategoryPerformance},
+	{Domain: "mc.yandex.ru", Vendor: "Yandex", Category: CategoryPerformance},
+	{Domain: "yandex.ru", Vendor: "Yandex", Category: CategoryTargeting},
+	{Domain: "intercom.io", Vendor: "Intercom", Category: CategoryFunctionality},
+	{Domain: "zdassets.com", Vendor: "Zendesk", Category: CategoryFunctionality},
+	{Domain: "eulerian.net", Vendor: "Eulerian", Category: CategoryTargeting},
+	{Domain: "at-o.net", Vendor: "AT Internet", Category: CategoryPerformance},
+	{Domain: "xiti.com", Vendor: "AT Internet", Category: CategoryPerformance},
+	{Domain: "keyade.com", Vendor: "Keyade", Category: CategoryTargeting},
+	{Domain: "dnsdelegation.io", Vendor: "Criteo", Category: CategoryTargeting},
+	{Domain: "storetail.io", Vendor: "Criteo", Category: CategoryTargeting},
+	{Domain: "tagcommander.com", Vendor: "Commanders Act", Category: CategoryTargeting},
+	{Domain: "wizaly.com", Vendor: "Wizaly", Category: CategoryTargeting},
+	{Domain: "affex.org", Vendor: "Ingenious Technologies", Category: CategoryTargeting},
+	{Domain: "eloqua.com", Vendor: "Oracle", Category: CategoryTargeting},
+	{Domain: "pardot.com", Vendor: "Salesforce", Category: CategoryTargeting},
+	{Domain: "actonservice.com", Vendor: "Act-On", Category: CategoryTargeting},
+	{Domain: "webtrekk.net", Vendor: "Webtrekk", Category: CategoryPerformance},
+	{Domain: "wt-eu02.net", Vendor: "Webtrekk", Category: CategoryPerformance},
+	{Domain: "mediarithmics.com", Vendor: "mediarithmics", Category: CategoryTargeting},
+	{Domain: "adobedc.net", Vendor: "Adobe", Category: CategoryPerformance},
+	{Domain: "cookielaw.org", Vendor: "OneTrust", Category: CategoryStrictlyNecessary},
+	{Domain: "onetrust.com", Vendor: "OneTrust", Category: CategoryStrictlyNecessary},
+	{Domain: "cookiebot.com", Vendor: "Cookiebot", Category: CategoryStrictlyNecessary},
+}
+
+// matchTracker returns the most specific known tracker of host or cookie domain.
+func matchTracker(host string) (tracker *trackerInfo) {
+	host = strings.TrimPrefix(strings.ToLower(host), ".")
+
+	for _, t := range knownTrackers {
+		if host == t.Domain || strings.HasSuffix(host, "."+t.Domain) {
+			if tracker == nil || len(t.Domain) > len(tracker.Domain) {
+				tracker = t
+			}
+		}
+	}
+
+	return
+}
+
+// matchTrackerURL returns the known tracker of url host.
+func matchTrackerURL(rawURL string) *trackerInfo {
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		return matchTracker(u.Hostname())
+	}
+
+	return nil
+}
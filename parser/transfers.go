@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 The CovenantSQL Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// dataTransfer groups the contacted servers located in a country outside the EU/EEA.
+type dataTransfer struct {
+	Country     string
+	CountryName string
+	Continent   string
+	Hosts       []string
+	Networks    []string
+	// Cookies set by or sent to the servers of this country.
+	Cookies    []string
+	ThirdParty bool
+}
+
+// serverAddrs returns the remote addresses of all contacted hosts.
+func serverAddrs(rc *recordCollector) (addrs map[string][]string) {
+	addrs = map[string][]string{}
+
+	for _, records := range rc.get() {
+		var host string
+
+		for _, r := range records {
+			if !r.isRequest {
+				if host != "" {
+					remoteAddr, _ := r.params.Map("response")["remoteIPAddress"].(string)
+					addrs[host] = appendUnique(addrs[host], remoteAddr)
+				}
+				continue
+			}
+
+			// redirected requests share the request id and carry the response of previous url
+			if redirect, ok := r.params["redirectResponse"].(map[string]interface{}); ok && host != "" {
+				remoteAddr, _ := redirect["remoteIPAddress"].(string)
+				addrs[host] = appendUnique(addrs[host], remoteAddr)
+			}
+			host = ""
+
+			req, _ := r.params["request"].(map[string]interface{})
+			reqURL, _ := req["url"].(string)
+			if u, err := url.Parse(reqURL); err == nil {
+				host = strings.ToLower(u.Hostname())
+			}
+		}
+	}
+
+	for _, list := range addrs {
+		sort.Strings(list)
+	}
+
+	return
+}
+
+// locateServers looks up all contacted servers in geoip databases and returns the transfers outside the EU/EEA.
+func (t *Task) locateServers(rc *recordCollector, site string, records []*reportRecord,
+	hosts []*thirdPartyHost) (transfers []*dataTransfer, hostLocations map[string]*geoLocation) {
+	if t.cfg.GeoIP == nil {
+		return
+	}
+
+	var siteHost string
+	if u, err := url.Parse(site); err == nil {
+		siteHost = u.Hostname()
+	}
+
+	cache := map[string]*geoLocation{}
+	lookup := func(addr string) *geoLocation {
+		if l, ok := cache[addr]; ok {
+			return l
+		}
+		l := t.cfg.GeoIP.Lookup(addr)
+		cache[addr] = l
+		return l
+	}
+
+	hostLocations = map[string]*geoLocation{}
+	transferMap := map[string]*dataTransfer{}
+
+	addTransfer := func(l *geoLocation) (tr *dataTransfer) {
+		if l == nil || l.EEA || l.Country == "" {
+			return
+		}
+		if tr = transferMap[l.Country]; tr == nil {
+			tr = &dataTransfer{
+				Country:     l.Country,
+				CountryName: l.CountryName,
+				Continent:   l.Continent,
+			}
+			transferMap[l.Country] = tr
+			transfers = append(transfers, tr)
+		}
+		return
+	}
+
+	for host, addrs := range serverAddrs(rc) {
+		for _, addr := range addrs {
+			l := lookup(addr)
+			if l == nil {
+				continue
+			}
+			if hostLocations[host] == nil {
+				hostLocations[host] = l
+			}
+			if tr := addTransfer(l); tr != nil {
+				tr.Hosts = appendUnique(tr.Hosts, host)
+				tr.Networks = appendUnique(tr.Networks, l.Network())
+				tr.ThirdParty = tr.ThirdParty || isThirdParty(siteHost, host)
+			}
+		}
+	}
+
+	for _, h := range hosts {
+		for _, addr := range h.RemoteAddrs {
+			if l := lookup(addr); l != nil {
+				h.Locations = append(h.Locations, l)
+			}
+		}
+	}
+
+	for _, r := range records {
+		for _, c := range r.Cookies {
+			c.Location = lookup(c.RemoteAddr)
+			if tr := addTransfer(c.Location); tr != nil {
+				tr.Cookies = appendUnique(tr.Cookies, c.Name)
+			}
+			for _, d := range c.Destinations {
+				if tr := addTransfer(hostLocations[d.Host]); tr != nil {
+					tr.Cookies = appendUnique(tr.Cookies, c.Name)
+				}
+			}
+		}
+	}
+
+	for _, tr := range transfers {
+		sort.Strings(tr.Hosts)
+		sort.Strings(tr.Networks)
+		sort.Strings(tr.Cookies)
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].Country < transfers[j].Country
+	})
+
+	return
+}
+
+// labelLocations sets the server location of initiator nodes.
+func labelLocations(nodes []*initiatorNode, hostLocations map[string]*geoLocation) {
+	for _, n := range nodes {
+		n.Location = hostLocations[strings.ToLower(n.Host)]
+		labelLocations(n.Children, hostLocations)
+	}
+}
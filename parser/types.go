@@ -17,9 +17,10 @@
 package parser
 
 import (
+	"io"
 	"net/http"
-	"os/exec"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -34,15 +35,28 @@ type TaskConfig struct {
 	DebuggerPort      int
 	Headless          bool
 	Classifier        *Classifier
+	ReviewQueue       *ReviewQueue
+	Lang              string
+	Templates         *Templates
+	Branding          *Branding
+	PDF               *PDFOptions
+	Screenshot        *ScreenshotOptions
+	Resolver          CNAMEResolver
+	FilterLists       *FilterLists
+	GeoIP             *GeoIP
+	// Trace records the debugger events and cookies of scan as ndjson for replay
+	Trace io.Writer
+	// Driver replaces the chrome browser started by task
+	Driver Driver
 }
 
 type Task struct {
 	cfg        *TaskConfig
 	startTime  time.Time
-	remote     *godet.RemoteDebugger
+	driver     Driver
 	reportData *reportData
-	userDir    string
-	debugger   *exec.Cmd
+	// records keeps the raw network events of last scan for har export
+	records *recordCollector
 }
 
 func NewTask(tc *TaskConfig) *Task {
@@ -57,16 +71,111 @@ type record struct {
 }
 
 type recordCollector struct {
-	l       sync.Mutex
-	records map[string][]*record
+	l          sync.Mutex
+	records    map[string][]*record
+	pageEvents map[string]float64
+	// loading contains the Network.loadingFinished/loadingFailed event of requests
+	loading map[string]godet.Params
+	// cookies is the browser cookie jar after page load
+	cookies []godet.Cookie
 }
 
 func newRecordCollector() *recordCollector {
 	return &recordCollector{
-		records: map[string][]*record{},
+		records:    map[string][]*record{},
+		pageEvents: map[string]float64{},
+		loading:    map[string]godet.Params{},
 	}
 }
 
+// addPageEvent records the timestamp of first occurrence of page lifecycle event.
+func (rc *recordCollector) addPageEvent(event string, p godet.Params) {
+	ts, ok := p["timestamp"].(float64)
+	if !ok {
+		return
+	}
+
+	rc.l.Lock()
+	defer rc.l.Unlock()
+
+	if _, exists := rc.pageEvents[event]; !exists {
+		rc.pageEvents[event] = ts
+	}
+}
+
+func (rc *recordCollector) pageEvent(event string) (ts float64, ok bool) {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+
+	ts, ok = rc.pageEvents[event]
+	return
+}
+
+// handleEvent collects the debugger events used by report.
+func (rc *recordCollector) handleEvent(method string, p godet.Params) {
+	switch method {
+	case "Network.requestWillBeSent", "Network.responseReceived":
+		// events without timestamp could not be ordered
+		if _, ok := p["timestamp"].(float64); !ok {
+			return
+		}
+
+		key := "request"
+		if method == "Network.responseReceived" {
+			key = "response"
+		}
+
+		if reqURL, ok := p.Map(key)["url"].(string); !ok || strings.HasPrefix(reqURL, "data:") {
+			// data uri is ignored
+			return
+		}
+
+		if key == "request" {
+			rc.addRequest(p)
+		} else {
+			rc.addResponse(p)
+		}
+	case "Network.loadingFinished", "Network.loadingFailed":
+		rc.addLoadingEvent(p)
+	case pageEventDOMContentLoaded, pageEventLoad:
+		rc.addPageEvent(method, p)
+	}
+}
+
+func (rc *recordCollector) setCookies(cookies []godet.Cookie) {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+
+	rc.cookies = cookies
+}
+
+func (rc *recordCollector) getCookies() []godet.Cookie {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+
+	return rc.cookies
+}
+
+func (rc *recordCollector) addLoadingEvent(p godet.Params) {
+	reqID := p.String("requestId")
+	if reqID == "" {
+		return
+	}
+
+	rc.l.Lock()
+	defer rc.l.Unlock()
+
+	rc.loading[reqID] = p
+}
+
+func (rc *recordCollector) loadingEvent(reqID string) (p godet.Params, ok bool) {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+
+	p, ok = rc.loading[reqID]
+	return
+}
+
 func (rc *recordCollector) addRecord(r *record) {
 	if r.reqID == "" {
 		return
@@ -117,6 +226,7 @@ func (rc *recordCollector) addResponse(p godet.Params) {
 type outputRecord struct {
 	url         string
 	reqSeq      float64
+	respSeq     float64
 	statusCode  int
 	usedCookies []*http.Cookie
 	setCookies  []*http.Cookie